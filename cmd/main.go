@@ -1,24 +1,29 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 
 	"agentgo/internal/app"
+	"agentgo/internal/mockagent"
 )
 
 func main() {
-	// Create and run application coordinator
-	coordinator, err := app.NewCoordinator()
-	if err != nil {
-		fmt.Printf("Failed to initialize application: %v\n", err)
-		os.Exit(1)
+	// The mock agent stands in for a real one in tests, so it is not one
+	// of the listed commands
+	if len(os.Args) > 1 && os.Args[1] == "mock-agent" {
+		if err := mockagent.Main(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "mock-agent: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	defer coordinator.Close()
 
-	// Run the main application
-	if err := coordinator.Run(); err != nil {
-		fmt.Printf("Application error: %v\n", err)
-		os.Exit(1)
+	err := app.Program().Run(os.Args[1:])
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "agentgo: %v\n", err)
+		os.Exit(app.ExitCode(err))
 	}
 }
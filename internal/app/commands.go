@@ -0,0 +1,275 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"agentgo/internal/lineedit"
+	"agentgo/internal/render"
+	"agentgo/internal/slash"
+)
+
+// errQuit is returned by a command that ends the session
+var errQuit = errors.New("quit")
+
+var errorStyle = render.Style{Color: render.Red}
+
+// metaCommand is a client command, typed with a leading colon, that agentgo
+// handles itself instead of sending it to the agent
+type metaCommand struct {
+	name    string
+	aliases []string
+	// args describes the arguments in help and in the hint after the name
+	args        string
+	description string
+	minArgs     int
+	// maxArgs is the most arguments taken, or -1 for any number
+	maxArgs int
+
+	// complete offers candidates for the argument being typed, given the
+	// arguments before it and its text so far
+	complete func(l *lineInterface, args []string, current string) []lineedit.Completion
+	run      func(l *lineInterface, args []string) error
+}
+
+// usage is the command as it is typed, with its arguments
+func (c *metaCommand) usage() string {
+	if c.args == "" {
+		return ":" + c.name
+	}
+	return ":" + c.name + " " + c.args
+}
+
+// usageError reports a command given the wrong arguments
+type usageError struct {
+	command *metaCommand
+	reason  string
+}
+
+func (e *usageError) Error() string {
+	if e.reason != "" {
+		return e.reason + "\nUsage: " + e.command.usage()
+	}
+	return "Usage: " + e.command.usage()
+}
+
+type metaCommands []*metaCommand
+
+// lookup finds a command by name or alias, with or without its colon,
+// ignoring case
+func (m metaCommands) lookup(name string) (*metaCommand, bool) {
+	name = strings.ToLower(strings.TrimPrefix(name, ":"))
+	for _, command := range m {
+		if command.name == name || slices.Contains(command.aliases, name) {
+			return command, true
+		}
+	}
+	return nil, false
+}
+
+// isCommand reports whether name, with its leading colon, is one of the
+// commands
+func (m metaCommands) isCommand(name string) bool {
+	if !strings.HasPrefix(name, ":") {
+		return false
+	}
+	_, ok := m.lookup(name)
+	return ok
+}
+
+// catalog lists the commands for completion and help
+func (m metaCommands) catalog() []slash.Command {
+	commands := make([]slash.Command, 0, len(m))
+	for _, command := range m {
+		commands = append(commands, slash.Command{Name: ":" + command.name, Description: command.description, Hint: command.args})
+	}
+	return commands
+}
+
+// run parses the input of a command and runs it
+func (m metaCommands) run(l *lineInterface, name, input string) error {
+	command, ok := m.lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown command %s, type :help to list the commands", name)
+	}
+
+	args, err := splitArgs(input)
+	if err != nil {
+		return &usageError{command: command, reason: err.Error()}
+	}
+	if len(args) < command.minArgs || (command.maxArgs >= 0 && len(args) > command.maxArgs) {
+		return &usageError{command: command}
+	}
+	return command.run(l, args)
+}
+
+// splitArgs splits input at spaces. Single or double quotes keep spaces in
+// an argument, and a backslash takes the next character literally.
+func splitArgs(input string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg, escaped := false, false
+	var quote rune
+
+	for _, r := range input {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// runCommand runs a meta command, showing its errors without ending the
+// session. It returns true when the command quits.
+func (l *lineInterface) runCommand(name, input string) bool {
+	err := l.meta.run(l, name, input)
+	if errors.Is(err, errQuit) {
+		return true
+	}
+	if err != nil {
+		l.output.Println(l.output.Paint(errorStyle, "⚠ "+err.Error()))
+	}
+	return false
+}
+
+// commandCompleter completes the names of agent and local commands, and the
+// arguments of meta commands
+type commandCompleter struct {
+	l *lineInterface
+}
+
+func (c commandCompleter) Complete(text string) []lineedit.Completion {
+	if mention := lastWord(text); strings.HasPrefix(mention, "@") {
+		return completeMention(text, mention)
+	}
+
+	name, rest, found := strings.Cut(text, " ")
+	if !found || !strings.HasPrefix(name, ":") {
+		return c.l.commands.Complete(text)
+	}
+
+	command, ok := c.l.meta.lookup(name)
+	if !ok || command.complete == nil {
+		return nil
+	}
+
+	// The argument being typed is the text after the last space
+	args := strings.Fields(rest)
+	current := ""
+	if rest != "" && !strings.HasSuffix(rest, " ") {
+		current = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	if command.maxArgs >= 0 && len(args) >= command.maxArgs {
+		return nil
+	}
+
+	before := strings.TrimSuffix(text, current)
+	var completions []lineedit.Completion
+	for _, candidate := range command.complete(c.l, args, current) {
+		if !strings.HasPrefix(strings.ToLower(candidate.Text), strings.ToLower(current)) {
+			continue
+		}
+		if candidate.Display == "" {
+			candidate.Display = candidate.Text
+		}
+		candidate.Text = before + candidate.Text
+		completions = append(completions, candidate)
+	}
+	return completions
+}
+
+func (c commandCompleter) Hint(text string) string {
+	if text == ":" {
+		return "Tab to list commands"
+	}
+	return c.l.commands.Hint(text)
+}
+
+// lastWord is the text after the last whitespace
+func lastWord(text string) string {
+	return text[strings.LastIndexAny(text, " \t\n")+1:]
+}
+
+// completeMention completes the path of an @mention being typed
+func completeMention(text, mention string) []lineedit.Completion {
+	before := strings.TrimSuffix(text, mention) + "@"
+	current := mention[1:]
+
+	var completions []lineedit.Completion
+	for _, candidate := range completePath(nil, nil, current) {
+		if strings.HasPrefix(candidate.Text, current) {
+			candidate.Text = before + candidate.Text
+			completions = append(completions, candidate)
+		}
+	}
+	return completions
+}
+
+// wordCompletions offers fixed words
+func wordCompletions(words ...string) []lineedit.Completion {
+	completions := make([]lineedit.Completion, 0, len(words))
+	for _, word := range words {
+		completions = append(completions, lineedit.Completion{Text: word})
+	}
+	return completions
+}
+
+// completePath offers the files and directories matching the path being
+// typed. Hidden entries are offered once a dot is typed.
+func completePath(_ *lineInterface, _ []string, current string) []lineedit.Completion {
+	dir, base := filepath.Split(current)
+	listed := dir
+	if listed == "" {
+		listed = "."
+	}
+	entries, err := os.ReadDir(listed)
+	if err != nil {
+		return nil
+	}
+
+	var completions []lineedit.Completion
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		completions = append(completions, lineedit.Completion{Text: dir + name, Display: name})
+	}
+	return completions
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"agentgo/internal/policy"
+	"agentgo/internal/render"
+	"agentgo/internal/slash"
+	"agentgo/protocol"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{"", nil, ""},
+		{"  one   two\tthree ", []string{"one", "two", "three"}, ""},
+		{`"two words" plain`, []string{"two words", "plain"}, ""},
+		{`'single "quoted"'`, []string{`single "quoted"`}, ""},
+		{`"double 'quoted'"`, []string{"double 'quoted'"}, ""},
+		{`my\ file.txt`, []string{"my file.txt"}, ""},
+		{`"say \"hi\""`, []string{`say "hi"`}, ""},
+		{`'C:\path'`, []string{`C:\path`}, ""},
+		{`a\\b`, []string{`a\b`}, ""},
+		{`""`, []string{""}, ""},
+		{`pre"fix"ed`, []string{"prefixed"}, ""},
+		{`"unterminated`, nil, `unterminated " quote`},
+		{`it's`, nil, "unterminated ' quote"},
+	}
+
+	for _, tt := range tests {
+		got, err := splitArgs(tt.input)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("splitArgs(%q) error = %v, expected %q", tt.input, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitArgs(%q) error = %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, expected %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// recordingCommands returns commands whose runs are recorded in ran
+func recordingCommands(ran *[][]string) metaCommands {
+	record := func(_ *lineInterface, args []string) error {
+		*ran = append(*ran, args)
+		return nil
+	}
+	return metaCommands{
+		{name: "save", args: "<file>", minArgs: 1, maxArgs: 1, run: record},
+		{name: "quit", aliases: []string{"q", "exit"}, run: record},
+		{name: "attach", args: "[path...]", maxArgs: -1, run: record},
+		{name: "policy", args: "[tool [ask|allow|reject]]", maxArgs: 2, run: record},
+	}
+}
+
+func TestMetaCommands_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{":save", "notes.txt", []string{"notes.txt"}, ""},
+		{":save", `"my notes.txt"`, []string{"my notes.txt"}, ""},
+		{":save", "", nil, "Usage: :save <file>"},
+		{":save", "a b", nil, "Usage: :save <file>"},
+		{":save", `"a`, nil, "unterminated \" quote\nUsage: :save <file>"},
+		{":quit", "", []string{}, ""},
+		{":quit", "now", nil, "Usage: :quit"},
+		{":attach", "", []string{}, ""},
+		{":attach", "a b c d", []string{"a", "b", "c", "d"}, ""},
+		{":policy", "bash allow", []string{"bash", "allow"}, ""},
+		{":policy", "bash allow now", nil, "Usage: :policy [tool [ask|allow|reject]]"},
+		{":nope", "", nil, "unknown command :nope, type :help to list the commands"},
+	}
+
+	for _, tt := range tests {
+		var ran [][]string
+		err := recordingCommands(&ran).run(nil, tt.name, tt.input)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s %s: error = %v, expected %q", tt.name, tt.input, err, tt.wantErr)
+			}
+			if len(ran) > 0 {
+				t.Errorf("%s %s: expected the command not to run", tt.name, tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s: error = %v", tt.name, tt.input, err)
+			continue
+		}
+		if len(ran) != 1 || len(ran[0]) != len(tt.want) || (len(tt.want) > 0 && !reflect.DeepEqual(ran[0], tt.want)) {
+			t.Errorf("%s %s: ran with %q, expected %q", tt.name, tt.input, ran, tt.want)
+		}
+	}
+
+	var ran [][]string
+	var usage *usageError
+	if err := recordingCommands(&ran).run(nil, ":save", ""); !errors.As(err, &usage) || usage.command.name != "save" {
+		t.Errorf("Expected a usage error for :save, got %v", err)
+	}
+}
+
+func TestMetaCommands_Lookup(t *testing.T) {
+	commands := newMetaCommands()
+	tests := []struct {
+		name      string
+		want      string
+		isCommand bool
+	}{
+		{":quit", "quit", true},
+		{":q", "quit", true},
+		{":EXIT", "quit", true},
+		{"help", "help", false},
+		{":Help", "help", true},
+		{":D", "", false},
+		{":)", "", false},
+		{"/quit", "", false},
+	}
+
+	for _, tt := range tests {
+		command, ok := commands.lookup(tt.name)
+		switch {
+		case tt.want == "" && ok:
+			t.Errorf("lookup(%q) found %s, expected nothing", tt.name, command.name)
+		case tt.want != "" && (!ok || command.name != tt.want):
+			t.Errorf("lookup(%q) = %v, %v, expected %s", tt.name, command, ok, tt.want)
+		}
+		if got := commands.isCommand(tt.name); got != tt.isCommand {
+			t.Errorf("isCommand(%q) = %v, expected %v", tt.name, got, tt.isCommand)
+		}
+	}
+}
+
+func TestCommandCompleter_Complete(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"notes.txt", "notebook.md", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	l := &lineInterface{meta: newMetaCommands(), commands: slash.NewCatalog(), policy: policy.New()}
+	l.commands.AddLocal(l.meta.catalog()...)
+	completer := commandCompleter{l: l}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{":qu", []string{":quit"}},
+		{":help q", []string{":help quit"}},
+		{":policy b", []string{":policy bash"}},
+		{":policy bash a", []string{":policy bash ask", ":policy bash allow"}},
+		{":POLICY bash R", []string{":POLICY bash reject"}},
+		{":policy bash allow ", nil},
+		{":save note", []string{":save notebook.md", ":save notes.txt"}},
+		{":save notes.txt ", nil},
+		{":save .h", []string{":save .hidden"}},
+		{":attach notes.txt ne", []string{":attach notes.txt nested/"}},
+		{":quit ", nil},
+		{":nope a", nil},
+		{"look at @note", []string{"look at @notebook.md", "look at @notes.txt"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, completion := range completer.Complete(tt.text) {
+			got = append(got, completion.Text)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %q, expected %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLineInterface_Help(t *testing.T) {
+	var output bytes.Buffer
+	l := &lineInterface{meta: newMetaCommands(), commands: slash.NewCatalog(), output: render.NewTextRenderer(&output, render.PlainText)}
+	l.commands.AddLocal(l.meta.catalog()...)
+	l.commands.Update([]protocol.Command{{Name: "review", Description: "Review the changes", Input: &protocol.CommandInput{Hint: "branch"}}})
+
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{[]string{"save"}, ":save <file>\n  Save the transcript as plain text\n", ""},
+		{[]string{":q"}, ":quit\n  End the session\n  Also: :q, :exit\n", ""},
+		{[]string{"review"}, "/review <branch>\n  Review the changes\n", ""},
+		{[]string{"/review"}, "/review <branch>\n  Review the changes\n", ""},
+		{[]string{"nope"}, "", "unknown command nope"},
+	}
+
+	for _, tt := range tests {
+		output.Reset()
+		err := l.help(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("help(%q) error = %v, expected %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || output.String() != tt.want {
+			t.Errorf("help(%q) = %q, %v, expected %q", tt.args, output.String(), err, tt.want)
+		}
+	}
+
+	output.Reset()
+	if err := l.help(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{":attach [path...]", "/review"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("Expected the command list to contain %q, got:\n%s", want, output.String())
+		}
+	}
+}
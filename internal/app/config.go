@@ -1,23 +1,45 @@
 package app
 
-import "flag"
+import (
+	"io"
+	"log"
+
+	"agentgo/internal/cli"
+	"agentgo/protocol"
+)
+
+// DefaultAgent is the command line that starts the agent when -agent is
+// not given
+const DefaultAgent = "claude-code-acp"
 
 // Config holds the application configuration
 type Config struct {
-	RecordFile string
-	ReplayFile string
+	// Agent is the command that starts the agent, then its arguments
+	Agent          []string
+	RecordFile     string
+	ReplayFile     string
+	MaxMessageSize int
+	Replay         protocol.ReplayOptions
+	ReplayStep     bool
+	TUI            bool
+	// Policy holds the permission rules, written as tool=decision
+	Policy    string
+	Verbosity string
 }
 
-// ParseFlags parses command line flags and returns configuration
-func ParseFlags() *Config {
-	recordFile := flag.String("record", "", "Record conversation to file")
-	replayFile := flag.String("replay", "", "Replay conversation from file")
-	flag.Parse()
+// setGlobals applies the global flags
+func (c *Config) setGlobals(globals *cli.Globals) {
+	command, args := globals.AgentCommand()
+	c.Agent = append([]string{command}, args...)
+	c.Verbosity = globals.Verbosity
+}
 
-	return &Config{
-		RecordFile: *recordFile,
-		ReplayFile: *replayFile,
+// agentCommand returns the command that starts the agent and its arguments
+func (c *Config) agentCommand() (string, []string) {
+	if len(c.Agent) == 0 {
+		return DefaultAgent, nil
 	}
+	return c.Agent[0], c.Agent[1:]
 }
 
 // IsRecording returns true if recording is enabled
@@ -33,4 +55,16 @@ func (c *Config) IsReplaying() bool {
 // IsNormalMode returns true if neither recording nor replaying
 func (c *Config) IsNormalMode() bool {
 	return !c.IsRecording() && !c.IsReplaying()
-}
\ No newline at end of file
+}
+
+// configureConnection applies the settings that tune a connection. Quiet
+// runs drop protocol warnings, and verbose ones log every message.
+func configureConnection(connection *protocol.AcpConnection, config *Config) {
+	connection.SetMaxMessageSize(config.MaxMessageSize)
+	switch config.Verbosity {
+	case cli.Quiet:
+		connection.SetLogger(log.New(io.Discard, "", 0))
+	case cli.Verbose:
+		connection.SetTrace(true)
+	}
+}
@@ -2,14 +2,17 @@ package app
 
 import (
 	"bufio"
-	"fmt"
 	"io"
 	"log"
 	"os"
-	"time"
 
+	"agentgo/internal/lineedit"
+	"agentgo/internal/policy"
+	"agentgo/internal/render"
+	"agentgo/internal/slash"
+	"agentgo/internal/terminal"
+	"agentgo/internal/tui"
 	"agentgo/protocol"
-	"agentgo/providers/claude"
 )
 
 // Coordinator orchestrates the main application flow
@@ -17,93 +20,175 @@ type Coordinator struct {
 	config     *Config
 	connection *protocol.AcpConnection
 	lifecycle  *LifecycleManager
-	handlers   protocol.Handler
+	output     render.Renderer
+	line       *lineInterface
+	ui         *tui.App
+	// stepInput is the user's input when stepping through a replay, shared
+	// by the stepper and permission prompts
+	stepInput *bufio.Reader
+
+	stopFollowingResizes func()
 }
 
 // NewCoordinator creates a new application coordinator
-func NewCoordinator() (*Coordinator, error) {
-	config := ParseFlags()
+func NewCoordinator(config *Config) (*Coordinator, error) {
+	rules, err := policy.Parse(config.Policy)
+	if err != nil {
+		return nil, err
+	}
 
-	connection, err := createConnection(config)
+	// Output goes through the editor so it appears above a prompt being
+	// typed. The editor shows the agent's progress itself when reading from
+	// a terminal, so the spinner only animates when it is not.
+	history := loadHistory()
+	editor := lineedit.NewEditor(os.Stdin, os.Stdout, history)
+	live := terminal.IsTerminal(os.Stdin)
+	capabilities := render.DetectCapabilities(os.Stdout)
+	editor.SetCapabilities(capabilities)
+	commands := slash.NewCatalog()
+	spinner := render.NewSpinner(editor, capabilities, terminal.IsTerminal(os.Stdout) && !live)
+	// A plain copy of the output is kept for :save
+	transcript := &transcript{}
+	output := render.NewTextRenderer(io.MultiWriter(spinner, transcript), capabilities)
+
+	connection, err := createConnection(config, output)
 	if err != nil {
 		return nil, err
 	}
 
-	if !config.IsReplaying() {
+	var ui *tui.App
+	if config.TUI && !config.ReplayStep {
+		if terminal.IsTerminal(os.Stdin) && terminal.IsTerminal(os.Stdout) {
+			ui = tui.New(os.Stdin, os.Stdout)
+			ui.SetHistory(history)
+			ui.SetCommands(commands)
+			connection.SetLogger(log.New(ui.LogWriter(), "acp: ", 0))
+		} else {
+			output.Printf("Not running in a terminal, using the line interface\n")
+		}
+	}
+	if ui == nil && live {
+		// The editor keeps the terminal in raw mode while the agent works,
+		// so the connection's logs go through it like any other output
+		connection.SetLogger(log.New(editor, "acp: ", log.LstdFlags))
+	}
+	configureConnection(connection, config)
+
+	// Strict replays check the session/new exchange like any other message
+	if !config.IsReplaying() || config.Replay.Strict {
 		_, err = connection.InitializeSession()
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	claude := &claude.Claude{}
-	appHandlers := NewHandlers(claude, claude)
+	var line *lineInterface
+	var stepInput *bufio.Reader
+	switch {
+	case ui != nil:
+		RegisterHandlers(connection.Registry(), ui, ui)
+	case config.IsReplaying() && config.ReplayStep:
+		stepInput = bufio.NewReader(os.Stdin)
+		prompt := newStepPrompt(output, stepInput)
+		RegisterHandlers(connection.Registry(), prompt, prompt)
+	default:
+		line = newLineInterface(connection, output, editor, spinner, commands, transcript, live)
+		line.policy = rules
+		RegisterHandlers(connection.Registry(), line, line)
+	}
 
 	lifecycle := NewLifecycleManager(connection)
+	lifecycle.OnShutdown(func() { spinner.Stop() })
+	lifecycle.OnShutdown(editor.Close)
+	if ui != nil {
+		lifecycle.OnShutdown(ui.Close)
+	}
 
 	return &Coordinator{
-		config:     config,
-		connection: connection,
-		lifecycle:  lifecycle,
-		handlers:   appHandlers,
+		config:               config,
+		connection:           connection,
+		lifecycle:            lifecycle,
+		output:               output,
+		line:                 line,
+		ui:                   ui,
+		stepInput:            stepInput,
+		stopFollowingResizes: output.FollowResizes(os.Stdout),
 	}, nil
 }
 
 // Run starts the main application loop
 func (c *Coordinator) Run() error {
-	ch := make(chan int)
+	turns := make(chan protocol.TurnResult)
 
 	c.lifecycle.SetupGracefulShutdown()
 
-	go func() {
-		if err := c.connection.StreamResponses(c.handlers, ch); err != nil {
-			panic(err)
+	if c.config.IsReplaying() && c.config.ReplayStep {
+		stepper, err := protocol.NewReplayStepper(c.connection)
+		if err != nil {
+			return err
 		}
-	}()
+		return runReplayStepper(stepper, c.stepInput, c.output)
+	}
 
-	return c.runInteractionLoop(ch)
-}
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- c.connection.StreamResponses(turns)
+	}()
 
-func (c *Coordinator) runInteractionLoop(ch chan int) error {
-	for {
-		time.Sleep(time.Second * 5)
-		reader := bufio.NewReader(os.Stdin)
+	if c.ui != nil {
+		return c.ui.Run(c.connection, turns, streamErr)
+	}
 
-		fmt.Print("> ")
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			log.Fatal(err)
-		}
+	return c.line.run(turns, streamErr)
+}
 
-		if err := c.connection.SendMessage(line); err != nil {
-			return err
-		}
-		_ = <-ch
+// loadHistory opens the prompt history of the project in the working
+// directory, keeping it in memory only when the file cannot be used
+func loadHistory() *lineedit.History {
+	dir, err := os.Getwd()
+	if err != nil {
+		return lineedit.NewHistory(0)
 	}
-
-	return nil
+	path, err := lineedit.ProjectHistoryPath(dir)
+	if err != nil {
+		return lineedit.NewHistory(0)
+	}
+	history, err := lineedit.LoadHistory(path, 0)
+	if err != nil {
+		log.Printf("Prompt history unavailable: %v", err)
+		return lineedit.NewHistory(0)
+	}
+	return history
 }
 
 // Close cleans up resources
 func (c *Coordinator) Close() error {
+	if c.stopFollowingResizes != nil {
+		c.stopFollowingResizes()
+	}
 	if c.connection != nil {
 		return c.connection.Close()
 	}
 	return nil
 }
 
-func createConnection(config *Config) (*protocol.AcpConnection, error) {
+func createConnection(config *Config, output render.Renderer) (*protocol.AcpConnection, error) {
 	switch {
 	case config.IsReplaying():
-		fmt.Printf("Replaying conversation from: %s\n", config.ReplayFile)
-		return protocol.OpenAcpReplayConnection(config.ReplayFile)
+		output.Printf("Replaying conversation from: %s\n", config.ReplayFile)
+		options := config.Replay
+		if config.ReplayStep {
+			// The user sets the pace when stepping
+			options.Speed = 0
+			options.MaxGap = 0
+		}
+		return protocol.OpenAcpReplayConnection(config.ReplayFile, options)
 	case config.IsRecording():
-		fmt.Printf("Recording conversation to: %s\n", config.RecordFile)
-		return protocol.OpenAcpRecordingConnection("claude-code-acp", config.RecordFile)
+		output.Printf("Recording conversation to: %s\n", config.RecordFile)
+		command, args := config.agentCommand()
+		return protocol.OpenAcpRecordingConnection(command, config.RecordFile, args...)
 	default:
-		return protocol.OpenAcpStdioConnection("claude-code-acp"), nil
+		command, args := config.agentCommand()
+		return protocol.OpenAcpConnection(protocol.NewBinaryIOProvider(command, args...))
 	}
 }
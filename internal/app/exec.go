@@ -0,0 +1,390 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"agentgo/internal/attach"
+	"agentgo/internal/cli"
+	"agentgo/internal/events"
+	"agentgo/internal/policy"
+	"agentgo/internal/render"
+	"agentgo/protocol"
+	"agentgo/providers/claude"
+)
+
+// ExecConfig holds the options of a one-shot exec run
+type ExecConfig struct {
+	Config
+	// Prompt is the text given on the command line
+	Prompt string
+	// PromptFile is read for the prompt, after any text given on the
+	// command line
+	PromptFile string
+	// Ask asks on the terminal about the permission requests no rule
+	// decides, instead of rejecting them
+	Ask bool
+	// Output is the format the answer is written in: OutputText,
+	// OutputJSON or OutputStreamJSON
+	Output string
+}
+
+// Formats of the output of agentgo exec
+const (
+	// OutputText streams the answer as it is written
+	OutputText = "text"
+	// OutputJSON writes a summary of the run once the turn ends
+	OutputJSON = "json"
+	// OutputStreamJSON writes each event as a line of JSON as it happens
+	OutputStreamJSON = "stream-json"
+)
+
+// Exec sends a single prompt, with any piped input attached, streams the
+// agent's answer to stdout and returns when the turn ends. Everything else
+// the agent reports, such as tool calls, goes to stderr so the answer can
+// be redirected on its own. With a JSON output format, stdout gets events
+// or a summary instead of the answer's text.
+func Exec(config *ExecConfig) error {
+	switch config.Output {
+	case OutputText, OutputJSON, OutputStreamJSON:
+	default:
+		return fmt.Errorf("unknown output format %q, expected text, json or stream-json", config.Output)
+	}
+
+	text, err := execPromptText(config)
+	if err != nil {
+		return err
+	}
+	var piped []byte
+	if isPiped(os.Stdin) {
+		if piped, err = io.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("reading piped input: %w", err)
+		}
+		if !utf8.Valid(piped) {
+			return errors.New("piped input is not text")
+		}
+	}
+	if strings.TrimSpace(text) == "" && len(piped) == 0 {
+		return errors.New("no prompt: give it as arguments, with -f or on stdin")
+	}
+	rules, err := policy.Parse(config.Policy)
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	// Permission prompts are shown even when progress is not
+	ask := render.NewTextRenderer(os.Stderr, render.DetectCapabilities(os.Stderr))
+	status := ask
+	if config.Verbosity == cli.Quiet {
+		status = render.NewTextRenderer(io.Discard, render.PlainText)
+	}
+	connection, err := createConnection(&config.Config, status)
+	if errors.Is(err, osexec.ErrNotFound) {
+		return &ExitError{Code: ExitAgentCrash, Err: fmt.Errorf("starting the agent: %w", err)}
+	} else if err != nil {
+		return err
+	}
+	defer connection.Close()
+	configureConnection(connection, &config.Config)
+
+	output := newExecOutput(os.Stdout, status, ask, rules, config, started)
+	defer output.Close()
+	sessionID, err := connection.InitializeSession()
+	if err != nil {
+		return output.End("", connectionExit(err))
+	}
+	output.Emit(events.Event{Type: events.SessionStarted, SessionID: sessionID})
+	RegisterHandlers(connection.Registry(), output, output)
+
+	turns := make(chan protocol.TurnResult, 1)
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- connection.StreamResponses(turns)
+	}()
+
+	prompt, attached, problems := execPrompt(text, piped, connection.PromptCapabilities())
+	if len(problems) > 0 {
+		return fmt.Errorf("attaching files: %w", errors.Join(problems...))
+	}
+	claude.DisplayAttachments(status, describe(attached))
+	if err := connection.SendPrompt(prompt); err != nil {
+		return output.End("", connectionExit(err))
+	}
+
+	result, err := awaitTurn(connection, turns, streamErr)
+	output.EndAnswer()
+	if err == nil {
+		claude.DisplayTurnEnd(status, result, time.Since(started))
+		err = turnExit(result)
+	}
+	return output.End(result.StopReason, err)
+}
+
+// awaitTurn waits for the end of the turn. The first interrupt cancels the
+// turn, so the agent can stop cleanly; a second one gives up at once.
+func awaitTurn(connection *protocol.AcpConnection, turns <-chan protocol.TurnResult, streamErr <-chan error) (protocol.TurnResult, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	cancelled := false
+	for {
+		select {
+		case result := <-turns:
+			return result, nil
+		case err := <-streamErr:
+			if errors.Is(err, io.EOF) {
+				return protocol.TurnResult{}, &ExitError{Code: ExitAgentCrash, Err: errors.New("the agent exited before the turn ended")}
+			}
+			return protocol.TurnResult{}, connectionExit(err)
+		case <-signals:
+			if cancelled {
+				return protocol.TurnResult{}, &ExitError{Code: ExitCancelled, Err: errors.New("interrupted")}
+			}
+			cancelled = true
+			if err := connection.SendCancel(); err != nil {
+				return protocol.TurnResult{}, connectionExit(err)
+			}
+		}
+	}
+}
+
+// isPiped reports whether f is a pipe or a file, as when input is piped or
+// redirected to agentgo. Terminals and devices such as /dev/null are not
+// read, so exec run from a script without input does not wait for it.
+func isPiped(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()
+}
+
+// execPromptText joins the prompt given as arguments and the prompt file
+func execPromptText(config *ExecConfig) (string, error) {
+	text := config.Prompt
+	if config.PromptFile == "" {
+		return text, nil
+	}
+
+	data, err := os.ReadFile(config.PromptFile)
+	if err != nil {
+		return "", err
+	}
+	if text != "" {
+		text += "\n\n"
+	}
+	return text + string(data), nil
+}
+
+// pipedURI names piped input in the resource it is sent as
+const pipedURI = "stdin:"
+
+// execPrompt builds the prompt from its text and the piped input. Piped
+// input is embedded as a resource when the agent accepts them, and
+// otherwise follows the text.
+func execPrompt(text string, piped []byte, capabilities protocol.PromptCapabilities) ([]protocol.Prompt, []attach.Item, []error) {
+	prompt, attached, problems := attach.Prompt(text, nil, capabilities)
+	if strings.TrimSpace(text) == "" {
+		prompt = prompt[1:]
+	}
+	if len(piped) == 0 {
+		return prompt, attached, problems
+	}
+
+	if capabilities.EmbeddedContext {
+		return append(prompt, attach.Embed(pipedURI, pipedMimeType(piped), string(piped))), attached, problems
+	}
+	return append(prompt, protocol.Prompt{Type: protocol.PromptText, Text: string(piped)}), attached, problems
+}
+
+// pipedMimeType recognises the output of diff tools, the most common input
+// piped for review, and treats anything else as plain text
+func pipedMimeType(piped []byte) string {
+	for _, prefix := range []string{"diff --git ", "--- ", "Index: "} {
+		if bytes.HasPrefix(piped, []byte(prefix)) {
+			return "text/x-diff"
+		}
+	}
+	return "text/plain"
+}
+
+// execOutput writes the agent's answer to stdout as it streams, and shows
+// everything else on the status renderer. With a JSON format it writes
+// events or a summary of them instead. Permission requests are decided by
+// the rules alone, and those no rule decides are rejected unless asking on
+// the terminal was asked for.
+type execOutput struct {
+	answer io.Writer
+	status render.Renderer
+	// display shows progress on the status renderer, and prompt asks for
+	// permissions on the terminal
+	display *claude.Claude
+	prompt  *claude.Claude
+	tty     *os.File
+	rules   *policy.Policy
+	reject  *policy.Policy
+
+	format    string
+	collector *events.Collector
+
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	// midLine is set when the answer so far does not end with a newline
+	midLine bool
+}
+
+func newExecOutput(answer io.Writer, status, ask render.Renderer, rules *policy.Policy, config *ExecConfig, started time.Time) *execOutput {
+	o := &execOutput{
+		answer:    answer,
+		status:    status,
+		display:   claude.NewClaude(),
+		prompt:    claude.NewClaude(),
+		rules:     rules,
+		reject:    policy.New(),
+		format:    config.Output,
+		collector: events.NewCollector(started),
+		encoder:   json.NewEncoder(answer),
+	}
+	o.display.SetRenderer(status)
+	o.prompt.SetRenderer(ask)
+	for _, tool := range policy.Tools {
+		o.reject.Set(tool, policy.Reject)
+	}
+
+	if !config.Ask {
+		return o
+	}
+	// stdin may be the piped input, so answers are read from the terminal
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		o.tty = tty
+		o.prompt.SetInput(tty)
+	}
+	return o
+}
+
+// Close releases the terminal opened for permission prompts
+func (o *execOutput) Close() {
+	if o.tty != nil {
+		o.tty.Close()
+	}
+}
+
+// Emit records an event, writing it out when events are streamed
+func (o *execOutput) Emit(event events.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	o.collector.Add(event)
+	if o.format != OutputStreamJSON {
+		return
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.encoder.Encode(event)
+}
+
+// End records the end of the run, writes the summary when one is wanted,
+// and returns err, the error the run ends with
+func (o *execOutput) End(stopReason string, err error) error {
+	event := events.Event{Type: events.TurnEnded, Time: time.Now(), StopReason: stopReason}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	event.Duration = events.NewDuration(event.Time.Sub(o.collector.Started()))
+	o.Emit(event)
+
+	if o.format == OutputJSON {
+		o.mutex.Lock()
+		defer o.mutex.Unlock()
+		o.encoder.SetIndent("", "  ")
+		o.encoder.Encode(o.collector.Summary())
+	}
+	return err
+}
+
+func (o *execOutput) HandleNotification(raw []byte, req protocol.SessionUpdateRequest) error {
+	update := req.Params.Update
+	if event, ok := events.FromUpdate(update, time.Now()); ok {
+		o.Emit(event)
+	}
+	if o.format != OutputText {
+		return nil
+	}
+
+	if update.SessionUpdateType != protocol.SessionUpdateAgentMessage {
+		return o.display.HandleNotification(raw, req)
+	}
+	if update.Content == nil || update.Content.Text == "" {
+		return nil
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.midLine = !strings.HasSuffix(update.Content.Text, "\n")
+	_, err := io.WriteString(o.answer, update.Content.Text)
+	return err
+}
+
+func (o *execOutput) HandlePermissionRequest(
+	acpConn *protocol.AcpConnection,
+	raw []byte,
+	req protocol.SessionRequestPermissionRequest,
+) error {
+	tool := policy.ToolOf(req.Params.ToolCall.RawInput)
+	answer := func(option protocol.PermissionOption, ok bool, by string) error {
+		permission := &events.Permission{ToolCallID: req.Params.ToolCall.ToolCallID, Tool: tool, By: by}
+		if ok {
+			permission.Option = option.OptionID
+		}
+		o.Emit(events.Event{Type: events.PermissionDecided, Permission: permission})
+
+		if !ok {
+			return acpConn.SendToolCancelled(req.ID)
+		}
+		return acpConn.SendToolResponse(req.ID, option.OptionID)
+	}
+
+	if option, ok := o.rules.Decide(req.Params); ok {
+		claude.ShowPolicySelection(o.status, tool, option.Name)
+		return answer(option, true, "policy")
+	}
+	if o.tty != nil {
+		option, ok, err := o.prompt.AskPermission(req)
+		if err != nil {
+			return err
+		}
+		return answer(option, ok, "user")
+	}
+
+	option, ok := o.reject.Decide(req.Params)
+	if !ok {
+		o.status.Printf("Cancelled a %s request: no rule allows it\n", tool)
+	} else {
+		o.status.Printf("Rejected a %s request: no rule allows it\n", tool)
+	}
+	return answer(option, ok, "default")
+}
+
+// EndAnswer finishes the answer's last line
+func (o *execOutput) EndAnswer() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.midLine {
+		io.WriteString(o.answer, "\n")
+		o.midLine = false
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"agentgo/protocol"
+)
+
+// Exit codes of agentgo exec, so scripts can tell why a run failed
+const (
+	ExitOK = 0
+	// ExitFailure covers usage errors and anything not listed below
+	ExitFailure = 1
+	// ExitRefusal is used when the agent refused the prompt
+	ExitRefusal = 2
+	// ExitLimit is used when the agent stopped at its token or model
+	// request limit
+	ExitLimit = 3
+	// ExitCancelled is used when the turn was cancelled or interrupted
+	ExitCancelled = 4
+	// ExitAgentCrash is used when the agent could not be started or exited
+	// before the turn ended
+	ExitAgentCrash = 5
+	// ExitProtocolError is used when the agent replied with an error or
+	// with messages agentgo could not understand
+	ExitProtocolError = 6
+)
+
+// ExitError is an error that ends agentgo with a particular exit code
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode is the code agentgo exits with after err
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitFailure
+}
+
+// turnExit gives the error a turn's result ends exec with, nil when the
+// agent finished normally
+func turnExit(result protocol.TurnResult) error {
+	if result.Err != nil {
+		return &ExitError{Code: ExitProtocolError, Err: result.Err}
+	}
+
+	switch result.StopReason {
+	case protocol.StopReasonEndTurn:
+		return nil
+	case protocol.StopReasonRefusal:
+		return &ExitError{Code: ExitRefusal, Err: errors.New("the agent refused to continue")}
+	case protocol.StopReasonMaxTokens:
+		return &ExitError{Code: ExitLimit, Err: errors.New("the agent stopped at the token limit")}
+	case protocol.StopReasonMaxTurnRequests:
+		return &ExitError{Code: ExitLimit, Err: errors.New("the agent stopped after too many model requests")}
+	case protocol.StopReasonCancelled:
+		return &ExitError{Code: ExitCancelled, Err: errors.New("the turn was cancelled")}
+	}
+	return &ExitError{Code: ExitProtocolError, Err: errors.New("unknown stop reason " + result.StopReason)}
+}
+
+// connectionExit classifies an error talking to the agent: the pipes
+// closing means the agent went away, anything else is a protocol error
+func connectionExit(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, os.ErrClosed) || errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrNotExist) {
+		return &ExitError{Code: ExitAgentCrash, Err: err}
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	return &ExitError{Code: ExitProtocolError, Err: err}
+}
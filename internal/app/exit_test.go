@@ -0,0 +1,63 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+
+	"agentgo/protocol"
+)
+
+func TestTurnExit(t *testing.T) {
+	tests := []struct {
+		result protocol.TurnResult
+		want   int
+	}{
+		{protocol.TurnResult{StopReason: protocol.StopReasonEndTurn}, ExitOK},
+		{protocol.TurnResult{StopReason: protocol.StopReasonRefusal}, ExitRefusal},
+		{protocol.TurnResult{StopReason: protocol.StopReasonMaxTokens}, ExitLimit},
+		{protocol.TurnResult{StopReason: protocol.StopReasonMaxTurnRequests}, ExitLimit},
+		{protocol.TurnResult{StopReason: protocol.StopReasonCancelled}, ExitCancelled},
+		{protocol.TurnResult{StopReason: "bored"}, ExitProtocolError},
+		{protocol.TurnResult{}, ExitProtocolError},
+		{protocol.TurnResult{Err: errors.New("overloaded")}, ExitProtocolError},
+	}
+
+	for _, tt := range tests {
+		err := turnExit(tt.result)
+		if got := ExitCode(err); got != tt.want {
+			t.Errorf("turnExit(%+v) exits with %d (%v), expected %d", tt.result, got, err, tt.want)
+		}
+	}
+}
+
+func TestConnectionExit(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitOK},
+		{io.EOF, ExitAgentCrash},
+		{io.ErrUnexpectedEOF, ExitAgentCrash},
+		{io.ErrClosedPipe, ExitAgentCrash},
+		{os.ErrClosed, ExitAgentCrash},
+		{fmt.Errorf("write: %w", syscall.EPIPE), ExitAgentCrash},
+		{fmt.Errorf("starting the agent: %w", os.ErrNotExist), ExitAgentCrash},
+		{fmt.Errorf("failed to decode response: %w", io.EOF), ExitAgentCrash},
+		{errors.New("invalid character 'x' looking for beginning of value"), ExitProtocolError},
+		{&ExitError{Code: ExitCancelled, Err: errors.New("interrupted")}, ExitCancelled},
+	}
+
+	for _, tt := range tests {
+		err := connectionExit(tt.err)
+		if got := ExitCode(err); got != tt.want {
+			t.Errorf("connectionExit(%v) exits with %d, expected %d", tt.err, got, tt.want)
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("connectionExit(%v) = %v, expected it to wrap the error", tt.err, err)
+		}
+	}
+}
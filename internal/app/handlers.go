@@ -5,26 +5,12 @@ import (
 	"agentgo/protocol"
 )
 
-type Handlers struct {
-	toolPrompt core.PermissionPrompt
-	notifier   core.Notifications
-}
-
-func NewHandlers(toolPrompt core.PermissionPrompt, notifier core.Notifications) *Handlers {
-	return &Handlers{
-		toolPrompt: toolPrompt,
-		notifier:   notifier,
-	}
-}
-
-func (h *Handlers) HandlePermissionRequest(
-	acpConn *protocol.AcpConnection,
-	raw []byte,
-	req protocol.SessionRequestPermissionRequest,
-) error {
-	return h.toolPrompt.HandlePermissionRequest(acpConn, raw, req)
-}
-
-func (h *Handlers) HandleNotification(raw []byte, req protocol.SessionUpdateRequest) error {
-	return h.notifier.HandleNotification(raw, req)
+// RegisterHandlers wires the provider's UI into the connection's method registry
+func RegisterHandlers(
+	registry *protocol.Registry,
+	toolPrompt core.PermissionPrompt,
+	notifier core.Notifications,
+) {
+	core.RegisterPermissionPrompt(registry, toolPrompt)
+	core.RegisterNotifications(registry, notifier)
 }
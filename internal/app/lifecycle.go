@@ -13,6 +13,7 @@ import (
 type LifecycleManager struct {
 	connection *protocol.AcpConnection
 	sigChan    chan os.Signal
+	cleanups   []func()
 }
 
 // NewLifecycleManager creates a new lifecycle manager
@@ -26,10 +27,19 @@ func NewLifecycleManager(connection *protocol.AcpConnection) *LifecycleManager {
 	}
 }
 
+// OnShutdown registers fn to run before the connection is closed on a signal,
+// e.g. to restore the terminal
+func (lm *LifecycleManager) OnShutdown(fn func()) {
+	lm.cleanups = append(lm.cleanups, fn)
+}
+
 // SetupGracefulShutdown sets up graceful shutdown handling in a goroutine
 func (lm *LifecycleManager) SetupGracefulShutdown() {
 	go func() {
 		<-lm.sigChan
+		for _, cleanup := range lm.cleanups {
+			cleanup()
+		}
 		fmt.Println("\nShutting down gracefully...")
 		if err := lm.connection.Close(); err != nil {
 			fmt.Printf("Error closing connection: %v\n", err)
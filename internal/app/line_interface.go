@@ -0,0 +1,460 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"agentgo/internal/attach"
+	"agentgo/internal/events"
+	"agentgo/internal/lineedit"
+	"agentgo/internal/policy"
+	"agentgo/internal/render"
+	"agentgo/internal/slash"
+	"agentgo/internal/terminal"
+	"agentgo/protocol"
+	"agentgo/providers/claude"
+)
+
+// statusInterval is how often the status above the prompt is redrawn while
+// the agent works
+const statusInterval = 100 * time.Millisecond
+
+var (
+	statusStyle = render.Style{Color: render.Cyan}
+	hintStyle   = render.Style{Color: render.Black, Bold: true}
+	askStyle    = render.Style{Color: render.Yellow, Bold: true}
+)
+
+// lineInterface runs the conversation in the terminal's normal scrolling
+// output. The editor stays available while the agent works: prompts entered
+// then are queued and sent in order as each turn ends. The editor is the only
+// reader of stdin, so answers to permission prompts are read through it too.
+type lineInterface struct {
+	connection *protocol.AcpConnection
+	output     render.Renderer
+	editor     *lineedit.Editor
+	spinner    *render.Spinner
+	prompt     *claude.Claude
+	commands   *slash.Catalog
+	meta       metaCommands
+	policy     *policy.Policy
+	transcript *transcript
+
+	// live is set when the editor draws in a terminal, so input is read
+	// while the agent works
+	live bool
+
+	// busy is set from sending a prompt until its turn ends. It is read by
+	// the editor's key handler.
+	busy  atomic.Bool
+	queue lineedit.Queue
+
+	// answerRequests receives a channel from a permission prompt waiting
+	// for the user's choice
+	answerRequests chan chan answer
+	// interrupts receives requests from the key handler to cancel the turn
+	interrupts chan interrupt
+
+	// Owned by the run loop
+	started       time.Time
+	cancelling    bool
+	pendingAnswer chan answer
+	lastStatus    []string
+	attachments   []attach.Item
+
+	// Statistics for :stats. toolCalls is counted by the notification
+	// handler; the rest by the run loop.
+	sessionStart time.Time
+	turns        int
+	prompts      int
+	working      time.Duration
+	toolCalls    atomic.Int64
+
+	// The session's current mode and model, changed by the agent and with
+	// :mode and :model
+	sessionMutex sync.Mutex
+	mode         string
+	model        string
+}
+
+// answer is a line entered for a permission prompt, or the cancellation of
+// the turn the prompt belongs to
+type answer struct {
+	text      string
+	cancelled bool
+}
+
+// interrupt cancels the running turn, sending text first once it has ended
+type interrupt struct {
+	text string
+}
+
+type lineRead struct {
+	line string
+	err  error
+}
+
+func newLineInterface(connection *protocol.AcpConnection, output render.Renderer, editor *lineedit.Editor, spinner *render.Spinner, commands *slash.Catalog, transcript *transcript, live bool) *lineInterface {
+	l := &lineInterface{
+		connection:     connection,
+		output:         output,
+		editor:         editor,
+		spinner:        spinner,
+		prompt:         claude.NewClaude(),
+		commands:       commands,
+		meta:           newMetaCommands(),
+		policy:         policy.New(),
+		transcript:     transcript,
+		live:           live,
+		answerRequests: make(chan chan answer),
+		interrupts:     make(chan interrupt, 8),
+		sessionStart:   time.Now(),
+	}
+	if modes := connection.Modes(); modes != nil {
+		l.mode = modes.CurrentModeID
+	}
+	if models := connection.Models(); models != nil {
+		l.model = models.CurrentModelID
+	}
+	commands.AddLocal(l.meta.catalog()...)
+
+	l.prompt.SetRenderer(output)
+	l.prompt.SetInput(&answerReader{requests: l.answerRequests})
+	editor.SetKeyHandler(l.handleKey)
+	editor.SetCompleter(commandCompleter{l: l})
+	return l
+}
+
+// HandlePermissionRequest answers the request by the permission rules, or
+// shows it with the Claude prompt, hiding the spinner so it does not draw
+// over the answer
+func (l *lineInterface) HandlePermissionRequest(
+	acpConn *protocol.AcpConnection,
+	raw []byte,
+	req protocol.SessionRequestPermissionRequest,
+) error {
+	if option, ok := l.policy.Decide(req.Params); ok {
+		claude.ShowPolicySelection(l.output, policy.ToolOf(req.Params.ToolCall.RawInput), option.Name)
+		return acpConn.SendToolResponse(req.ID, option.OptionID)
+	}
+
+	l.spinner.Pause()
+	defer l.spinner.Resume()
+	return l.prompt.HandlePermissionRequest(acpConn, raw, req)
+}
+
+// HandleNotification keeps the agent's command list and mode, and draws
+// other updates with the Claude provider
+func (l *lineInterface) HandleNotification(raw []byte, req protocol.SessionUpdateRequest) error {
+	switch update := req.Params.Update; update.SessionUpdateType {
+	case protocol.SessionUpdateAvailableCommands:
+		l.commands.Update(update.AvailableCommands)
+		return nil
+	case protocol.SessionUpdateCurrentMode:
+		l.modeChanged(update.CurrentModeID)
+		return nil
+	case protocol.SessionUpdateToolCall:
+		l.toolCalls.Add(1)
+	}
+	return l.prompt.HandleNotification(raw, req)
+}
+
+// run reads prompts and sends them until the user quits or the stream from
+// the agent ends
+func (l *lineInterface) run(turns <-chan protocol.TurnResult, streamErr <-chan error) error {
+	defer l.editor.Close()
+
+	grants := make(chan string)
+	reads := make(chan lineRead)
+	go func() {
+		for prompt := range grants {
+			line, err := l.editor.ReadLine(prompt)
+			reads <- lineRead{line: line, err: err}
+		}
+	}()
+	defer close(grants)
+
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+
+	reading, eof := false, false
+	for {
+		if eof && !l.busy.Load() {
+			return nil
+		}
+		// Piped input is read one line at a time as it is needed, so the
+		// answer to a permission prompt is not queued as a prompt
+		if !reading && !eof && (l.live || !l.busy.Load() || l.pendingAnswer != nil) {
+			grants <- l.promptText()
+			reading = true
+		}
+
+		select {
+		case read := <-reads:
+			reading = false
+			quit, err := l.handleLine(read)
+			if err != nil || quit {
+				return err
+			}
+			if errors.Is(read.err, io.EOF) {
+				eof = true
+			}
+		case result := <-turns:
+			if err := l.endTurn(result); err != nil {
+				return err
+			}
+		case err := <-streamErr:
+			l.spinner.Stop()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case reply := <-l.answerRequests:
+			l.pendingAnswer = reply
+		case request := <-l.interrupts:
+			if err := l.interrupt(request); err != nil {
+				return err
+			}
+		case <-ticker.C:
+		}
+
+		l.updateStatus()
+	}
+}
+
+// handleLine acts on a line the user entered, returning true to quit
+func (l *lineInterface) handleLine(read lineRead) (bool, error) {
+	switch {
+	case errors.Is(read.err, lineedit.ErrInterrupted):
+		if l.busy.Load() {
+			return false, l.interrupt(interrupt{})
+		}
+		return true, nil
+	case errors.Is(read.err, io.EOF):
+		l.answer(answer{cancelled: true})
+		return false, nil
+	case read.err != nil:
+		return false, read.err
+	}
+
+	if l.pendingAnswer != nil {
+		l.answer(answer{text: read.line})
+		return false, nil
+	}
+
+	if strings.TrimSpace(read.line) == "" {
+		return false, nil
+	}
+	l.editor.History().Add(read.line)
+
+	// Meta commands run at once, even while the agent works. Other lines
+	// starting with a colon, such as ":D thanks", are prompts.
+	if name, input, ok := slash.Parse(read.line); ok {
+		switch {
+		case l.meta.isCommand(name):
+			return l.runCommand(name, input), nil
+		case strings.EqualFold(name, slash.HelpCommand):
+			l.commands.WriteHelp(l.output)
+			return false, nil
+		}
+	}
+	if l.busy.Load() {
+		l.queue.Push(read.line)
+		return false, nil
+	}
+	l.transcript.AddPrompt(read.line)
+	return false, l.send(read.line)
+}
+
+// handleKey runs in the editor while it reads. While the agent works, Esc or
+// Ctrl-C on an empty line interrupts it, Ctrl-S interrupts it and sends the
+// text now, and Up on an empty line takes back the last queued prompt.
+func (l *lineInterface) handleKey(key terminal.Key, state *lineedit.State) bool {
+	if view := state.View(); !l.busy.Load() || view.Search != "" || view.Completions != nil {
+		return false
+	}
+
+	text := state.Text()
+	switch {
+	case key.Type == terminal.KeyEscape,
+		key.Type == terminal.KeyRune && key.Ctrl && key.Rune == 'c' && text == "":
+		l.requestInterrupt(interrupt{})
+		return true
+	case key.Type == terminal.KeyRune && key.Ctrl && key.Rune == 's' && strings.TrimSpace(text) != "":
+		l.editor.History().Add(text)
+		l.requestInterrupt(interrupt{text: text})
+		state.Reset()
+		return true
+	case key.Type == terminal.KeyUp && text == "":
+		if queued, ok := l.queue.TakeLast(); ok {
+			state.SetText(queued)
+			return true
+		}
+	}
+	return false
+}
+
+// requestInterrupt hands an interrupt to the run loop without blocking the
+// editor
+func (l *lineInterface) requestInterrupt(request interrupt) {
+	select {
+	case l.interrupts <- request:
+	default:
+	}
+}
+
+// interrupt cancels the running turn. Text to send now goes to the front of
+// the queue, so it is sent when the agent confirms the cancellation.
+func (l *lineInterface) interrupt(request interrupt) error {
+	if !l.busy.Load() {
+		if request.text == "" {
+			return nil
+		}
+		l.transcript.AddPrompt(request.text)
+		return l.send(request.text)
+	}
+
+	if request.text != "" {
+		l.queue.PushFront(request.text)
+	}
+	l.answer(answer{cancelled: true})
+	if l.cancelling {
+		return nil
+	}
+	l.cancelling = true
+	return l.connection.SendCancel()
+}
+
+// answer hands an answer to the waiting permission prompt, if there is one
+func (l *lineInterface) answer(a answer) {
+	if l.pendingAnswer == nil {
+		return
+	}
+	l.pendingAnswer <- a
+	l.pendingAnswer = nil
+}
+
+// send sends a prompt, rewriting agent commands into the form the agent
+// expects, with the files it mentions and those attached since the last one
+func (l *lineInterface) send(text string) error {
+	prompt, attached, problems := attach.Prompt(l.commands.Prompt(text), l.attachments, l.connection.PromptCapabilities())
+	l.attachments = nil
+	for _, problem := range problems {
+		l.output.Println(l.output.Paint(errorStyle, "⚠ Not attached: "+problem.Error()))
+	}
+	claude.DisplayAttachments(l.output, describe(attached))
+
+	if err := l.connection.SendPrompt(prompt); err != nil {
+		return err
+	}
+	l.prompts++
+	l.busy.Store(true)
+	l.started = time.Now()
+	l.spinner.Start("Working…")
+	return nil
+}
+
+// endTurn shows how the turn ended and sends the next queued prompt
+func (l *lineInterface) endTurn(result protocol.TurnResult) error {
+	l.spinner.Stop()
+	claude.DisplayTurnEnd(l.output, result, time.Since(l.started))
+	l.turns++
+	l.working += time.Since(l.started)
+	l.cancelling = false
+	l.answer(answer{cancelled: true})
+
+	next, ok := l.queue.Pop()
+	if !ok {
+		l.busy.Store(false)
+		return nil
+	}
+	claude.DisplayNotification(l.output, events.Event{Type: events.Prompt, Text: next})
+	return l.send(next)
+}
+
+func (l *lineInterface) promptText() string {
+	if l.pendingAnswer != nil {
+		return "? "
+	}
+	return "> "
+}
+
+// updateStatus redraws the lines above the prompt when they change
+func (l *lineInterface) updateStatus() {
+	if !l.live {
+		return
+	}
+
+	status := l.status()
+	if !slices.Equal(status, l.lastStatus) {
+		l.editor.SetStatus(status)
+		l.lastStatus = status
+	}
+	l.editor.SetPrompt(l.promptText())
+}
+
+// status lists what the agent is doing and the queued prompts
+func (l *lineInterface) status() []string {
+	if !l.busy.Load() {
+		return nil
+	}
+
+	capabilities := l.output.Capabilities()
+	var lines []string
+	switch {
+	case l.pendingAnswer != nil:
+		lines = append(lines, l.output.Paint(askStyle, "❓ Answer the permission prompt above"))
+	case l.cancelling:
+		lines = append(lines, l.output.Paint(statusStyle, render.SpinnerText("Interrupting…", time.Since(l.started), capabilities.Unicode)))
+	default:
+		lines = append(lines, l.output.Paint(statusStyle, render.SpinnerText("Working…", time.Since(l.started), capabilities.Unicode))+
+			l.output.Paint(hintStyle, "  Esc to interrupt · Enter to queue"))
+	}
+
+	queued := l.queue.Items()
+	for _, line := range lineedit.QueueLines(queued, capabilities.Width-1) {
+		lines = append(lines, l.output.Paint(hintStyle, line))
+	}
+	if len(queued) > 0 {
+		lines = append(lines, l.output.Paint(hintStyle, "  ↑ edit last queued · Ctrl-S interrupt and send now"))
+	}
+	return lines
+}
+
+// describe lists attached items for DisplayAttachments
+func describe(items []attach.Item) []string {
+	descriptions := make([]string, 0, len(items))
+	for _, item := range items {
+		descriptions = append(descriptions, item.Describe())
+	}
+	return descriptions
+}
+
+// answerReader is the input of the Claude permission prompt. Each line is
+// requested from the run loop, which answers with the next line the user
+// enters.
+type answerReader struct {
+	requests chan<- chan answer
+	pending  []byte
+}
+
+func (r *answerReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		reply := make(chan answer, 1)
+		r.requests <- reply
+		a := <-reply
+		if a.cancelled {
+			return 0, claude.ErrPromptCancelled
+		}
+		r.pending = []byte(a.text + "\n")
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,374 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"agentgo/internal/attach"
+	"agentgo/internal/lineedit"
+	"agentgo/internal/policy"
+	"agentgo/internal/render"
+	"agentgo/protocol"
+)
+
+var (
+	headingStyle = render.Style{Bold: true}
+	nameStyle    = render.Style{Color: render.Cyan}
+)
+
+// newMetaCommands lists the commands of the line interface, in the order
+// help shows them
+func newMetaCommands() metaCommands {
+	return metaCommands{
+		{
+			name: "help", args: "[command]", maxArgs: 1,
+			description: "List the commands, or describe one",
+			complete:    completeCommandNames,
+			run:         (*lineInterface).help,
+		},
+		{
+			name: "quit", aliases: []string{"q", "exit"},
+			description: "End the session",
+			run:         func(*lineInterface, []string) error { return errQuit },
+		},
+		{
+			name:        "cancel",
+			description: "Interrupt the agent and drop the queued prompts",
+			run:         (*lineInterface).cancel,
+		},
+		{
+			name: "save", args: "<file>", minArgs: 1, maxArgs: 1,
+			description: "Save the transcript as plain text",
+			complete:    completePath,
+			run: func(l *lineInterface, args []string) error {
+				if err := l.transcript.Save(args[0]); err != nil {
+					return err
+				}
+				l.output.Printf("Transcript saved to %s\n", args[0])
+				return nil
+			},
+		},
+		{
+			name: "policy", args: "[tool [ask|allow|reject]]", maxArgs: 2,
+			description: "Show the permission rules, or change the rule for a tool",
+			complete:    completePolicy,
+			run:         (*lineInterface).setPolicy,
+		},
+		{
+			name: "mode", args: "[mode]", maxArgs: 1,
+			description: "List the agent's modes, or switch to one",
+			complete:    completeModes,
+			run:         (*lineInterface).switchMode,
+		},
+		{
+			name: "model", args: "[model]", maxArgs: 1,
+			description: "List the agent's models, or switch to one",
+			complete:    completeModels,
+			run:         (*lineInterface).switchModel,
+		},
+		{
+			name: "attach", args: "[path...]", maxArgs: -1,
+			description: "Attach files, directories or images to the next prompt",
+			complete:    completePath,
+			run:         (*lineInterface).attach,
+		},
+		{
+			name:        "stats",
+			description: "Show statistics about the session",
+			run:         (*lineInterface).stats,
+		},
+		{
+			name:        "clear",
+			description: "Clear the screen",
+			run: func(l *lineInterface, _ []string) error {
+				if !l.live {
+					return fmt.Errorf("the screen can only be cleared in a terminal")
+				}
+				l.editor.Clear()
+				return nil
+			},
+		},
+	}
+}
+
+// help lists every command, or describes one
+func (l *lineInterface) help(args []string) error {
+	if len(args) == 0 {
+		l.commands.WriteHelp(l.output)
+		return nil
+	}
+
+	if command, ok := l.meta.lookup(args[0]); ok {
+		l.output.Println(l.output.Paint(nameStyle, command.usage()))
+		l.output.Println("  " + command.description)
+		if len(command.aliases) > 0 {
+			l.output.Println("  Also: :" + strings.Join(command.aliases, ", :"))
+		}
+		return nil
+	}
+	if command, ok := l.commands.Lookup("/" + strings.TrimPrefix(args[0], "/")); ok {
+		usage := command.Name
+		if command.Hint != "" {
+			usage += " <" + command.Hint + ">"
+		}
+		l.output.Println(l.output.Paint(nameStyle, usage))
+		l.output.Println("  " + command.Description)
+		return nil
+	}
+	return fmt.Errorf("unknown command %s", args[0])
+}
+
+func completeCommandNames(l *lineInterface, _ []string, _ string) []lineedit.Completion {
+	var completions []lineedit.Completion
+	for _, command := range l.meta {
+		completions = append(completions, lineedit.Completion{Text: command.name, Description: command.description})
+	}
+	return completions
+}
+
+// cancel interrupts the turn, dropping the prompts queued behind it
+func (l *lineInterface) cancel([]string) error {
+	if !l.busy.Load() {
+		l.output.Println("The agent is not working")
+		return nil
+	}
+	if dropped := l.queue.Clear(); dropped > 0 {
+		l.output.Printf("Dropped %d queued prompt(s)\n", dropped)
+	}
+	return l.interrupt(interrupt{})
+}
+
+// setPolicy lists the rules, toggles a tool between asking and allowing, or
+// sets its rule
+func (l *lineInterface) setPolicy(args []string) error {
+	if len(args) == 0 {
+		l.output.Println(l.output.Paint(headingStyle, "Permission rules:"))
+		for _, tool := range policy.Tools {
+			l.output.Printf("  %-6s %s\n", tool, l.policy.Rule(tool))
+		}
+		return nil
+	}
+
+	tool := strings.ToLower(args[0])
+	decision := policy.Allow
+	if len(args) == 2 {
+		var err error
+		if decision, err = policy.ParseDecision(args[1]); err != nil {
+			return err
+		}
+	} else if l.policy.Rule(tool) != policy.Ask {
+		decision = policy.Ask
+	}
+
+	if err := l.policy.Set(tool, decision); err != nil {
+		return err
+	}
+	l.output.Printf("Permission rule: %s → %s\n", tool, decision)
+	return nil
+}
+
+func completePolicy(_ *lineInterface, args []string, _ string) []lineedit.Completion {
+	if len(args) == 0 {
+		return wordCompletions(policy.Tools...)
+	}
+	words := make([]string, 0, len(policy.Decisions))
+	for _, decision := range policy.Decisions {
+		words = append(words, string(decision))
+	}
+	return wordCompletions(words...)
+}
+
+// switchMode lists the modes, or asks the agent to switch. The agent's
+// answer is awaited in the background so the prompt stays responsive.
+func (l *lineInterface) switchMode(args []string) error {
+	modes := l.connection.Modes()
+	if modes == nil || len(modes.AvailableModes) == 0 {
+		return fmt.Errorf("the agent does not offer modes")
+	}
+
+	current := l.currentMode()
+	if len(args) == 0 {
+		l.output.Println(l.output.Paint(headingStyle, "Modes:"))
+		width := 0
+		for _, mode := range modes.AvailableModes {
+			width = max(width, render.StringWidth(mode.ID))
+		}
+		for _, mode := range modes.AvailableModes {
+			l.writeChoice(mode.ID, width, mode.Name, mode.Description, mode.ID == current)
+		}
+		return nil
+	}
+
+	id := args[0]
+	if !slices.ContainsFunc(modes.AvailableModes, func(mode protocol.SessionMode) bool { return mode.ID == id }) {
+		return fmt.Errorf("unknown mode %s, type :mode to list them", id)
+	}
+	go func() {
+		if err := l.connection.SetMode(id); err != nil {
+			l.output.Println(l.output.Paint(errorStyle, "⚠ "+err.Error()))
+			return
+		}
+		l.modeChanged(id)
+	}()
+	return nil
+}
+
+func completeModes(l *lineInterface, _ []string, _ string) []lineedit.Completion {
+	modes := l.connection.Modes()
+	if modes == nil {
+		return nil
+	}
+	var completions []lineedit.Completion
+	for _, mode := range modes.AvailableModes {
+		completions = append(completions, lineedit.Completion{Text: mode.ID, Description: mode.Name})
+	}
+	return completions
+}
+
+// switchModel lists the models, or asks the agent to switch
+func (l *lineInterface) switchModel(args []string) error {
+	models := l.connection.Models()
+	if models == nil || len(models.AvailableModels) == 0 {
+		return fmt.Errorf("the agent does not offer models")
+	}
+
+	current := l.currentModel()
+	if len(args) == 0 {
+		l.output.Println(l.output.Paint(headingStyle, "Models:"))
+		width := 0
+		for _, model := range models.AvailableModels {
+			width = max(width, render.StringWidth(model.ModelID))
+		}
+		for _, model := range models.AvailableModels {
+			l.writeChoice(model.ModelID, width, model.Name, model.Description, model.ModelID == current)
+		}
+		return nil
+	}
+
+	id := args[0]
+	if !slices.ContainsFunc(models.AvailableModels, func(model protocol.ModelInfo) bool { return model.ModelID == id }) {
+		return fmt.Errorf("unknown model %s, type :model to list them", id)
+	}
+	go func() {
+		if err := l.connection.SetModel(id); err != nil {
+			l.output.Println(l.output.Paint(errorStyle, "⚠ "+err.Error()))
+			return
+		}
+		l.sessionMutex.Lock()
+		l.model = id
+		l.sessionMutex.Unlock()
+		l.output.Printf("Model: %s\n", id)
+	}()
+	return nil
+}
+
+func completeModels(l *lineInterface, _ []string, _ string) []lineedit.Completion {
+	models := l.connection.Models()
+	if models == nil {
+		return nil
+	}
+	var completions []lineedit.Completion
+	for _, model := range models.AvailableModels {
+		completions = append(completions, lineedit.Completion{Text: model.ModelID, Description: model.Name})
+	}
+	return completions
+}
+
+// writeChoice lists a mode or model, marking the current one. IDs are
+// padded to width so the names line up.
+func (l *lineInterface) writeChoice(id string, width int, name, description string, current bool) {
+	marker := "  "
+	if current {
+		marker = "* "
+	}
+	line := marker + l.output.Paint(nameStyle, id) + strings.Repeat(" ", width-render.StringWidth(id))
+	if name != "" {
+		line += "  " + name
+	}
+	if description != "" {
+		line += l.output.Paint(hintStyle, " - "+description)
+	}
+	l.output.Println(line)
+}
+
+func (l *lineInterface) currentMode() string {
+	l.sessionMutex.Lock()
+	defer l.sessionMutex.Unlock()
+	return l.mode
+}
+
+func (l *lineInterface) currentModel() string {
+	l.sessionMutex.Lock()
+	defer l.sessionMutex.Unlock()
+	return l.model
+}
+
+// modeChanged records the session's mode, announcing it when it differs
+// from the last one seen. Both the agent's current_mode_update and the
+// answer to :mode report a switch, so it is announced once.
+func (l *lineInterface) modeChanged(id string) {
+	l.sessionMutex.Lock()
+	changed := l.mode != id
+	l.mode = id
+	l.sessionMutex.Unlock()
+
+	if changed {
+		l.output.Printf("Mode: %s\n", id)
+	}
+}
+
+// attach stages files to send with the next prompt, or lists those staged
+func (l *lineInterface) attach(args []string) error {
+	if len(args) == 0 {
+		if len(l.attachments) == 0 {
+			l.output.Println("No files attached")
+		}
+		for _, item := range l.attachments {
+			l.output.Println("📎 " + item.Describe())
+		}
+		return nil
+	}
+
+	for _, path := range args {
+		items, err := attach.Resolve(path, l.connection.PromptCapabilities())
+		if err != nil {
+			return err
+		}
+		l.attachments = append(l.attachments, items...)
+		for _, item := range items {
+			l.output.Println("📎 " + item.Describe())
+		}
+	}
+	l.output.Println(l.output.Paint(hintStyle, "Sent with the next prompt"))
+	return nil
+}
+
+// stats shows what has happened in the session so far
+func (l *lineInterface) stats([]string) error {
+	working := l.working
+	if l.busy.Load() {
+		working += time.Since(l.started)
+	}
+
+	rows := [][2]string{
+		{"Session", l.connection.SessionID()},
+		{"Mode", l.currentMode()},
+		{"Model", l.currentModel()},
+		{"Turns", fmt.Sprintf("%d, %s working", l.turns, working.Round(time.Second))},
+		{"Prompts", fmt.Sprintf("%d sent, %d queued", l.prompts, l.queue.Len())},
+		{"Tool calls", fmt.Sprint(l.toolCalls.Load())},
+		{"Attached", fmt.Sprintf("%d file(s)", len(l.attachments))},
+		{"Uptime", time.Since(l.sessionStart).Round(time.Second).String()},
+	}
+
+	l.output.Println(l.output.Paint(headingStyle, "Session:"))
+	for _, row := range rows {
+		if row[1] == "" {
+			continue
+		}
+		l.output.Printf("  %-10s  %s\n", row[0], row[1])
+	}
+	return nil
+}
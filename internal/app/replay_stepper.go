@@ -0,0 +1,148 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"agentgo/internal/render"
+	"agentgo/protocol"
+	"agentgo/providers/claude"
+)
+
+const replayStepperHelp = `Replay commands:
+  <enter>, n   deliver the next message
+  g N          go to message N (skipped messages are not rendered)
+  t            go to the next tool call
+  p            go to the next permission request
+  r            show the raw JSON of the current message
+  c            deliver all remaining messages
+  q            quit
+`
+
+// runReplayStepper lets the user step through a recording one agent message
+// at a time, reading commands from in. Anything else reading the user's
+// input while stepping must share in, so neither buffers lines meant for
+// the other.
+func runReplayStepper(stepper *protocol.ReplayStepper, in *bufio.Reader, out render.Renderer) error {
+	// Prompt responses end a turn; nobody waits on it while stepping
+	turns := make(chan protocol.TurnResult, 1)
+
+	fmt.Fprintf(out, "Stepping through %d recorded messages. Type ? for help.\n", stepper.Len())
+
+	for {
+		fmt.Fprintf(out, "replay %d/%d> ", stepper.Position(), stepper.Len())
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		command, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch command {
+		case "", "n":
+			if err := stepOnce(stepper, turns, out); err != nil {
+				return err
+			}
+		case "g":
+			n, convErr := strconv.Atoi(strings.TrimSpace(arg))
+			if convErr != nil {
+				fmt.Fprintln(out, "usage: g N")
+				continue
+			}
+			if seekErr := stepper.Seek(n - 1); seekErr != nil {
+				fmt.Fprintln(out, seekErr)
+			}
+		case "t":
+			seekTo(stepper, protocol.IsToolCall, "tool call", out)
+		case "p":
+			seekTo(stepper, protocol.IsPermissionRequest, "permission request", out)
+		case "r":
+			showRaw(stepper.Current(), out)
+		case "c":
+			for !stepper.Done() {
+				if err := stepOnce(stepper, turns, out); err != nil {
+					return err
+				}
+			}
+		case "q":
+			return nil
+		default:
+			fmt.Fprint(out, replayStepperHelp)
+		}
+	}
+}
+
+// newStepPrompt draws the agent messages stepped to. Permission requests
+// among them read their answers from in, the reader the stepper's commands
+// come from.
+func newStepPrompt(output render.Renderer, in *bufio.Reader) *claude.Claude {
+	prompt := claude.NewClaude()
+	prompt.SetRenderer(output)
+	prompt.SetInput(in)
+	return prompt
+}
+
+func stepOnce(stepper *protocol.ReplayStepper, turns chan protocol.TurnResult, out render.Renderer) error {
+	if stepper.Done() {
+		fmt.Fprintln(out, "End of recording.")
+		return nil
+	}
+
+	// The header goes above the message it describes
+	rule := "──"
+	if !out.Capabilities().Unicode {
+		rule = "--"
+	}
+	out.Println(out.Paint(hintStyle, fmt.Sprintf("%s message %d/%d: %s %s", rule, stepper.Position()+1, stepper.Len(), describeMessage(stepper.Next()), rule)))
+
+	_, err := stepper.Step(turns)
+	select {
+	case <-turns:
+	default:
+	}
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func seekTo(stepper *protocol.ReplayStepper, match func(*protocol.Message) bool, name string, out io.Writer) {
+	if !stepper.SeekNext(match) {
+		fmt.Fprintf(out, "No further %s.\n", name)
+		return
+	}
+	fmt.Fprintf(out, "Next %s is message %d.\n", name, stepper.Position()+1)
+}
+
+func showRaw(message *protocol.Message, out io.Writer) {
+	if message == nil {
+		fmt.Fprintln(out, "No message delivered yet.")
+		return
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, message.Raw, "", "  "); err != nil {
+		fmt.Fprintln(out, string(message.Raw))
+		return
+	}
+	fmt.Fprintln(out, pretty.String())
+}
+
+func describeMessage(message *protocol.Message) string {
+	switch {
+	case message.IsResponse():
+		return "response"
+	case message.UpdateType() != "":
+		return fmt.Sprintf("%s (%s)", message.Method, message.UpdateType())
+	default:
+		return message.Method
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agentgo/internal/render"
+	"agentgo/protocol"
+)
+
+func TestRunReplayStepper_AnswersPermissionRequest(t *testing.T) {
+	recording := filepath.Join(t.TempDir(), "permission.jsonl")
+	lines := []string{
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{"sessionId":"s1","update":{"sessionUpdate":"agent_message_chunk","content":{"type":"text","text":"Editing the file."}}}}}`,
+		`{"timestamp":"2025-01-01T00:00:01Z","direction":"in","data":{"jsonrpc":"2.0","id":7,"method":"session/request_permission","params":{"sessionId":"s1","toolCall":{"toolCallId":"t1","rawInput":{"file_path":"/a.go","old_string":"a","new_string":"b"}},"options":[{"optionId":"allow","name":"Allow","kind":"allow_once"},{"optionId":"reject","name":"Reject","kind":"reject_once"}]}}}`,
+		`{"timestamp":"2025-01-01T00:00:02Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{"sessionId":"s1","update":{"sessionUpdate":"agent_message_chunk","content":{"type":"text","text":"Done."}}}}}`,
+	}
+	if err := os.WriteFile(recording, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := protocol.OpenAcpReplayConnection(recording, protocol.ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stepper, err := protocol.NewReplayStepper(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The prompt's answer is read between the stepper's commands
+	var output bytes.Buffer
+	renderer := render.NewTextRenderer(&output, render.PlainText)
+	in := bufio.NewReader(strings.NewReader("n\np\nn\n1\nn\nq\nnot read\n"))
+	prompt := newStepPrompt(renderer, in)
+	RegisterHandlers(conn.Registry(), prompt, prompt)
+
+	done := make(chan error, 1)
+	go func() { done <- runReplayStepper(stepper, in, renderer) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stepping through the permission request did not finish")
+	}
+
+	text := output.String()
+	for _, want := range []string{"Editing the file.", "message 2/3: session/request_permission", "Selected: Allow", "Done.", "message 3/3"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the output to contain %q, got:\n%s", want, text)
+		}
+	}
+	// Each header comes before the message it describes
+	for _, pair := range [][2]string{{"message 1/3", "Editing the file."}, {"message 2/3", "Tool Request"}, {"message 3/3", "Done."}} {
+		if header, message := strings.Index(text, pair[0]), strings.Index(text, pair[1]); header < 0 || message < header {
+			t.Errorf("Expected %q before %q, got:\n%s", pair[0], pair[1], text)
+		}
+	}
+	if rest, _ := in.ReadString('\n'); rest != "not read\n" {
+		t.Errorf("Expected the input after q to be left unread, got %q", rest)
+	}
+}
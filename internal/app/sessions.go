@@ -0,0 +1,133 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"agentgo/protocol"
+)
+
+// recordingExtension is the extension of recordings
+const recordingExtension = ".jsonl"
+
+// sessionsDir is where recordings made without a file name are kept:
+// $XDG_STATE_HOME/agentgo/sessions, or ~/.local/state/agentgo/sessions
+func sessionsDir() (string, error) {
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateDir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateDir, "agentgo", "sessions"), nil
+}
+
+// newSessionFile names the recording of a session starting now
+func newSessionFile() (string, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, time.Now().Format("20060102-150405")+recordingExtension), nil
+}
+
+// resolveRecording finds a recording given as a path, or by the name
+// agentgo sessions lists it under
+func resolveRecording(name string) (string, error) {
+	if _, err := os.Stat(name); err == nil || strings.ContainsRune(name, filepath.Separator) {
+		return name, err
+	}
+
+	dir, err := sessionsDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, strings.TrimSuffix(name, recordingExtension)+recordingExtension)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("no recording %s, run agentgo sessions to list them", name)
+	}
+	return path, nil
+}
+
+// session is a recorded session, as listed by agentgo sessions
+type session struct {
+	name      string
+	started   time.Time
+	sessionID string
+	cwd       string
+}
+
+// listSessions reads the recordings in dir, newest first
+func listSessions(dir string) ([]session, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []session
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != recordingExtension {
+			continue
+		}
+		s := session{name: strings.TrimSuffix(entry.Name(), recordingExtension)}
+		header, err := protocol.ReadRecordingHeader(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if header != nil {
+			s.started, s.sessionID, s.cwd = header.StartedAt, header.SessionID, header.Cwd
+		} else if info, err := entry.Info(); err == nil {
+			s.started = info.ModTime()
+		}
+		sessions = append(sessions, s)
+	}
+
+	slices.SortFunc(sessions, func(a, b session) int { return b.started.Compare(a.started) })
+	return sessions, nil
+}
+
+// writeSessions lists the recorded sessions
+func writeSessions(w io.Writer) error {
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	sessions, err := listSessions(dir)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintf(w, "No recorded sessions in %s\nRecord one with agentgo record\n", dir)
+		return nil
+	}
+
+	width := len("NAME")
+	for _, s := range sessions {
+		width = max(width, len(s.name))
+	}
+	fmt.Fprintf(w, "%-*s  %-16s  %-36s  %s\n", width, "NAME", "STARTED", "SESSION", "DIRECTORY")
+	for _, s := range sessions {
+		fmt.Fprintf(w, "%-*s  %-16s  %-36s  %s\n", width, s.name, s.started.Local().Format("2006-01-02 15:04"), orDash(s.sessionID), orDash(s.cwd))
+	}
+	return nil
+}
+
+func orDash(text string) string {
+	if text == "" {
+		return "-"
+	}
+	return text
+}
@@ -0,0 +1,315 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"agentgo/internal/cli"
+	"agentgo/internal/policy"
+	"agentgo/protocol"
+)
+
+// Program is agentgo's command line
+func Program() *cli.Program {
+	return &cli.Program{
+		Name:    "agentgo",
+		Summary: "a terminal client for agents that speak the Agent Client Protocol",
+		Default: "chat",
+		Commands: []*cli.Command{
+			chatCommand(),
+			execCommand(),
+			replayCommand(),
+			recordCommand(),
+			sessionsCommand(),
+			policyCommand(),
+		},
+		Defaults: cli.Globals{
+			Agent:     DefaultAgent,
+			Verbosity: cli.Normal,
+			Color:     "auto",
+		},
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// decisionWords completes permission rules
+var decisionWords = func() []string {
+	var words []string
+	for _, tool := range policy.Tools {
+		for _, decision := range policy.Decisions {
+			words = append(words, tool+"="+string(decision))
+		}
+	}
+	return words
+}()
+
+// defineSessionFlags adds the flags of the commands that talk to the agent
+// interactively
+func defineSessionFlags(flags *flag.FlagSet, config *Config) {
+	flags.BoolVar(&config.TUI, "tui", false, "Use the full-screen interface when running in a terminal")
+	flags.IntVar(&config.MaxMessageSize, "max-message-size", protocol.DefaultMaxMessageSize, "Maximum size in bytes of a single agent message")
+	flags.StringVar(&config.Policy, "policy", "", "Permission rules for the line interface, such as bash=allow,edit=allow")
+}
+
+// noArgs rejects arguments given to a command that takes none
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	return nil
+}
+
+// runChat runs an interactive session
+func runChat(config *Config) error {
+	coordinator, err := NewCoordinator(config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer coordinator.Close()
+	return coordinator.Run()
+}
+
+func chatCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "chat",
+		Summary: "Talk with the agent",
+		Description: `Starts an interactive session with the agent. Type a prompt to send it,
+/ for the agent's commands and : for agentgo's own, such as :help.
+
+-replay and the -replay-* flags are deprecated: they run agentgo replay,
+which takes the same flags without the prefix.`,
+		Complete: map[string]cli.Values{
+			"record": {Files: true},
+			"replay": {Files: true},
+			"policy": {Words: decisionWords},
+		},
+		Setup: func(flags *flag.FlagSet) cli.Action {
+			config := &Config{}
+			defineSessionFlags(flags, config)
+			flags.StringVar(&config.RecordFile, "record", "", "Record conversation to file")
+			replay := flags.String("replay", "", "Deprecated: use agentgo replay <recording>")
+			speed := defineReplayFlags(flags, config, "replay-")
+			return func(globals *cli.Globals, args []string) error {
+				if err := noArgs(args); err != nil {
+					return err
+				}
+				if *replay != "" {
+					fmt.Fprintln(os.Stderr, "Warning: -replay is deprecated, use agentgo replay <recording>")
+					return runReplay(config, globals, *replay, *speed)
+				}
+				config.setGlobals(globals)
+				return runChat(config)
+			}
+		},
+	}
+}
+
+func execCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "exec",
+		Args:    "[prompt...]",
+		Summary: "Send one prompt and print the answer",
+		Description: `Sends one prompt and prints the agent's answer. Input piped to agentgo
+is attached to the prompt. Permission requests no -policy rule decides
+are rejected, or asked on the terminal with -ask.
+
+Exit codes: 0 the turn ended, 1 usage or other error, 2 the agent
+refused, 3 token or request limit, 4 cancelled, 5 the agent crashed,
+6 protocol error.`,
+		Complete: map[string]cli.Values{
+			"f":      {Files: true},
+			"record": {Files: true},
+			"policy": {Words: decisionWords},
+			"output": {Words: []string{OutputText, OutputJSON, OutputStreamJSON}},
+		},
+		Setup: func(flags *flag.FlagSet) cli.Action {
+			config := &ExecConfig{}
+			flags.StringVar(&config.PromptFile, "f", "", "Read the prompt from a file")
+			flags.StringVar(&config.RecordFile, "record", "", "Record conversation to file")
+			flags.IntVar(&config.MaxMessageSize, "max-message-size", protocol.DefaultMaxMessageSize, "Maximum size in bytes of a single agent message")
+			flags.StringVar(&config.Policy, "policy", "", "Permission rules, such as bash=allow,edit=allow")
+			flags.BoolVar(&config.Ask, "ask", false, "Ask on the terminal about permission requests no rule decides, instead of rejecting them")
+			flags.StringVar(&config.Output, "output", OutputText, "Output format: text, json or stream-json")
+			return func(globals *cli.Globals, args []string) error {
+				config.setGlobals(globals)
+				config.Prompt = strings.Join(args, " ")
+				return Exec(config)
+			}
+		},
+	}
+}
+
+func replayCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "replay",
+		Args:    "<recording>",
+		Summary: "Replay a recorded session",
+		Description: `Replays a recording made with agentgo record or -record, as if the
+agent were answering again. The recording is a file, or a name listed
+by agentgo sessions.`,
+		Complete: map[string]cli.Values{
+			"": {Files: true},
+		},
+		Setup: func(flags *flag.FlagSet) cli.Action {
+			config := &Config{}
+			flags.BoolVar(&config.TUI, "tui", false, "Use the full-screen interface when running in a terminal")
+			flags.IntVar(&config.MaxMessageSize, "max-message-size", protocol.DefaultMaxMessageSize, "Maximum size in bytes of a single agent message")
+			speed := defineReplayFlags(flags, config, "")
+			return func(globals *cli.Globals, args []string) error {
+				if len(args) != 1 {
+					return fmt.Errorf("replay takes one recording, run agentgo sessions to list them")
+				}
+				return runReplay(config, globals, args[0], *speed)
+			}
+		},
+	}
+}
+
+// defineReplayFlags adds the flags that control a replay and returns the
+// speed flag. With a prefix they are chat's deprecated aliases of them.
+func defineReplayFlags(flags *flag.FlagSet, config *Config, prefix string) *string {
+	usage := func(name, text string) (string, string) {
+		if prefix == "" {
+			return name, text
+		}
+		return prefix + name, "Deprecated: use agentgo replay -" + name
+	}
+
+	name, text := usage("speed", "Replay speed multiplier, e.g. 0.5, 2 or max")
+	speed := flags.String(name, "1", text)
+	name, text = usage("max-gap", "Cap on the pause between replayed messages, e.g. 2s (0 for no cap)")
+	flags.DurationVar(&config.Replay.MaxGap, name, 0, text)
+	name, text = usage("strict", "Fail when a message we send differs from the recording")
+	flags.BoolVar(&config.Replay.Strict, name, false, text)
+	name, text = usage("step", "Step through the replay one message at a time")
+	flags.BoolVar(&config.ReplayStep, name, false, text)
+	return speed
+}
+
+// runReplay replays a recording, named as agentgo sessions lists it or by
+// its file
+func runReplay(config *Config, globals *cli.Globals, recording, speed string) error {
+	var err error
+	if config.Replay.Speed, err = protocol.ParseReplaySpeed(speed); err != nil {
+		return err
+	}
+	if config.ReplayFile, err = resolveRecording(recording); err != nil {
+		return err
+	}
+	config.setGlobals(globals)
+	return runChat(config)
+}
+
+func recordCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "record",
+		Args:    "[file]",
+		Summary: "Talk with the agent, recording the session",
+		Description: `Starts an interactive session like agentgo chat, recording every
+message to file. Without a file the recording is kept with the other
+sessions, where agentgo sessions lists it and agentgo replay finds it
+by name.`,
+		Complete: map[string]cli.Values{
+			"":       {Files: true},
+			"policy": {Words: decisionWords},
+		},
+		Setup: func(flags *flag.FlagSet) cli.Action {
+			config := &Config{}
+			defineSessionFlags(flags, config)
+			return func(globals *cli.Globals, args []string) error {
+				switch len(args) {
+				case 0:
+					var err error
+					if config.RecordFile, err = newSessionFile(); err != nil {
+						return err
+					}
+				case 1:
+					config.RecordFile = args[0]
+				default:
+					return noArgs(args[1:])
+				}
+				config.setGlobals(globals)
+				return runChat(config)
+			}
+		},
+	}
+}
+
+func sessionsCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "sessions",
+		Summary: "List the recorded sessions",
+		Description: `Lists the sessions recorded with agentgo record, newest first. Replay
+one with agentgo replay <name>.`,
+		Setup: func(*flag.FlagSet) cli.Action {
+			return func(_ *cli.Globals, args []string) error {
+				if err := noArgs(args); err != nil {
+					return err
+				}
+				return writeSessions(os.Stdout)
+			}
+		},
+	}
+}
+
+func policyCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "policy",
+		Args:    "[rules]",
+		Summary: "Check permission rules",
+		Description: `Shows what each kind of tool gets under the permission rules given, or
+under those set with policy in the config file. Rules are written as
+tool=decision, separated by commas, where tool is bash, edit, write or
+other and decision is ask, allow or reject.`,
+		Complete: map[string]cli.Values{
+			"": {Words: decisionWords},
+		},
+		Setup: func(*flag.FlagSet) cli.Action {
+			return func(globals *cli.Globals, args []string) error {
+				return writePolicies(os.Stdout, globals.Settings, args)
+			}
+		},
+	}
+}
+
+// policySections are the config file sections that can set rules
+var policySections = []string{"chat", "record", "exec"}
+
+// writePolicies shows the decision for each tool under the rules given,
+// or under the rules in the config file
+func writePolicies(w io.Writer, settings cli.Settings, args []string) error {
+	var sources [][2]string
+	if len(args) > 0 {
+		sources = append(sources, [2]string{"Rules", strings.Join(args, ",")})
+	} else {
+		for _, section := range policySections {
+			if rules := settings.Get(section, "policy"); rules != "" {
+				sources = append(sources, [2]string{"[" + section + "] in the config file", rules})
+			}
+		}
+	}
+	if len(sources) == 0 {
+		fmt.Fprintln(w, "No permission rules are configured, so every request is asked about.")
+		fmt.Fprintln(w, "Set them with -policy, or with policy in the [chat] or [exec] section of the config file.")
+		return nil
+	}
+
+	for i, source := range sources {
+		rules, err := policy.Parse(source[1])
+		if err != nil {
+			return fmt.Errorf("%s: %w", source[0], err)
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s:\n", source[0])
+		for _, tool := range policy.Tools {
+			fmt.Fprintf(w, "  %-6s %s\n", tool, rules.Rule(tool))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChat_ReplayFlagRunsReplay(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-replay", "missing"}, "no recording missing"},
+		{[]string{"chat", "-replay", "missing", "-replay-speed", "fast"}, "invalid replay speed"},
+		{[]string{"replay", "missing"}, "no recording missing"},
+		{[]string{"replay", "-speed", "fast", "missing"}, "invalid replay speed"},
+	}
+
+	for _, tt := range tests {
+		err := Program().Run(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.args, tt.want, err)
+		}
+	}
+}
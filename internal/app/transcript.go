@@ -0,0 +1,41 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"agentgo/internal/render"
+)
+
+// transcript keeps a plain-text copy of the conversation for :save. Output
+// is written to it with the styling removed, and prompts are added as the
+// user sends them, since the editor draws those itself.
+type transcript struct {
+	mutex sync.Mutex
+	text  strings.Builder
+}
+
+func (t *transcript) Write(p []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.text.WriteString(render.StripEscapes(string(p)))
+	return len(p), nil
+}
+
+// AddPrompt records a prompt the user sent
+func (t *transcript) AddPrompt(text string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, line := range strings.Split(text, "\n") {
+		t.text.WriteString("> " + line + "\n")
+	}
+	t.text.WriteString("\n")
+}
+
+// Save writes the transcript so far to a file
+func (t *transcript) Save(path string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return os.WriteFile(path, []byte(t.text.String()), 0o644)
+}
@@ -0,0 +1,332 @@
+// Package attach turns files, directories and images into the content
+// blocks of a prompt. Each file is sent in the richest form the agent's
+// prompt capabilities allow: text is embedded as a resource, images as
+// base64 image blocks, and anything else, or too large, as a resource link.
+package attach
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"agentgo/protocol"
+)
+
+const (
+	// MaxEmbedSize is the largest text file embedded in a prompt; larger
+	// ones are sent as links
+	MaxEmbedSize = 256 << 10
+	// MaxImageSize is the largest image sent inline
+	MaxImageSize = 5 << 20
+	// MaxDirectoryFiles is the most files attached from one directory
+	MaxDirectoryFiles = 50
+)
+
+// Kind is how an attached file is sent
+type Kind string
+
+const (
+	Embedded Kind = "embedded"
+	Image    Kind = "image"
+	Link     Kind = "link"
+)
+
+// Item is a file to attach to a prompt
+type Item struct {
+	// Path is the absolute path of the file
+	Path string
+	// Name is the path as the user gave it, for display
+	Name     string
+	MimeType string
+	Size     int64
+	Kind     Kind
+}
+
+// Resolve finds the items to attach for a path. A directory gives the files
+// in it and its subdirectories, skipping hidden ones.
+func Resolve(path string, capabilities protocol.PromptCapabilities) ([]Item, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a file", path)
+		}
+		item, err := newItem(abs, path, info.Size(), capabilities)
+		if err != nil {
+			return nil, err
+		}
+		return []Item{item}, nil
+	}
+
+	var items []Item
+	err = filepath.WalkDir(abs, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if file != abs && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if len(items) == MaxDirectoryFiles {
+			return fmt.Errorf("%s has more than %d files, attach a narrower directory", path, MaxDirectoryFiles)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(abs, file)
+		item, err := newItem(file, filepath.Join(path, rel), info.Size(), capabilities)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s has no files to attach", path)
+	}
+	return items, nil
+}
+
+// newItem decides how a file is sent, from its type, its size and what the
+// agent accepts
+func newItem(abs, name string, size int64, capabilities protocol.PromptCapabilities) (Item, error) {
+	mimeType, text, err := detectType(abs)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item := Item{Path: abs, Name: name, MimeType: mimeType, Size: size, Kind: Link}
+	switch {
+	case isInlineImage(mimeType) && capabilities.Image && size <= MaxImageSize:
+		item.Kind = Image
+	case text && capabilities.EmbeddedContext && size <= MaxEmbedSize:
+		item.Kind = Embedded
+	}
+	return item, nil
+}
+
+// sniffSize is how much of a file is read to tell text from binary
+const sniffSize = 8 << 10
+
+// detectType guesses a file's MIME type from its extension, and whether it
+// is text from its first bytes
+func detectType(path string) (string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", false, err
+	}
+	head = head[:n]
+	looksText := !slices.Contains(head, 0) && validUTF8Prefix(head)
+
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch {
+	case mimeType == "" && looksText:
+		return "text/plain", true, nil
+	case mimeType == "":
+		return "application/octet-stream", false, nil
+	}
+	return mimeType, looksText && isTextType(mimeType), nil
+}
+
+// validUTF8Prefix reports whether b is UTF-8, allowing it to end part way
+// through a character where the sniffed prefix was cut
+func validUTF8Prefix(b []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if utf8.Valid(b) {
+			return true
+		}
+		b = b[:len(b)-1]
+	}
+	return utf8.Valid(b)
+}
+
+// isTextType reports whether a MIME type is text that can be embedded
+func isTextType(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "text/"),
+		strings.HasSuffix(mimeType, "+json"),
+		strings.HasSuffix(mimeType, "+xml"):
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-sh", "application/toml", "application/yaml", "application/sql":
+		return true
+	}
+	return false
+}
+
+// isInlineImage reports whether a MIME type is an image agents can view
+func isInlineImage(mimeType string) bool {
+	switch mimeType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// URI is the file URI of the item
+func (i Item) URI() string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(i.Path)}).String()
+}
+
+// Block reads the file into the content block it is sent as. The file is
+// read when the prompt is sent, so it includes changes made after it was
+// attached.
+func (i Item) Block() (protocol.Prompt, error) {
+	switch i.Kind {
+	case Embedded:
+		data, err := os.ReadFile(i.Path)
+		if err != nil {
+			return protocol.Prompt{}, err
+		}
+		return Embed(i.URI(), i.MimeType, string(data)), nil
+	case Image:
+		data, err := os.ReadFile(i.Path)
+		if err != nil {
+			return protocol.Prompt{}, err
+		}
+		return protocol.Prompt{
+			Type:     protocol.PromptImage,
+			MimeType: i.MimeType,
+			Data:     base64.StdEncoding.EncodeToString(data),
+			URI:      i.URI(),
+		}, nil
+	}
+
+	if _, err := os.Stat(i.Path); err != nil {
+		return protocol.Prompt{}, err
+	}
+	return protocol.Prompt{
+		Type:     protocol.PromptResourceLink,
+		URI:      i.URI(),
+		Name:     filepath.Base(i.Path),
+		MimeType: i.MimeType,
+		Size:     i.Size,
+	}, nil
+}
+
+// Embed is a resource block holding text, such as a file or piped input
+func Embed(uri, mimeType, text string) protocol.Prompt {
+	return protocol.Prompt{
+		Type: protocol.PromptEmbedded,
+		Resource: &protocol.PromptResource{
+			URI:      uri,
+			MimeType: mimeType,
+			Text:     text,
+		},
+	}
+}
+
+// Describe summarises the item for the list shown before sending
+func (i Item) Describe() string {
+	how := map[Kind]string{Embedded: "embedded", Image: "image", Link: "sent as a link"}[i.Kind]
+	return fmt.Sprintf("%s (%s, %s, %s)", i.Name, i.MimeType, FormatSize(i.Size), how)
+}
+
+// FormatSize formats a byte count for display
+func FormatSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+}
+
+// mentionPattern matches @ followed by a path, at the start of the text or
+// after whitespace, so e-mail addresses are not taken for mentions
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// Mentions returns the paths mentioned in text as @path that exist.
+// Punctuation ending a sentence after the path is ignored.
+func Mentions(text string) []string {
+	var paths []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		path := match[1]
+		for path != "" {
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+			trimmed := strings.TrimRight(path, ".,;:!?)]}'\"")
+			if trimmed == path {
+				path = ""
+				break
+			}
+			path = trimmed
+		}
+		if path != "" && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Prompt builds the blocks of a prompt: the text, then the items staged
+// for it and the files it mentions. It returns the items attached, and the
+// problems with those that could not be, which are left out.
+func Prompt(text string, staged []Item, capabilities protocol.PromptCapabilities) ([]protocol.Prompt, []Item, []error) {
+	items := slices.Clone(staged)
+	var problems []error
+	for _, path := range Mentions(text) {
+		mentioned, err := Resolve(path, capabilities)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		items = append(items, mentioned...)
+	}
+
+	prompt := []protocol.Prompt{{Type: protocol.PromptText, Text: text}}
+	var attached []Item
+	for _, item := range items {
+		if slices.ContainsFunc(attached, func(a Item) bool { return a.Path == item.Path }) {
+			continue
+		}
+		block, err := item.Block()
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		prompt = append(prompt, block)
+		attached = append(attached, item)
+	}
+	return prompt, attached, problems
+}
@@ -0,0 +1,185 @@
+package attach
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"agentgo/protocol"
+)
+
+var allCapabilities = protocol.PromptCapabilities{Image: true, EmbeddedContext: true}
+
+// pngHeader is enough of a PNG for the type to be detected by extension
+var pngHeader = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0, 0, 0, 0}
+
+func writeFiles(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	return dir
+}
+
+func TestResolve_Kinds(t *testing.T) {
+	writeFiles(t, map[string][]byte{
+		"notes.txt":  []byte("hello"),
+		"Makefile":   []byte("all:\n"),
+		"big.txt":    []byte(strings.Repeat("x", MaxEmbedSize+1)),
+		"logo.png":   pngHeader,
+		"tool.bin":   {0x7f, 'E', 'L', 'F', 0, 1},
+		"data.json":  []byte(`{"a": 1}`),
+		"broken.txt": {0xff, 0xfe, 0},
+	})
+
+	tests := []struct {
+		path         string
+		capabilities protocol.PromptCapabilities
+		kind         Kind
+		mimeType     string
+	}{
+		{"notes.txt", allCapabilities, Embedded, "text/plain"},
+		{"Makefile", allCapabilities, Embedded, "text/plain"},
+		{"data.json", allCapabilities, Embedded, "application/json"},
+		{"big.txt", allCapabilities, Link, "text/plain"},
+		{"logo.png", allCapabilities, Image, "image/png"},
+		{"tool.bin", allCapabilities, Link, "application/octet-stream"},
+		{"broken.txt", allCapabilities, Link, "text/plain"},
+		{"notes.txt", protocol.PromptCapabilities{}, Link, "text/plain"},
+		{"logo.png", protocol.PromptCapabilities{EmbeddedContext: true}, Link, "image/png"},
+	}
+	for _, tt := range tests {
+		items, err := Resolve(tt.path, tt.capabilities)
+		if err != nil {
+			t.Fatalf("Resolve(%q) error: %v", tt.path, err)
+		}
+		if len(items) != 1 || items[0].Kind != tt.kind || items[0].MimeType != tt.mimeType {
+			t.Errorf("Resolve(%q, %+v) = %+v, expected a %s %s", tt.path, tt.capabilities, items, tt.kind, tt.mimeType)
+		}
+	}
+}
+
+func TestResolve_Directory(t *testing.T) {
+	writeFiles(t, map[string][]byte{
+		"src/a.txt":        []byte("a"),
+		"src/sub/b.txt":    []byte("b"),
+		"src/.hidden":      []byte("h"),
+		"src/.git/config":  []byte("c"),
+		"other/not-me.txt": []byte("x"),
+	})
+
+	items, err := Resolve("src", allCapabilities)
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	expected := []string{filepath.Join("src", "a.txt"), filepath.Join("src", "sub", "b.txt")}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Resolve() names = %q, expected %q", names, expected)
+	}
+}
+
+func TestResolve_DirectoryTooLarge(t *testing.T) {
+	files := map[string][]byte{}
+	for i := 0; i <= MaxDirectoryFiles; i++ {
+		files[filepath.Join("many", strings.Repeat("f", i+1))] = []byte("x")
+	}
+	writeFiles(t, files)
+
+	if _, err := Resolve("many", allCapabilities); err == nil {
+		t.Error("Expected an error for a directory with too many files")
+	}
+}
+
+func TestItem_Block(t *testing.T) {
+	dir := writeFiles(t, map[string][]byte{
+		"notes.txt": []byte("hello"),
+		"logo.png":  pngHeader,
+	})
+
+	resolve := func(path string, capabilities protocol.PromptCapabilities) protocol.Prompt {
+		t.Helper()
+		items, err := Resolve(path, capabilities)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := items[0].Block()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
+
+	embedded := resolve("notes.txt", allCapabilities)
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "notes.txt"))
+	if embedded.Type != protocol.PromptEmbedded || embedded.Resource.Text != "hello" || embedded.Resource.URI != uri {
+		t.Errorf("Expected an embedded resource, got %+v", embedded)
+	}
+
+	image := resolve("logo.png", allCapabilities)
+	if image.Type != protocol.PromptImage || image.Data != base64.StdEncoding.EncodeToString(pngHeader) {
+		t.Errorf("Expected a base64 image, got %+v", image)
+	}
+
+	link := resolve("notes.txt", protocol.PromptCapabilities{})
+	if link.Type != protocol.PromptResourceLink || link.Name != "notes.txt" || link.Size != 5 || link.Resource != nil {
+		t.Errorf("Expected a resource link, got %+v", link)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	writeFiles(t, map[string][]byte{
+		"README.md":   []byte("readme"),
+		"docs/api.md": []byte("api"),
+	})
+
+	text := "Compare @README.md with @docs/api.md, then mail me@example.com about @missing.txt and @README.md."
+	expected := []string{"README.md", "docs/api.md"}
+	if paths := Mentions(text); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Mentions() = %q, expected %q", paths, expected)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	writeFiles(t, map[string][]byte{
+		"a.txt": []byte("a"),
+		"b.txt": []byte("b"),
+	})
+	staged, err := Resolve("a.txt", allCapabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, attached, problems := Prompt("look at @b.txt and @a.txt", staged, allCapabilities)
+	if len(problems) != 0 {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+	if len(prompt) != 3 || prompt[0].Text != "look at @b.txt and @a.txt" {
+		t.Fatalf("Expected the text and two resources, got %+v", prompt)
+	}
+	if len(attached) != 2 || attached[0].Name != "a.txt" || attached[1].Name != "b.txt" {
+		t.Errorf("Expected a.txt once then b.txt, got %+v", attached)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, expected := range map[int64]string{12: "12 B", 1536: "1.5 KB", 3 << 20: "3.0 MB"} {
+		if got := FormatSize(n); got != expected {
+			t.Errorf("FormatSize(%d) = %q, expected %q", n, got, expected)
+		}
+	}
+}
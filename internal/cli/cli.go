@@ -0,0 +1,310 @@
+// Package cli runs a program made of subcommands. Every command takes the
+// global flags as well as its own, defaults for both can be kept in a
+// config file, and shell completion scripts are generated from the table
+// of commands.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"agentgo/internal/render"
+)
+
+// Action runs a command with the arguments left after its flags
+type Action func(globals *Globals, args []string) error
+
+// Values describes what a flag or argument takes, for completion
+type Values struct {
+	// Words are the values it accepts
+	Words []string
+	// Files is set when it takes a path, Dirs when that is a directory
+	Files bool
+	Dirs  bool
+}
+
+// Command is a subcommand of the program
+type Command struct {
+	Name string
+	// Args describes the arguments after the flags, as shown in usage
+	Args    string
+	Summary string
+	// Description is shown in the command's help, below its usage
+	Description string
+	// Setup defines the command's flags and returns the action that runs
+	// it once they are parsed
+	Setup func(flags *flag.FlagSet) Action
+	// Complete describes the values of the command's flags by name, and of
+	// its arguments under ""
+	Complete map[string]Values
+}
+
+// Program is a command line program made of subcommands
+type Program struct {
+	Name    string
+	Summary string
+	// Default is the command run when none is named. Its flags may be
+	// given without naming it, as in "agentgo -record file".
+	Default  string
+	Commands []*Command
+	// Defaults are the global flags' values when neither the command line
+	// nor the config file sets them
+	Defaults Globals
+	Stdout   io.Writer
+	Stderr   io.Writer
+}
+
+// Commands the program provides itself
+const (
+	helpCommand       = "help"
+	completionCommand = "completion"
+)
+
+// Run parses the command line and runs the command it names. It returns
+// flag.ErrHelp when a command's help was asked for with -h.
+func (p *Program) Run(args []string) error {
+	globals := p.Defaults
+	given := map[string]bool{}
+
+	// Global flags may come before the command's name
+	name, rest := p.Default, args
+	leading := flag.NewFlagSet(p.Name, flag.ContinueOnError)
+	leading.SetOutput(io.Discard)
+	globals.define(leading)
+	switch err := leading.Parse(args); {
+	case errors.Is(err, flag.ErrHelp):
+		p.writeHelp(p.Stdout)
+		return nil
+	case err == nil:
+		leading.Visit(func(f *flag.Flag) { given[f.Name] = true })
+		rest = leading.Args()
+		if len(rest) > 0 {
+			name, rest = rest[0], rest[1:]
+		}
+	}
+	// Otherwise the flags are the default command's
+
+	switch name {
+	case helpCommand:
+		return p.help(rest)
+	case completionCommand:
+		return p.completion(rest)
+	}
+	command := p.lookup(name)
+	if command == nil {
+		return fmt.Errorf("unknown command %q, run \"%s help\" to list the commands", name, p.Name)
+	}
+
+	flags := flag.NewFlagSet(p.Name+" "+command.Name, flag.ContinueOnError)
+	flags.SetOutput(p.Stderr)
+	action := command.Setup(flags)
+	globals.define(flags)
+	flags.Usage = func() { p.writeCommandHelp(flags.Output(), command) }
+	if err := flags.Parse(rest); err != nil {
+		return err
+	}
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	settings, err := LoadSettings(globals.Config)
+	if err != nil {
+		return err
+	}
+	if err := settings.apply(flags, command.Name, given); err != nil {
+		return err
+	}
+	globals.Settings = settings
+
+	if err := globals.apply(); err != nil {
+		return err
+	}
+	return action(&globals, flags.Args())
+}
+
+// lookup finds a command by name
+func (p *Program) lookup(name string) *Command {
+	for _, command := range p.Commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+// help shows the program's help, or a command's
+func (p *Program) help(args []string) error {
+	if len(args) == 0 {
+		p.writeHelp(p.Stdout)
+		return nil
+	}
+
+	switch args[0] {
+	case helpCommand:
+		fmt.Fprintf(p.Stdout, "Usage: %s help [command]\n\nShows the help of a command, or lists the commands.\n", p.Name)
+		return nil
+	case completionCommand:
+		fmt.Fprintf(p.Stdout, "Usage: %s completion bash|zsh|fish\n\n%s\n", p.Name, completionDescription)
+		return nil
+	}
+	command := p.lookup(args[0])
+	if command == nil {
+		return fmt.Errorf("unknown command %q, run \"%s help\" to list the commands", args[0], p.Name)
+	}
+	p.writeCommandHelp(p.Stdout, command)
+	return nil
+}
+
+// writeHelp lists the commands and the global flags
+func (p *Program) writeHelp(w io.Writer) {
+	fmt.Fprintf(w, "%s - %s\n\n", p.Name, p.Summary)
+	fmt.Fprintf(w, "Usage: %s [global flags] <command> [flags] [arguments]\n\n", p.Name)
+
+	fmt.Fprintln(w, "Commands:")
+	width := len(completionCommand)
+	for _, command := range p.Commands {
+		width = max(width, len(command.Name))
+	}
+	for _, command := range p.Commands {
+		summary := command.Summary
+		if command.Name == p.Default {
+			summary += " (default)"
+		}
+		fmt.Fprintf(w, "  %-*s  %s\n", width, command.Name, summary)
+	}
+	fmt.Fprintf(w, "  %-*s  %s\n", width, helpCommand, "Show the help of a command")
+	fmt.Fprintf(w, "  %-*s  %s\n", width, completionCommand, "Print a shell completion script")
+
+	fmt.Fprintln(w, "\nGlobal flags:")
+	p.globalFlags().PrintDefaults()
+	fmt.Fprintf(w, "\nRun \"%s help <command>\" for the flags of a command.\n", p.Name)
+}
+
+// writeCommandHelp shows a command's usage, description and flags
+func (p *Program) writeCommandHelp(w io.Writer, command *Command) {
+	usage := p.Name + " " + command.Name + " [flags]"
+	if command.Args != "" {
+		usage += " " + command.Args
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n", usage)
+	if command.Description != "" {
+		fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(command.Description))
+	} else {
+		fmt.Fprintf(w, "%s.\n\n", command.Summary)
+	}
+
+	own := p.commandFlags(command)
+	own.SetOutput(w)
+	if hasFlags(own) {
+		fmt.Fprintln(w, "Flags:")
+		own.PrintDefaults()
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "Global flags:")
+	globals := p.globalFlags()
+	globals.SetOutput(w)
+	globals.PrintDefaults()
+}
+
+// globalFlags defines the global flags, with their default values
+func (p *Program) globalFlags() *flag.FlagSet {
+	defaults := p.Defaults
+	flags := flag.NewFlagSet(p.Name, flag.ContinueOnError)
+	flags.SetOutput(p.Stdout)
+	defaults.define(flags)
+	return flags
+}
+
+// commandFlags defines a command's own flags
+func (p *Program) commandFlags(command *Command) *flag.FlagSet {
+	flags := flag.NewFlagSet(p.Name+" "+command.Name, flag.ContinueOnError)
+	flags.SetOutput(p.Stdout)
+	command.Setup(flags)
+	return flags
+}
+
+func hasFlags(flags *flag.FlagSet) bool {
+	found := false
+	flags.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// Verbosity levels of the -verbosity flag
+const (
+	// Quiet hides progress and protocol warnings
+	Quiet = "quiet"
+	// Normal shows progress and protocol warnings
+	Normal = "normal"
+	// Verbose also logs every message exchanged with the agent
+	Verbose = "verbose"
+)
+
+// Verbosities lists the verbosity levels
+var Verbosities = []string{Quiet, Normal, Verbose}
+
+// Globals are the flags every command takes
+type Globals struct {
+	// Agent is the command line that starts the agent
+	Agent string
+	// Cwd is the directory to work in, the session's working directory
+	Cwd string
+	// Config is the config file, or "" for the default one
+	Config    string
+	Verbosity string
+	Color     string
+	// Settings are the contents of the config file
+	Settings Settings
+}
+
+// globalNames lists the global flags
+var globalNames = []string{"agent", "cwd", "config", "verbosity", "color"}
+
+// globalValues describes the global flags' values for completion
+var globalValues = map[string]Values{
+	"cwd":       {Dirs: true},
+	"config":    {Files: true},
+	"verbosity": {Words: Verbosities},
+	"color":     {Words: []string{string(render.ColorAuto), string(render.ColorAlways), string(render.ColorNever)}},
+}
+
+// define adds the global flags to flags. Their defaults are the current
+// values, so flags given before the command's name are kept.
+func (g *Globals) define(flags *flag.FlagSet) {
+	flags.StringVar(&g.Agent, "agent", g.Agent, "Command line that starts the agent")
+	flags.StringVar(&g.Cwd, "cwd", g.Cwd, "Work in this directory instead of the current one")
+	flags.StringVar(&g.Config, "config", g.Config, "Config file (default "+defaultConfigDescription+")")
+	flags.StringVar(&g.Verbosity, "verbosity", g.Verbosity, "How much to show: quiet, normal or verbose")
+	flags.StringVar(&g.Color, "color", g.Color, "Use colors: auto, always or never")
+}
+
+// apply checks the global flags and puts them into effect
+func (g *Globals) apply() error {
+	if strings.TrimSpace(g.Agent) == "" {
+		return errors.New("-agent is empty")
+	}
+	if !slices.Contains(Verbosities, g.Verbosity) {
+		return fmt.Errorf("unknown verbosity %q, expected quiet, normal or verbose", g.Verbosity)
+	}
+	mode, err := render.ParseColorMode(g.Color)
+	if err != nil {
+		return err
+	}
+	render.SetColorMode(mode)
+
+	if g.Cwd != "" {
+		if err := os.Chdir(g.Cwd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AgentCommand splits the agent's command line into the command and its
+// arguments
+func (g *Globals) AgentCommand() (string, []string) {
+	fields := strings.Fields(g.Agent)
+	return fields[0], fields[1:]
+}
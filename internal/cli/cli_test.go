@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// run records what the command it was given ran with
+type run struct {
+	command string
+	globals Globals
+	args    []string
+	output  string
+	verbose bool
+}
+
+func testProgram(t *testing.T, got *run) (*Program, *bytes.Buffer) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	command := func(name string) *Command {
+		return &Command{
+			Name:    name,
+			Args:    "[prompt...]",
+			Summary: "Run " + name,
+			Setup: func(flags *flag.FlagSet) Action {
+				output := flags.String("output", "text", "Output format")
+				verbose := flags.Bool("v", false, "Say more")
+				return func(globals *Globals, args []string) error {
+					*got = run{command: name, globals: *globals, args: args, output: *output, verbose: *verbose}
+					return nil
+				}
+			},
+			Complete: map[string]Values{"output": {Words: []string{"text", "json"}}},
+		}
+	}
+
+	stdout := &bytes.Buffer{}
+	return &Program{
+		Name:     "prog",
+		Summary:  "a test program",
+		Default:  "chat",
+		Commands: []*Command{command("chat"), command("exec")},
+		Defaults: Globals{Agent: "agent", Verbosity: Normal, Color: "never"},
+		Stdout:   stdout,
+		Stderr:   &bytes.Buffer{},
+	}, stdout
+}
+
+func TestRun_Dispatch(t *testing.T) {
+	tests := []struct {
+		args      []string
+		command   string
+		agent     string
+		verbosity string
+		output    string
+		rest      []string
+	}{
+		{nil, "chat", "agent", Normal, "text", nil},
+		{[]string{"exec", "hello", "there"}, "exec", "agent", Normal, "text", []string{"hello", "there"}},
+		{[]string{"-agent", "other --acp", "exec", "-output", "json", "hi"}, "exec", "other --acp", Normal, "json", []string{"hi"}},
+		{[]string{"exec", "-verbosity", "quiet", "hi"}, "exec", "agent", Quiet, "text", []string{"hi"}},
+		// The default command's flags may be given without naming it
+		{[]string{"-output", "json"}, "chat", "agent", Normal, "json", nil},
+		{[]string{"-verbosity", "verbose", "-output", "json"}, "chat", "agent", Verbose, "json", nil},
+	}
+
+	for _, tt := range tests {
+		var got run
+		program, _ := testProgram(t, &got)
+		if err := program.Run(tt.args); err != nil {
+			t.Errorf("%q: unexpected error %v", tt.args, err)
+			continue
+		}
+		if got.command != tt.command || got.globals.Agent != tt.agent || got.globals.Verbosity != tt.verbosity || got.output != tt.output {
+			t.Errorf("%q: unexpected run %+v", tt.args, got)
+		}
+		if len(got.args) != 0 || len(tt.rest) != 0 {
+			if !reflect.DeepEqual(got.args, tt.rest) {
+				t.Errorf("%q: expected arguments %q, got %q", tt.args, tt.rest, got.args)
+			}
+		}
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"bogus"}, `unknown command "bogus"`},
+		{[]string{"help", "bogus"}, `unknown command "bogus"`},
+		{[]string{"-verbosity", "loud", "exec"}, "unknown verbosity"},
+		{[]string{"-color", "purple", "exec"}, "unknown color mode"},
+		{[]string{"-agent", " ", "exec"}, "-agent is empty"},
+		{[]string{"exec", "-bogus"}, "flag provided but not defined"},
+		{[]string{"completion", "tcsh"}, "no completion for tcsh"},
+	}
+
+	for _, tt := range tests {
+		var got run
+		program, _ := testProgram(t, &got)
+		err := program.Run(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.args, tt.want, err)
+		}
+		if got.command != "" {
+			t.Errorf("%q: expected no command to run, ran %s", tt.args, got.command)
+		}
+	}
+}
+
+func TestRun_Help(t *testing.T) {
+	var got run
+	program, stdout := testProgram(t, &got)
+	if err := program.Run([]string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Usage: prog [global flags] <command>", "chat        Run chat (default)", "completion", "-verbosity"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected the help to contain %q, got:\n%s", want, stdout)
+		}
+	}
+
+	stdout.Reset()
+	if err := program.Run([]string{"help", "exec"}); err != nil {
+		t.Fatal(err)
+	}
+	help := stdout.String()
+	if !strings.HasPrefix(help, "Usage: prog exec [flags] [prompt...]") || !strings.Contains(help, "-output") || !strings.Contains(help, "Global flags:") {
+		t.Errorf("Unexpected command help:\n%s", help)
+	}
+
+	if err := program.Run([]string{"exec", "-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp, got %v", err)
+	}
+	if got.command != "" {
+		t.Errorf("Expected help not to run the command, ran %s", got.command)
+	}
+}
+
+func TestRun_Settings(t *testing.T) {
+	var got run
+	program, _ := testProgram(t, &got)
+	config := filepath.Join(t.TempDir(), "config")
+	contents := "verbosity = quiet\nagent = \"from config\"\n\n[exec]\noutput = json\nv = true\n"
+	if err := os.WriteFile(config, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := program.Run([]string{"-config", config, "exec", "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.globals.Verbosity != Quiet || got.globals.Agent != "from config" || got.output != "json" || !got.verbose {
+		t.Errorf("Expected the config file's settings, got %+v", got)
+	}
+	if got.globals.Settings.Get("exec", "output") != "json" {
+		t.Errorf("Expected the settings to be passed on, got %v", got.globals.Settings)
+	}
+
+	// The command line wins over the config file
+	if err := program.Run([]string{"-config", config, "-verbosity", "verbose", "exec", "-output", "text", "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.globals.Verbosity != Verbose || got.output != "text" {
+		t.Errorf("Expected the command line's flags, got %+v", got)
+	}
+
+	// The [exec] section does not apply to chat
+	if err := program.Run([]string{"-config", config}); err != nil {
+		t.Fatal(err)
+	}
+	if got.command != "chat" || got.output != "text" || got.globals.Verbosity != Quiet {
+		t.Errorf("Expected only the global settings, got %+v", got)
+	}
+
+	if err := program.Run([]string{"-config", filepath.Join(t.TempDir(), "missing"), "exec"}); err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+}
+
+func TestRun_DefaultConfig(t *testing.T) {
+	var got run
+	program, _ := testProgram(t, &got)
+	path, err := DefaultConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("[chat]\noutput = json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := program.Run(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.output != "json" {
+		t.Errorf("Expected the default config file to be read, got %+v", got)
+	}
+}
+
+func TestGlobals_AgentCommand(t *testing.T) {
+	globals := Globals{Agent: "  npx  claude-code-acp --debug "}
+	command, args := globals.AgentCommand()
+	if command != "npx" || !reflect.DeepEqual(args, []string{"claude-code-acp", "--debug"}) {
+		t.Errorf("Unexpected command %q %q", command, args)
+	}
+}
@@ -0,0 +1,332 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const completionDescription = `Prints a script that completes the commands, flags and their values in
+a shell. Load it from the shell's startup file:
+
+  bash:  source <(agentgo completion bash)
+  zsh:   source <(agentgo completion zsh)
+  fish:  agentgo completion fish | source`
+
+// Shells lists the shells completion scripts are generated for
+var Shells = []string{"bash", "zsh", "fish"}
+
+// completion prints the completion script for a shell
+func (p *Program) completion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s completion bash|zsh|fish", p.Name)
+	}
+
+	switch args[0] {
+	case "bash":
+		p.writeBash(p.Stdout)
+	case "zsh":
+		p.writeZsh(p.Stdout)
+	case "fish":
+		p.writeFish(p.Stdout)
+	default:
+		return fmt.Errorf("no completion for %s, expected bash, zsh or fish", args[0])
+	}
+	return nil
+}
+
+// flagInfo describes a flag for completion
+type flagInfo struct {
+	name       string
+	usage      string
+	takesValue bool
+	values     Values
+}
+
+// describeFlags lists the flags defined on flags
+func describeFlags(flags *flag.FlagSet, values map[string]Values) []flagInfo {
+	var infos []flagInfo
+	flags.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		infos = append(infos, flagInfo{
+			name:       f.Name,
+			usage:      f.Usage,
+			takesValue: !ok || !boolFlag.IsBoolFlag(),
+			values:     values[f.Name],
+		})
+	})
+	return infos
+}
+
+// ownFlags lists the flags of a command, without the global ones
+func (p *Program) ownFlags(command *Command) []flagInfo {
+	return describeFlags(p.commandFlags(command), command.Complete)
+}
+
+// globalFlagInfos lists the global flags
+func (p *Program) globalFlagInfos() []flagInfo {
+	return describeFlags(p.globalFlags(), globalValues)
+}
+
+// allFlags lists every flag a command takes, its own then the global ones
+func (p *Program) allFlags(command *Command) []flagInfo {
+	return append(p.ownFlags(command), p.globalFlagInfos()...)
+}
+
+// commandWords lists what can be typed as the command
+func (p *Program) commandWords() []string {
+	words := make([]string, 0, len(p.Commands)+2)
+	for _, command := range p.Commands {
+		words = append(words, command.Name)
+	}
+	return append(words, helpCommand, completionCommand)
+}
+
+// valueFlags lists the flags of every command that take a value, so the
+// scripts can skip values while looking for the command's name
+func (p *Program) valueFlags() []string {
+	var names []string
+	infos := p.globalFlagInfos()
+	for _, command := range p.Commands {
+		infos = append(infos, p.ownFlags(command)...)
+	}
+	for _, info := range infos {
+		if info.takesValue && !slices.Contains(names, "-"+info.name) {
+			names = append(names, "-"+info.name)
+		}
+	}
+	return names
+}
+
+func flagNames(infos []flagInfo) []string {
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, "-"+info.name)
+	}
+	return names
+}
+
+// bashValues is the bash code completing values
+func bashValues(values Values) string {
+	switch {
+	case len(values.Words) > 0:
+		return fmt.Sprintf(`COMPREPLY=($(compgen -W %q -- "$cur"))`, strings.Join(values.Words, " "))
+	case values.Dirs:
+		return `compopt -o filenames; COMPREPLY=($(compgen -d -- "$cur"))`
+	case values.Files:
+		return `compopt -o filenames; COMPREPLY=($(compgen -f -- "$cur"))`
+	}
+	return "COMPREPLY=()"
+}
+
+func (p *Program) writeBash(w io.Writer) {
+	fn := "_" + strings.ReplaceAll(p.Name, "-", "_")
+	fmt.Fprintf(w, "# bash completion for %s, generated by \"%s completion bash\"\n", p.Name, p.Name)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintf(w, "    local value_flags=\" %s \"\n", strings.Join(p.valueFlags(), " "))
+	fmt.Fprint(w, `    local command="" i word
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        if [[ "$value_flags" == *" $word "* ]]; then
+            ((i++))
+        elif [[ "$word" != -* ]]; then
+            command="$word"
+            break
+        fi
+    done
+`)
+
+	// Values of flags
+	fmt.Fprintln(w, `    case "$command:$prev" in`)
+	writeBashFlagValues := func(pattern string, infos []flagInfo) {
+		for _, info := range infos {
+			if info.takesValue {
+				fmt.Fprintf(w, "        %s:-%s) %s; return ;;\n", pattern, info.name, bashValues(info.values))
+			}
+		}
+	}
+	for _, command := range p.Commands {
+		writeBashFlagValues(command.Name, p.ownFlags(command))
+	}
+	writeBashFlagValues("*", p.globalFlagInfos())
+	fmt.Fprintln(w, "    esac")
+
+	// Flags and arguments
+	fmt.Fprintf(w, `
+    if [[ "$cur" == -* ]]; then
+        case "$command" in
+`)
+	for _, command := range p.Commands {
+		fmt.Fprintf(w, "            %s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", command.Name, strings.Join(flagNames(p.allFlags(command)), " "))
+	}
+	fmt.Fprintf(w, "            *) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(flagNames(p.globalFlagInfos()), " "))
+	fmt.Fprintln(w, `        esac
+        return
+    fi
+
+    case "$command" in`)
+	fmt.Fprintf(w, "        \"\") COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(p.commandWords(), " "))
+	fmt.Fprintf(w, "        %s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", helpCommand, strings.Join(p.commandWords(), " "))
+	fmt.Fprintf(w, "        %s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", completionCommand, strings.Join(Shells, " "))
+	for _, command := range p.Commands {
+		if values, ok := command.Complete[""]; ok {
+			fmt.Fprintf(w, "        %s) %s ;;\n", command.Name, bashValues(values))
+		}
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "complete -F %s %s\n", fn, p.Name)
+}
+
+// zshValues is the zsh code completing values
+func zshValues(values Values) string {
+	switch {
+	case len(values.Words) > 0:
+		return "compadd -- " + strings.Join(values.Words, " ")
+	case values.Dirs:
+		return "_files -/"
+	case values.Files:
+		return "_files"
+	}
+	return "_message value"
+}
+
+// zshQuote quotes a string for zsh, escaping the colons _describe splits on
+func zshQuote(text string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(text, "'", `'\''`), ":", `\:`) + "'"
+}
+
+func (p *Program) writeZsh(w io.Writer) {
+	fn := "_" + strings.ReplaceAll(p.Name, "-", "_")
+	fmt.Fprintf(w, "#compdef %s\n", p.Name)
+	fmt.Fprintf(w, "# zsh completion for %s, generated by \"%s completion zsh\"\n", p.Name, p.Name)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "    local -a value_flags=(%s)\n", strings.Join(p.valueFlags(), " "))
+	fmt.Fprint(w, `    local command="" i word
+    for ((i = 2; i < CURRENT; i++)); do
+        word=${words[i]}
+        if (( ${value_flags[(Ie)$word]} )); then
+            ((i++))
+        elif [[ $word != -* ]]; then
+            command=$word
+            break
+        fi
+    done
+    local cur=${words[CURRENT]} prev=${words[CURRENT-1]}
+`)
+
+	fmt.Fprintln(w, `    case "$command:$prev" in`)
+	writeZshFlagValues := func(pattern string, infos []flagInfo) {
+		for _, info := range infos {
+			if info.takesValue {
+				fmt.Fprintf(w, "        %s:-%s) %s; return ;;\n", pattern, info.name, zshValues(info.values))
+			}
+		}
+	}
+	for _, command := range p.Commands {
+		writeZshFlagValues(command.Name, p.ownFlags(command))
+	}
+	writeZshFlagValues("*", p.globalFlagInfos())
+	fmt.Fprintln(w, "    esac")
+
+	describe := func(infos []flagInfo) string {
+		items := make([]string, 0, len(infos))
+		for _, info := range infos {
+			items = append(items, zshQuote("-"+info.name+":"+info.usage))
+		}
+		return strings.Join(items, " ")
+	}
+	fmt.Fprintln(w, `
+    local -a flags
+    if [[ $cur == -* ]]; then
+        case $command in`)
+	for _, command := range p.Commands {
+		fmt.Fprintf(w, "            %s) flags=(%s) ;;\n", command.Name, describe(p.allFlags(command)))
+	}
+	fmt.Fprintf(w, "            *) flags=(%s) ;;\n", describe(p.globalFlagInfos()))
+	fmt.Fprint(w, `        esac
+        _describe flag flags
+        return
+    fi
+`)
+
+	commands := make([]string, 0, len(p.Commands)+2)
+	for _, command := range p.Commands {
+		commands = append(commands, zshQuote(command.Name+":"+command.Summary))
+	}
+	commands = append(commands, zshQuote(helpCommand+":Show the help of a command"), zshQuote(completionCommand+":Print a shell completion script"))
+	fmt.Fprintf(w, "    local -a commands=(%s)\n", strings.Join(commands, " "))
+	fmt.Fprintln(w, `    case $command in
+        "") _describe command commands ;;`)
+	fmt.Fprintf(w, "        %s) _describe command commands ;;\n", helpCommand)
+	fmt.Fprintf(w, "        %s) compadd -- %s ;;\n", completionCommand, strings.Join(Shells, " "))
+	for _, command := range p.Commands {
+		if values, ok := command.Complete[""]; ok {
+			fmt.Fprintf(w, "        %s) %s ;;\n", command.Name, zshValues(values))
+		}
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, `if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    %s "$@"
+else
+    compdef %s %s
+fi
+`, fn, fn, p.Name)
+}
+
+// fishQuote quotes a string for fish
+func fishQuote(text string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(text, `\`, `\\`), "'", `\'`) + "'"
+}
+
+// fishValues is the fish completion options for values
+func fishValues(values Values) string {
+	switch {
+	case len(values.Words) > 0:
+		return "-x -a " + fishQuote(strings.Join(values.Words, " "))
+	case values.Dirs:
+		return "-x -a '(__fish_complete_directories)'"
+	case values.Files:
+		return "-r -F"
+	}
+	return "-x"
+}
+
+func (p *Program) writeFish(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for %s, generated by \"%s completion fish\"\n", p.Name, p.Name)
+	fmt.Fprintf(w, "complete -c %s -f\n", p.Name)
+
+	for _, command := range p.Commands {
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", p.Name, command.Name, fishQuote(command.Summary))
+	}
+	fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", p.Name, helpCommand, fishQuote("Show the help of a command"))
+	fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", p.Name, completionCommand, fishQuote("Print a shell completion script"))
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from %s' -a %s\n", p.Name, helpCommand, fishQuote(strings.Join(p.commandWords(), " ")))
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from %s' -a %s\n", p.Name, completionCommand, fishQuote(strings.Join(Shells, " ")))
+
+	writeFlags := func(condition string, infos []flagInfo) {
+		for _, info := range infos {
+			line := fmt.Sprintf("complete -c %s", p.Name)
+			if condition != "" {
+				line += " -n " + fishQuote(condition)
+			}
+			line += " -o " + info.name
+			if info.takesValue {
+				line += " " + fishValues(info.values)
+			}
+			fmt.Fprintln(w, line+" -d "+fishQuote(info.usage))
+		}
+	}
+	writeFlags("", p.globalFlagInfos())
+	for _, command := range p.Commands {
+		condition := "__fish_seen_subcommand_from " + command.Name
+		writeFlags(condition, p.ownFlags(command))
+		if values, ok := command.Complete[""]; ok {
+			fmt.Fprintf(w, "complete -c %s -n %s %s\n", p.Name, fishQuote(condition), strings.TrimPrefix(strings.TrimPrefix(fishValues(values), "-x "), "-r "))
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompletion_Scripts(t *testing.T) {
+	for _, shell := range Shells {
+		var got run
+		program, stdout := testProgram(t, &got)
+		if err := program.Run([]string{"completion", shell}); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		script := stdout.String()
+		for _, want := range []string{"prog", "chat", "exec", "completion", "verbosity", "output", "json"} {
+			if !strings.Contains(script, want) {
+				t.Errorf("%s: expected the script to mention %q", shell, want)
+			}
+		}
+
+		// Check the syntax with the shell itself when it is installed
+		path, err := exec.LookPath(shell)
+		if err != nil {
+			continue
+		}
+		file := filepath.Join(t.TempDir(), "completion")
+		if err := os.WriteFile(file, []byte(script), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stderr bytes.Buffer
+		check := exec.Command(path, "-n", file)
+		check.Stderr = &stderr
+		if err := check.Run(); err != nil {
+			t.Errorf("%s: the script does not parse: %v\n%s", shell, err, stderr.String())
+		}
+	}
+}
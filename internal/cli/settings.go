@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultConfigDescription names the default config file in help
+const defaultConfigDescription = "$XDG_CONFIG_HOME/agentgo/config"
+
+// Settings are the contents of a config file: values by section, then by
+// name. Settings before the first section are under "".
+//
+// A config file sets defaults for flags, one per line. Global flags go at
+// the top, and a command's flags in a section named after it:
+//
+//	color = never
+//
+//	[exec]
+//	policy = bash=allow,edit=allow
+//	output = json
+type Settings map[string]map[string]string
+
+// Get returns a setting, or "" when it is not set
+func (s Settings) Get(section, name string) string {
+	return s[section][name]
+}
+
+// ParseSettings reads a config file. Lines starting with # or ; are
+// comments, and values may be double quoted.
+func ParseSettings(r io.Reader) (Settings, error) {
+	settings := Settings{}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		name, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected name = value, got %q", number, line)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad quoted value %s", number, value)
+			}
+			value = unquoted
+		}
+		if settings[section] == nil {
+			settings[section] = map[string]string{}
+		}
+		settings[section][name] = value
+	}
+	return settings, scanner.Err()
+}
+
+// LoadSettings reads the config file at path. An empty path reads the
+// default file, which need not exist.
+func LoadSettings(path string) (Settings, error) {
+	required := path != ""
+	if !required {
+		var err error
+		if path, err = DefaultConfigPath(); err != nil {
+			return Settings{}, nil
+		}
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return Settings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	settings, err := ParseSettings(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// DefaultConfigPath returns the config file read when -config is not
+// given: $XDG_CONFIG_HOME/agentgo/config, or ~/.config/agentgo/config
+func DefaultConfigPath() (string, error) {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "agentgo", "config"), nil
+}
+
+// apply sets the flags of a command that were not given on the command
+// line from the top of the file and the command's section
+func (s Settings) apply(flags *flag.FlagSet, command string, given map[string]bool) error {
+	for _, section := range []string{"", command} {
+		for name, value := range s[section] {
+			global := slices.Contains(globalNames, name)
+			switch {
+			case name == "config":
+				return errors.New("config: the config file cannot name another one")
+			case section == "" && !global:
+				return fmt.Errorf("config: %s is not a global flag, set it in a [command] section", name)
+			case flags.Lookup(name) == nil:
+				return fmt.Errorf("config: [%s] has no flag %s", section, name)
+			case given[name]:
+				continue
+			}
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("config: %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
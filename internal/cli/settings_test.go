@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSettings(t *testing.T) {
+	input := `# global flags
+color = never
+agent = "npx claude-code-acp"
+
+; the exec command
+[ exec ]
+policy = bash=allow,edit=allow
+output=json
+`
+	settings, err := ParseSettings(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Settings{
+		"":     {"color": "never", "agent": "npx claude-code-acp"},
+		"exec": {"policy": "bash=allow,edit=allow", "output": "json"},
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("Expected %v, got %v", want, settings)
+	}
+	if settings.Get("chat", "policy") != "" {
+		t.Error("Expected an unset setting to be empty")
+	}
+}
+
+func TestParseSettings_Errors(t *testing.T) {
+	for _, input := range []string{"color never", "[exec]\nagent = \"unterminated"} {
+		if _, err := ParseSettings(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestSettings_Apply(t *testing.T) {
+	tests := []struct {
+		settings Settings
+		want     string
+	}{
+		{Settings{"": {"output": "json"}}, "not a global flag"},
+		{Settings{"exec": {"bogus": "1"}}, "has no flag bogus"},
+		{Settings{"": {"config": "other"}}, "cannot name another one"},
+		{Settings{"exec": {"count": "many"}}, "count"},
+	}
+
+	for _, tt := range tests {
+		flags := flag.NewFlagSet("exec", flag.ContinueOnError)
+		flags.String("output", "text", "")
+		flags.Int("count", 0, "")
+		globals := Globals{}
+		globals.define(flags)
+		err := tt.settings.apply(flags, "exec", nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: expected an error containing %q, got %v", tt.settings, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package core
+
+import "agentgo/protocol"
+
+// RegisterPermissionPrompt routes session/request_permission requests to the prompt
+func RegisterPermissionPrompt(registry *protocol.Registry, prompt PermissionPrompt) {
+	protocol.OnRequest(registry, protocol.MethodSessionRequestPermission, prompt.HandlePermissionRequest)
+}
+
+// RegisterNotifications routes session/update notifications to the notifier
+func RegisterNotifications(registry *protocol.Registry, notifier Notifications) {
+	protocol.OnNotification(registry, protocol.MethodSessionUpdate, notifier.HandleNotification)
+}
@@ -0,0 +1,269 @@
+// Package events turns what happens in a session into one model: a stream
+// of normalised events, and a summary of a run built from them. The
+// terminal display draws these events for people, and output for tools is
+// written from them.
+package events
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"agentgo/protocol"
+)
+
+// Type names the kind of an event
+type Type string
+
+const (
+	// SessionStarted is the first event, naming the session
+	SessionStarted Type = "session_started"
+	// Prompt carries a prompt sent to the agent, or a chunk of one the
+	// agent reports back
+	Prompt Type = "prompt"
+	// Message carries a chunk of the agent's answer
+	Message Type = "message"
+	// Thought carries a chunk of the agent's reasoning
+	Thought Type = "thought"
+	// ToolCallStarted reports a tool call the agent started
+	ToolCallStarted Type = "tool_call"
+	// ToolCallUpdated reports a change to a tool call, such as its status
+	ToolCallUpdated Type = "tool_call_update"
+	// PlanUpdated carries the agent's plan, in full each time
+	PlanUpdated Type = "plan"
+	// ModeChanged reports the session's new mode
+	ModeChanged Type = "mode"
+	// PermissionDecided reports how a permission request was answered
+	PermissionDecided Type = "permission"
+	// TurnEnded is the last event of a turn
+	TurnEnded Type = "turn_end"
+)
+
+// Event is one thing that happened in a session. Only the fields of its
+// type are set.
+type Event struct {
+	Type      Type      `json:"type"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"sessionId,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	Mode      string    `json:"mode,omitempty"`
+
+	ToolCall   *ToolCall            `json:"toolCall,omitempty"`
+	Plan       []protocol.PlanEntry `json:"plan,omitempty"`
+	Permission *Permission          `json:"permission,omitempty"`
+	StopReason string               `json:"stopReason,omitempty"`
+	Duration   *Duration            `json:"duration,omitempty"`
+	Error      string               `json:"error,omitempty"`
+}
+
+// ToolCall is the state of a tool call. Updates carry only what changed.
+type ToolCall struct {
+	ID     string         `json:"id"`
+	Title  string         `json:"title,omitempty"`
+	Kind   string         `json:"kind,omitempty"`
+	Status string         `json:"status,omitempty"`
+	Files  []string       `json:"files,omitempty"`
+	Input  map[string]any `json:"input,omitempty"`
+}
+
+// Permission is the answer to a permission request
+type Permission struct {
+	ToolCallID string `json:"toolCallId"`
+	Tool       string `json:"tool"`
+	// Option is the option selected, empty when the request was cancelled
+	Option string `json:"option,omitempty"`
+	// By is who answered: "policy", "user", or "default" when nothing
+	// could and the request was rejected
+	By string `json:"by"`
+}
+
+// Duration is a length of time written in milliseconds
+type Duration struct {
+	Milliseconds int64 `json:"ms"`
+}
+
+// NewDuration converts d to a Duration
+func NewDuration(d time.Duration) *Duration {
+	return &Duration{Milliseconds: d.Milliseconds()}
+}
+
+// FromUpdate converts a session update into an event, or returns false for
+// updates that are not part of the model, such as the command list
+func FromUpdate(update protocol.SessionUpdate, at time.Time) (Event, bool) {
+	event := Event{Time: at}
+	switch update.SessionUpdateType {
+	case protocol.SessionUpdateAgentMessage, protocol.SessionUpdateAgentThought, protocol.SessionUpdateUserMessage:
+		if update.Content == nil || update.Content.Text == "" {
+			return Event{}, false
+		}
+		switch update.SessionUpdateType {
+		case protocol.SessionUpdateAgentMessage:
+			event.Type = Message
+		case protocol.SessionUpdateAgentThought:
+			event.Type = Thought
+		default:
+			event.Type = Prompt
+		}
+		event.Text = update.Content.Text
+	case protocol.SessionUpdateToolCall, protocol.SessionUpdateToolCallUpdate:
+		event.Type = ToolCallStarted
+		if update.SessionUpdateType == protocol.SessionUpdateToolCallUpdate {
+			event.Type = ToolCallUpdated
+		}
+		event.ToolCall = &ToolCall{
+			ID:     update.ToolCallID,
+			Title:  update.Title,
+			Kind:   update.Kind,
+			Status: update.Status,
+			Files:  files(update),
+			Input:  update.RawInput,
+		}
+	case protocol.SessionUpdatePlan:
+		event.Type = PlanUpdated
+		event.Plan = update.Entries
+	case protocol.SessionUpdateCurrentMode:
+		event.Type = ModeChanged
+		event.Mode = update.CurrentModeID
+	default:
+		return Event{}, false
+	}
+	return event, true
+}
+
+// fileInputs are the raw input fields agents name a tool's file in
+var fileInputs = []string{"file_path", "path", "notebook_path"}
+
+// files lists the paths a tool call works on
+func files(update protocol.SessionUpdate) []string {
+	var paths []string
+	for _, location := range update.Locations {
+		paths = appendPath(paths, location.Path)
+	}
+	for _, key := range fileInputs {
+		if path, ok := update.RawInput[key].(string); ok {
+			paths = appendPath(paths, path)
+		}
+	}
+	return paths
+}
+
+func appendPath(paths []string, path string) []string {
+	if path == "" || slices.Contains(paths, path) {
+		return paths
+	}
+	return append(paths, path)
+}
+
+// changesFiles reports whether a kind of tool call changes the files it
+// works on, rather than only reading them
+func changesFiles(kind string) bool {
+	switch kind {
+	case "edit", "delete", "move":
+		return true
+	}
+	return false
+}
+
+// Summary describes a finished run
+type Summary struct {
+	SessionID  string `json:"sessionId"`
+	StopReason string `json:"stopReason,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Text is the agent's answer, its message chunks joined
+	Text      string     `json:"text"`
+	ToolCalls []ToolCall `json:"toolCalls"`
+	// FilesTouched lists the files changed by tool calls, sorted
+	FilesTouched []string `json:"filesTouched"`
+	Duration     Duration `json:"duration"`
+}
+
+// Collector builds a summary from the events of a run. It is safe for
+// concurrent use.
+type Collector struct {
+	mutex     sync.Mutex
+	started   time.Time
+	summary   Summary
+	text      strings.Builder
+	toolCalls map[string]int
+	touched   []string
+}
+
+// NewCollector creates a collector for a run that started at started
+func NewCollector(started time.Time) *Collector {
+	return &Collector{started: started, toolCalls: map[string]int{}}
+}
+
+// Started is when the run started
+func (c *Collector) Started() time.Time {
+	return c.started
+}
+
+// Add records an event
+func (c *Collector) Add(event Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch event.Type {
+	case SessionStarted:
+		c.summary.SessionID = event.SessionID
+	case Message:
+		c.text.WriteString(event.Text)
+	case ToolCallStarted, ToolCallUpdated:
+		c.addToolCall(*event.ToolCall)
+	case TurnEnded:
+		c.summary.StopReason = event.StopReason
+		c.summary.Error = event.Error
+		c.summary.Duration = Duration{Milliseconds: event.Time.Sub(c.started).Milliseconds()}
+	}
+}
+
+// addToolCall merges a tool call's fields into what is known of it
+func (c *Collector) addToolCall(update ToolCall) {
+	i, ok := c.toolCalls[update.ID]
+	if !ok {
+		i = len(c.summary.ToolCalls)
+		c.toolCalls[update.ID] = i
+		c.summary.ToolCalls = append(c.summary.ToolCalls, ToolCall{ID: update.ID})
+	}
+
+	call := &c.summary.ToolCalls[i]
+	if update.Title != "" {
+		call.Title = update.Title
+	}
+	if update.Kind != "" {
+		call.Kind = update.Kind
+	}
+	if update.Status != "" {
+		call.Status = update.Status
+	}
+	if update.Input != nil {
+		call.Input = update.Input
+	}
+	for _, path := range update.Files {
+		call.Files = appendPath(call.Files, path)
+	}
+	if changesFiles(call.Kind) {
+		for _, path := range call.Files {
+			c.touched = appendPath(c.touched, path)
+		}
+	}
+}
+
+// Summary returns the summary of the events so far
+func (c *Collector) Summary() Summary {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	summary := c.summary
+	summary.Text = c.text.String()
+	summary.ToolCalls = slices.Clone(summary.ToolCalls)
+	if summary.ToolCalls == nil {
+		summary.ToolCalls = []ToolCall{}
+	}
+	summary.FilesTouched = slices.Sorted(slices.Values(c.touched))
+	if summary.FilesTouched == nil {
+		summary.FilesTouched = []string{}
+	}
+	return summary
+}
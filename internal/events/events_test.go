@@ -0,0 +1,81 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"agentgo/protocol"
+)
+
+var start = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func update(updateType string) protocol.SessionUpdate {
+	return protocol.SessionUpdate{SessionUpdateType: updateType}
+}
+
+func TestFromUpdate(t *testing.T) {
+	message := update(protocol.SessionUpdateAgentMessage)
+	message.Content = &protocol.UpdateContent{Type: "text", Text: "hello"}
+	if event, ok := FromUpdate(message, start); !ok || event.Type != Message || event.Text != "hello" || !event.Time.Equal(start) {
+		t.Errorf("Expected a message event, got %+v, %v", event, ok)
+	}
+
+	userMessage := update(protocol.SessionUpdateUserMessage)
+	userMessage.Content = &protocol.UpdateContent{Type: "text", Text: "fix it"}
+	if event, ok := FromUpdate(userMessage, start); !ok || event.Type != Prompt || event.Text != "fix it" {
+		t.Errorf("Expected a prompt event, got %+v, %v", event, ok)
+	}
+
+	toolCall := update(protocol.SessionUpdateToolCall)
+	toolCall.ToolCallID = "t1"
+	toolCall.Kind = "edit"
+	toolCall.Locations = []protocol.ToolCallLocation{{Path: "/src/a.go", Line: 3}}
+	toolCall.RawInput = map[string]any{"file_path": "/src/a.go", "old_string": "a"}
+	event, ok := FromUpdate(toolCall, start)
+	if !ok || event.Type != ToolCallStarted || event.ToolCall.ID != "t1" {
+		t.Fatalf("Expected a tool call event, got %+v, %v", event, ok)
+	}
+	if !reflect.DeepEqual(event.ToolCall.Files, []string{"/src/a.go"}) {
+		t.Errorf("Expected the file once, got %q", event.ToolCall.Files)
+	}
+
+	if _, ok := FromUpdate(update(protocol.SessionUpdateAvailableCommands), start); ok {
+		t.Error("Expected the command list to be left out")
+	}
+	if _, ok := FromUpdate(update(protocol.SessionUpdateAgentMessage), start); ok {
+		t.Error("Expected an empty chunk to be left out")
+	}
+}
+
+func TestCollector_Summary(t *testing.T) {
+	c := NewCollector(start)
+	c.Add(Event{Type: SessionStarted, SessionID: "s1"})
+	c.Add(Event{Type: Message, Text: "Editing "})
+	c.Add(Event{Type: ToolCallStarted, ToolCall: &ToolCall{ID: "t1", Title: "Read", Kind: "read", Files: []string{"/b.go"}}})
+	c.Add(Event{Type: ToolCallStarted, ToolCall: &ToolCall{ID: "t2", Title: "Edit", Kind: "edit", Status: "pending"}})
+	c.Add(Event{Type: ToolCallUpdated, ToolCall: &ToolCall{ID: "t2", Status: "completed", Files: []string{"/z.go", "/a.go"}}})
+	c.Add(Event{Type: Message, Text: "done."})
+	c.Add(Event{Type: TurnEnded, Time: start.Add(1500 * time.Millisecond), StopReason: protocol.StopReasonEndTurn})
+
+	summary := c.Summary()
+	if summary.SessionID != "s1" || summary.StopReason != "end_turn" || summary.Text != "Editing done." {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if summary.Duration.Milliseconds != 1500 {
+		t.Errorf("Expected 1500ms, got %d", summary.Duration.Milliseconds)
+	}
+	if len(summary.ToolCalls) != 2 || summary.ToolCalls[1].Title != "Edit" || summary.ToolCalls[1].Status != "completed" {
+		t.Errorf("Expected the edit's update merged into it, got %+v", summary.ToolCalls)
+	}
+	if !reflect.DeepEqual(summary.FilesTouched, []string{"/a.go", "/z.go"}) {
+		t.Errorf("Expected only the edited files, sorted, got %q", summary.FilesTouched)
+	}
+}
+
+func TestCollector_EmptySummary(t *testing.T) {
+	summary := NewCollector(start).Summary()
+	if summary.ToolCalls == nil || summary.FilesTouched == nil {
+		t.Error("Expected empty lists rather than nil, so they encode as []")
+	}
+}
@@ -0,0 +1,137 @@
+package lineedit
+
+import (
+	"fmt"
+	"strings"
+
+	"agentgo/internal/render"
+)
+
+// hintStyle draws hints and completions dimmed
+var hintStyle = render.Style{Color: render.Black, Bold: true}
+
+// Completion is a candidate offered when the user presses Tab
+type Completion struct {
+	// Text replaces the text before the cursor when the candidate is chosen
+	Text string
+	// Display is listed instead of Text when set, e.g. just the file name
+	// of a path
+	Display     string
+	Description string
+}
+
+// label is what the candidate is listed as
+func (c Completion) label() string {
+	if c.Display != "" {
+		return c.Display
+	}
+	return c.Text
+}
+
+// Completer offers completions for the text being edited
+type Completer interface {
+	// Complete returns the candidates for the text before the cursor
+	Complete(text string) []Completion
+	// Hint returns a placeholder shown after the text, such as the input a
+	// command expects, or "" for none
+	Hint(text string) string
+}
+
+// SetCompleter sets what Tab completes, or nil to complete nothing
+func (s *State) SetCompleter(completer Completer) {
+	s.completer = completer
+	s.completions = nil
+}
+
+// complete handles Tab. A single candidate is inserted, followed by a space
+// unless it is a directory to complete further; several are listed, after
+// inserting what they have in common.
+func (s *State) complete() {
+	if s.completer == nil {
+		return
+	}
+
+	before := string(s.text[:s.cursor])
+	candidates := s.completer.Complete(before)
+	switch len(candidates) {
+	case 0:
+		s.completions = nil
+	case 1:
+		text := candidates[0].Text
+		if !strings.HasSuffix(text, "/") {
+			text += " "
+		}
+		s.replaceBeforeCursor(text)
+		s.completions = nil
+	default:
+		if prefix := commonPrefix(candidates); len([]rune(prefix)) > len([]rune(before)) {
+			s.replaceBeforeCursor(prefix)
+		}
+		s.completions = candidates
+	}
+}
+
+// updateCompletions narrows the listed candidates as the user keeps typing
+func (s *State) updateCompletions() {
+	if s.completions == nil || s.completer == nil {
+		return
+	}
+	s.completions = s.completer.Complete(string(s.text[:s.cursor]))
+	if len(s.completions) == 0 {
+		s.completions = nil
+	}
+}
+
+func (s *State) replaceBeforeCursor(text string) {
+	s.text = append([]rune(text), s.text[s.cursor:]...)
+	s.cursor = len([]rune(text))
+}
+
+func commonPrefix(candidates []Completion) string {
+	prefix := []rune(candidates[0].Text)
+	for _, candidate := range candidates[1:] {
+		text := []rune(candidate.Text)
+		n := 0
+		for n < len(prefix) && n < len(text) && prefix[n] == text[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
+
+// maxCompletionLines is how many candidates are listed before the rest are
+// summarised
+const maxCompletionLines = 8
+
+// CompletionLines lists candidates one per line with their descriptions
+// aligned, each at most width columns
+func CompletionLines(completions []Completion, width int) []string {
+	nameWidth := 0
+	for _, completion := range completions[:min(len(completions), maxCompletionLines)] {
+		nameWidth = max(nameWidth, render.StringWidth(completion.label()))
+	}
+
+	var lines []string
+	for i, completion := range completions {
+		if i == maxCompletionLines {
+			lines = append(lines, fmt.Sprintf("  … and %d more", len(completions)-i))
+			break
+		}
+		line := "  " + completion.label()
+		if completion.Description != "" {
+			line += strings.Repeat(" ", nameWidth-render.StringWidth(completion.label())+2) + completion.Description
+		}
+		lines = append(lines, render.Truncate(line, width, "…"))
+	}
+	return lines
+}
+
+// HintText is the hint to draw after a line of text, separated from it by a
+// space unless the text already ends with one
+func HintText(line, hint string) string {
+	if hint == "" || line == "" || strings.HasSuffix(line, " ") {
+		return hint
+	}
+	return " " + hint
+}
@@ -0,0 +1,111 @@
+package lineedit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"agentgo/internal/terminal"
+)
+
+// wordCompleter completes words starting with the text before the cursor
+type wordCompleter []string
+
+func (w wordCompleter) Complete(text string) []Completion {
+	var completions []Completion
+	for _, word := range w {
+		if strings.HasPrefix(word, text) {
+			completions = append(completions, Completion{Text: word, Description: "the " + word + " command"})
+		}
+	}
+	return completions
+}
+
+func (w wordCompleter) Hint(text string) string {
+	if text == "/review " {
+		return "focus"
+	}
+	return ""
+}
+
+func TestComplete(t *testing.T) {
+	s := NewState(nil)
+	s.SetCompleter(wordCompleter{"/review", "/reset", "/init"})
+
+	press(s, runes("/i")...)
+	press(s, key(terminal.KeyTab))
+	if s.Text() != "/init " || s.View().Completions != nil {
+		t.Errorf("A single candidate gave %q, expected it inserted", s.Text())
+	}
+
+	s.Reset()
+	press(s, runes("/r")...)
+	press(s, key(terminal.KeyTab))
+	if s.Text() != "/re" {
+		t.Errorf("Several candidates gave %q, expected their common prefix", s.Text())
+	}
+	if completions := s.View().Completions; len(completions) != 2 {
+		t.Errorf("Expected both candidates listed, got %+v", completions)
+	}
+
+	press(s, runes("v")...)
+	if completions := s.View().Completions; len(completions) != 1 || completions[0].Text != "/review" {
+		t.Errorf("Expected the list to narrow while typing, got %+v", completions)
+	}
+
+	press(s, key(terminal.KeyEscape))
+	if s.View().Completions != nil {
+		t.Error("Expected Esc to close the list")
+	}
+}
+
+func TestComplete_Directory(t *testing.T) {
+	s := NewState(nil)
+	s.SetCompleter(wordCompleter{":save docs/"})
+
+	press(s, runes(":save d")...)
+	press(s, key(terminal.KeyTab))
+	if s.Text() != ":save docs/" {
+		t.Errorf("Expected no space after a directory, got %q", s.Text())
+	}
+}
+
+func TestViewHint(t *testing.T) {
+	s := NewState(nil)
+	s.SetCompleter(wordCompleter{"/review"})
+
+	press(s, runes("/review ")...)
+	if hint := s.View().Hint; hint != "focus" {
+		t.Errorf("Hint %q, expected the command's input", hint)
+	}
+	press(s, key(terminal.KeyLeft))
+	if hint := s.View().Hint; hint != "" {
+		t.Errorf("Hint %q, expected none with the cursor inside the text", hint)
+	}
+}
+
+func TestCompletionLines(t *testing.T) {
+	lines := CompletionLines([]Completion{
+		{Text: "/init", Description: "Write a guide"},
+		{Text: "/review", Description: "Review the current changes"},
+		{Text: ":save docs/notes.txt", Display: "notes.txt"},
+	}, 30)
+
+	expected := []string{
+		"  /init      Write a guide",
+		"  /review    Review the curre…",
+		"  notes.txt",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("CompletionLines() = %q, expected %q", lines, expected)
+	}
+}
+
+func TestHintText(t *testing.T) {
+	if got := HintText("/", "Tab to list commands"); got != " Tab to list commands" {
+		t.Errorf("Expected a space before the hint, got %q", got)
+	}
+	if got := HintText("/review ", "focus"); got != "focus" {
+		t.Errorf("Expected no extra space, got %q", got)
+	}
+}
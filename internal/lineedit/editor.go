@@ -0,0 +1,392 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"agentgo/internal/render"
+	"agentgo/internal/terminal"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C on an
+// empty line
+var ErrInterrupted = errors.New("interrupted")
+
+// continuationPrompt starts the lines after the first of a multi-line prompt
+const continuationPrompt = "… "
+
+// Editor reads prompts from the terminal, redrawing the text being edited in
+// place below the output. Output written through the editor while a prompt
+// is being read appears above it, so the agent can keep talking while the
+// user types. When in is not a terminal it reads plain lines.
+type Editor struct {
+	in      *os.File
+	out     io.Writer
+	state   *State
+	painter *render.TextRenderer
+
+	keys  *terminal.KeyReader
+	lines *bufio.Reader
+
+	// keyHandler sees each key before the editor does
+	keyHandler func(key terminal.Key, state *State) bool
+
+	mutex   sync.Mutex
+	restore func() error
+	// reading is true while ReadLine has the terminal in raw mode and the
+	// input area drawn
+	reading bool
+	prompt  string
+	status  []string
+	// cursorRow is the row of the cursor counted from the first row of the
+	// input area
+	cursorRow int
+	// atLineStart is false when output left the cursor mid-line
+	atLineStart bool
+}
+
+// NewEditor creates an editor reading keys from in and drawing on out
+func NewEditor(in *os.File, out io.Writer, history *History) *Editor {
+	return &Editor{
+		in:          in,
+		out:         out,
+		state:       NewState(history),
+		painter:     render.NewTextRenderer(nil, render.PlainText),
+		atLineStart: true,
+	}
+}
+
+// SetCapabilities sets what the terminal can display, for drawing hints and
+// completions
+func (e *Editor) SetCapabilities(capabilities render.Capabilities) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.painter = render.NewTextRenderer(nil, capabilities)
+}
+
+// SetCompleter sets what Tab completes
+func (e *Editor) SetCompleter(completer Completer) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.state.SetCompleter(completer)
+}
+
+// History returns the history browsed and searched from the editor. The
+// editor does not add to it; callers add the lines they accept as prompts.
+func (e *Editor) History() *History {
+	return e.state.History()
+}
+
+// SetKeyHandler sets a function that sees each key before the editor and
+// returns true when it has handled it. It runs with the editor locked, so it
+// may change the state but must not call the editor's methods or block.
+func (e *Editor) SetKeyHandler(handler func(key terminal.Key, state *State) bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.keyHandler = handler
+}
+
+// SetPrompt changes the prompt, including that of a ReadLine in progress
+func (e *Editor) SetPrompt(prompt string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if prompt == e.prompt {
+		return
+	}
+	e.prompt = prompt
+	if e.reading {
+		e.refresh()
+	}
+}
+
+// SetStatus sets the lines shown between the output and the prompt while a
+// line is being read
+func (e *Editor) SetStatus(lines []string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.status = lines
+	if e.reading {
+		e.refresh()
+	}
+}
+
+// Write writes output, moving the input area below it while a line is being
+// read
+func (e *Editor) Write(p []byte) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.reading {
+		return e.out.Write(p)
+	}
+
+	e.erase()
+	_, err := e.out.Write(rawNewlines(p))
+	if len(p) > 0 {
+		e.atLineStart = p[len(p)-1] == '\n'
+	}
+	e.draw(true)
+	return len(p), err
+}
+
+// ReadLine reads one prompt. It returns io.EOF on Ctrl-D and ErrInterrupted
+// on Ctrl-C when nothing has been typed.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !terminal.IsTerminal(e.in) {
+		return e.readPlainLine(prompt)
+	}
+
+	restore, err := terminal.MakeRaw(e.in)
+	if err != nil {
+		return e.readPlainLine(prompt)
+	}
+
+	e.mutex.Lock()
+	e.restore = restore
+	if e.keys == nil {
+		e.keys = terminal.NewKeyReader(e.in)
+	}
+	io.WriteString(e.out, terminal.EnableBracketedPaste)
+	e.prompt = prompt
+	e.state.Reset()
+	e.reading = true
+	e.atLineStart = true
+	e.cursorRow = 0
+	e.draw(true)
+	e.mutex.Unlock()
+
+	for {
+		key, err := e.keys.ReadKey()
+
+		e.mutex.Lock()
+		line, done, err := e.handleKey(key, err)
+		e.mutex.Unlock()
+
+		if done {
+			return line, err
+		}
+	}
+}
+
+// Close returns the terminal to its normal mode when a line is still being
+// read, e.g. when the program quits while waiting for input
+func (e *Editor) Close() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.reading {
+		e.finish()
+	}
+}
+
+// Clear clears the screen, redrawing the input area at the top when a line
+// is being read
+func (e *Editor) Clear() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.clear()
+}
+
+// clear is Clear with the mutex held
+func (e *Editor) clear() {
+	io.WriteString(e.out, "\033[H\033[2J")
+	e.cursorRow = 0
+	e.atLineStart = true
+	if e.reading {
+		e.draw(true)
+	}
+}
+
+// handleKey applies a key, returning done when ReadLine should return. The
+// caller holds the mutex.
+func (e *Editor) handleKey(key terminal.Key, err error) (string, bool, error) {
+	if err != nil {
+		e.finish()
+		return "", true, err
+	}
+
+	if e.keyHandler != nil && e.keyHandler(key, e.state) {
+		e.refresh()
+		return "", false, nil
+	}
+
+	if key.Type == terminal.KeyRune && key.Ctrl && key.Rune == 'l' {
+		e.clear()
+		return "", false, nil
+	}
+
+	switch e.state.HandleKey(key) {
+	case ActionSubmit:
+		line := e.state.Text()
+		e.finish()
+		return line, true, nil
+	case ActionEOF:
+		e.finish()
+		return "", true, io.EOF
+	case ActionInterrupt:
+		io.WriteString(e.out, "^C")
+		e.finish()
+		return "", true, ErrInterrupted
+	}
+
+	e.refresh()
+	return "", false, nil
+}
+
+// readPlainLine reads a line from a pipe or file. A trailing backslash
+// continues the prompt on the next line.
+func (e *Editor) readPlainLine(prompt string) (string, error) {
+	if e.lines == nil {
+		e.lines = bufio.NewReader(e.in)
+	}
+	io.WriteString(e.out, prompt)
+
+	var text strings.Builder
+	for {
+		line, err := e.lines.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if text.Len() > 0 {
+				return text.String(), nil
+			}
+			return "", err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if continued, ok := strings.CutSuffix(line, "\\"); ok && err == nil {
+			text.WriteString(continued + "\n")
+			continue
+		}
+
+		text.WriteString(line)
+		return text.String(), nil
+	}
+}
+
+// refresh redraws the input area in place. The caller holds the mutex.
+func (e *Editor) refresh() {
+	e.erase()
+	e.draw(true)
+}
+
+// erase clears the input area, leaving the cursor where it started. The
+// caller holds the mutex.
+func (e *Editor) erase() {
+	var b strings.Builder
+	if e.cursorRow > 0 {
+		fmt.Fprintf(&b, "\033[%dA", e.cursorRow)
+	}
+	b.WriteString("\r\033[J")
+	io.WriteString(e.out, b.String())
+	e.cursorRow = 0
+}
+
+// draw draws the status lines, when withStatus is set, then the prompt and
+// text, leaving the terminal cursor at the editing position. The caller
+// holds the mutex.
+func (e *Editor) draw(withStatus bool) {
+	width := render.DefaultWidth
+	if w, _, err := terminal.Size(e.in); err == nil && w > 0 {
+		width = w
+	}
+
+	var b strings.Builder
+	// The input area starts on a line of its own
+	if !e.atLineStart {
+		b.WriteString("\r\n")
+		e.atLineStart = true
+	}
+
+	view := e.state.View()
+	rows := 0
+	if withStatus {
+		lines := slices.Clone(e.status)
+		for _, line := range CompletionLines(view.Completions, width-1) {
+			lines = append(lines, e.painter.Paint(hintStyle, line))
+		}
+		for _, line := range lines {
+			b.WriteString(render.Truncate(line, width-1, ""))
+			b.WriteString("\r\n")
+			rows++
+		}
+	}
+
+	promptWidth := render.StringWidth(e.prompt)
+	continuation := continuationPrompt + strings.Repeat(" ", max(promptWidth-render.StringWidth(continuationPrompt), 0))
+
+	cursorRow, cursorColumn := 0, 0
+	for i, line := range view.Lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+			b.WriteString(continuation)
+		} else {
+			b.WriteString(e.prompt)
+		}
+		b.WriteString(line)
+
+		lineWidth := promptWidth + render.StringWidth(line)
+		if hint := HintText(line, view.Hint); withStatus && hint != "" && lineWidth+render.StringWidth(hint) < width {
+			b.WriteString(e.painter.Paint(hintStyle, hint))
+		}
+		if i == view.CursorLine {
+			column := promptWidth + view.CursorColumn
+			cursorRow = rows + column/width
+			cursorColumn = column % width
+		}
+		rows += max((lineWidth+width-1)/width, 1)
+	}
+
+	// A cursor just past a full row sits at the start of a row not drawn yet
+	endRow := rows - 1
+	if cursorRow > endRow {
+		b.WriteString("\r\n")
+		endRow = cursorRow
+	}
+	if up := endRow - cursorRow; up > 0 {
+		fmt.Fprintf(&b, "\033[%dA", up)
+	}
+	b.WriteString("\r")
+	if cursorColumn > 0 {
+		fmt.Fprintf(&b, "\033[%dC", cursorColumn)
+	}
+
+	e.cursorRow = cursorRow
+	io.WriteString(e.out, b.String())
+}
+
+// finish leaves the submitted text, without the status lines, on the lines
+// above the cursor and returns the terminal to its normal mode. The caller
+// holds the mutex.
+func (e *Editor) finish() {
+	e.state.search = nil
+	e.state.cursor = len(e.state.text)
+	e.erase()
+	e.draw(false)
+	io.WriteString(e.out, "\r\n"+terminal.DisableBracketedPaste)
+
+	e.cursorRow = 0
+	e.reading = false
+	if e.restore != nil {
+		e.restore()
+		e.restore = nil
+	}
+}
+
+// rawNewlines turns bare newlines into CRLF, since raw mode no longer does
+func rawNewlines(p []byte) []byte {
+	var b strings.Builder
+	for i, c := range p {
+		if c == '\n' && (i == 0 || p[i-1] != '\r') {
+			b.WriteByte('\r')
+		}
+		b.WriteByte(c)
+	}
+	return []byte(b.String())
+}
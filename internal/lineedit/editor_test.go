@@ -0,0 +1,32 @@
+package lineedit
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEditorWrite_RedrawsBelowOutput(t *testing.T) {
+	var out bytes.Buffer
+	e := NewEditor(nil, &out, nil)
+	e.prompt = "> "
+	e.state.SetText("hi")
+	e.status = []string{"working"}
+	e.reading = true
+
+	e.Write([]byte("agent says\n"))
+
+	expected := "\r\033[J" + "agent says\r\n" + "working\r\n> hi\r\033[4C"
+	if out.String() != expected {
+		t.Errorf("Got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestEditorWrite_PassesThroughWhenNotReading(t *testing.T) {
+	var out bytes.Buffer
+	e := NewEditor(nil, &out, nil)
+
+	e.Write([]byte("a\nb"))
+	if out.String() != "a\nb" {
+		t.Errorf("Got %q, expected the output unchanged", out.String())
+	}
+}
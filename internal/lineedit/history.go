@@ -0,0 +1,162 @@
+package lineedit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultHistorySize is how many entries a history keeps
+const DefaultHistorySize = 1000
+
+// History is the list of submitted prompts, oldest first. When it has a file
+// every new entry is appended to it, one JSON string per line so multi-line
+// prompts survive.
+type History struct {
+	mutex   sync.Mutex
+	path    string
+	limit   int
+	entries []string
+}
+
+// NewHistory creates a history kept only in memory
+func NewHistory(limit int) *History {
+	if limit <= 0 {
+		limit = DefaultHistorySize
+	}
+	return &History{limit: limit}
+}
+
+// LoadHistory reads the history file at path, creating an empty history if
+// it does not exist yet. Lines that cannot be read are skipped.
+func LoadHistory(path string, limit int) (*History, error) {
+	history := NewHistory(limit)
+	history.path = path
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry != "" {
+			history.entries = append(history.entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history %s: %v", path, err)
+	}
+
+	if len(history.entries) > history.limit {
+		history.entries = history.entries[len(history.entries)-history.limit:]
+		// Rewrite the file so it does not grow without bound
+		if err := history.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+
+	return history, nil
+}
+
+// ProjectHistoryPath returns the history file for the project containing
+// dir: its git root, or dir itself outside a repository. Files live under
+// $XDG_STATE_HOME/agentgo/history, or ~/.local/state/agentgo/history.
+func ProjectHistoryPath(dir string) (string, error) {
+	project, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for candidate := project; ; candidate = filepath.Dir(candidate) {
+		if _, err := os.Stat(filepath.Join(candidate, ".git")); err == nil {
+			project = candidate
+			break
+		}
+		if filepath.Dir(candidate) == candidate {
+			break
+		}
+	}
+
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateDir = filepath.Join(home, ".local", "state")
+	}
+
+	sum := sha256.Sum256([]byte(project))
+	name := fmt.Sprintf("%s-%s.jsonl", filepath.Base(project), hex.EncodeToString(sum[:])[:12])
+	return filepath.Join(stateDir, "agentgo", "history", name), nil
+}
+
+// Entries returns a copy of the entries, oldest first
+func (h *History) Entries() []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]string(nil), h.entries...)
+}
+
+// Add appends an entry, skipping blank ones and repeats of the last entry
+func (h *History) Add(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > h.limit {
+		h.entries = h.entries[len(h.entries)-h.limit:]
+	}
+
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func (h *History) rewrite() error {
+	var b strings.Builder
+	for _, entry := range h.entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(h.path, []byte(b.String()), 0o600)
+}
@@ -0,0 +1,117 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistoryAdd(t *testing.T) {
+	history := NewHistory(3)
+	for _, entry := range []string{"one", "", "  ", "two", "two", "three", "four"} {
+		if err := history.Add(entry); err != nil {
+			t.Fatalf("Add(%q) error: %v", entry, err)
+		}
+	}
+
+	expected := []string{"two", "three", "four"}
+	if entries := history.Entries(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Entries %q, expected %q", entries, expected)
+	}
+}
+
+func TestHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.jsonl")
+
+	history, err := LoadHistory(path, 0)
+	if err != nil {
+		t.Fatalf("LoadHistory() error: %v", err)
+	}
+	history.Add("first")
+	history.Add("multi\nline \"quoted\"")
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("History file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("History file mode %v, expected 0600", info.Mode().Perm())
+	}
+
+	reloaded, err := LoadHistory(path, 0)
+	if err != nil {
+		t.Fatalf("LoadHistory() error: %v", err)
+	}
+	expected := []string{"first", "multi\nline \"quoted\""}
+	if entries := reloaded.Entries(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Reloaded %q, expected %q", entries, expected)
+	}
+}
+
+func TestLoadHistoryTrimsToLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	content := "\"one\"\nnot json\n\"two\"\n\"three\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := LoadHistory(path, 2)
+	if err != nil {
+		t.Fatalf("LoadHistory() error: %v", err)
+	}
+	expected := []string{"two", "three"}
+	if entries := history.Entries(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Entries %q, expected %q", entries, expected)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "\"two\"\n\"three\"\n" {
+		t.Errorf("File not rewritten to the limit: %q", data)
+	}
+}
+
+func TestProjectHistoryPath(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+
+	project := filepath.Join(t.TempDir(), "myproject")
+	subdir := filepath.Join(project, "pkg", "sub")
+	if err := os.MkdirAll(subdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(project, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	fromRoot, err := ProjectHistoryPath(project)
+	if err != nil {
+		t.Fatalf("ProjectHistoryPath() error: %v", err)
+	}
+	fromSubdir, err := ProjectHistoryPath(subdir)
+	if err != nil {
+		t.Fatalf("ProjectHistoryPath() error: %v", err)
+	}
+
+	if fromRoot != fromSubdir {
+		t.Errorf("Paths differ within one repository: %s and %s", fromRoot, fromSubdir)
+	}
+	if filepath.Dir(fromRoot) != filepath.Join(state, "agentgo", "history") {
+		t.Errorf("Path %s not under the state directory", fromRoot)
+	}
+	if !strings.HasPrefix(filepath.Base(fromRoot), "myproject-") {
+		t.Errorf("Path %s not named after the project", fromRoot)
+	}
+
+	other, err := ProjectHistoryPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("ProjectHistoryPath() error: %v", err)
+	}
+	if other == fromRoot {
+		t.Errorf("Different projects share history file %s", other)
+	}
+}
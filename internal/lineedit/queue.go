@@ -0,0 +1,99 @@
+package lineedit
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"agentgo/internal/render"
+)
+
+// Queue holds prompts entered while the agent is still working, to be sent
+// in order once it finishes. It is safe for concurrent use.
+type Queue struct {
+	mutex sync.Mutex
+	items []string
+}
+
+// Push adds a prompt to the end of the queue
+func (q *Queue) Push(text string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.items = append(q.items, text)
+}
+
+// PushFront adds a prompt to be sent before the others
+func (q *Queue) PushFront(text string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.items = append([]string{text}, q.items...)
+}
+
+// Pop removes and returns the next prompt to send
+func (q *Queue) Pop() (string, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.items) == 0 {
+		return "", false
+	}
+	text := q.items[0]
+	q.items = q.items[1:]
+	return text, true
+}
+
+// TakeLast removes and returns the prompt queued most recently, so it can be
+// edited or dropped
+func (q *Queue) TakeLast() (string, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.items) == 0 {
+		return "", false
+	}
+	text := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return text, true
+}
+
+// Clear drops every queued prompt, returning how many there were
+func (q *Queue) Clear() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	n := len(q.items)
+	q.items = nil
+	return n
+}
+
+// Items returns the queued prompts, the next one to send first
+func (q *Queue) Items() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return append([]string(nil), q.items...)
+}
+
+// Len returns how many prompts are queued
+func (q *Queue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.items)
+}
+
+// maxQueueLines is how many queued prompts are listed before the rest are
+// summarised
+const maxQueueLines = 3
+
+// QueueLines lists queued prompts one per line, each at most width columns
+func QueueLines(items []string, width int) []string {
+	var lines []string
+	for i, item := range items {
+		if i == maxQueueLines {
+			lines = append(lines, fmt.Sprintf("  … and %d more", len(items)-i))
+			break
+		}
+		line := fmt.Sprintf("  ⏳ %d. %s", i+1, strings.ReplaceAll(item, "\n", "⏎"))
+		lines = append(lines, render.Truncate(line, width, "…"))
+	}
+	return lines
+}
@@ -0,0 +1,46 @@
+package lineedit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	var q Queue
+	q.Push("first")
+	q.Push("second")
+	q.PushFront("urgent")
+
+	if items := q.Items(); !reflect.DeepEqual(items, []string{"urgent", "first", "second"}) {
+		t.Errorf("Items() = %q", items)
+	}
+	if text, ok := q.TakeLast(); !ok || text != "second" {
+		t.Errorf("TakeLast() = %q, %v, expected the newest prompt", text, ok)
+	}
+	if text, ok := q.Pop(); !ok || text != "urgent" {
+		t.Errorf("Pop() = %q, %v, expected the front of the queue", text, ok)
+	}
+	q.Pop()
+	if _, ok := q.Pop(); ok || q.Len() != 0 {
+		t.Error("Expected an empty queue")
+	}
+
+	q.Push("one")
+	q.Push("two")
+	if n := q.Clear(); n != 2 || q.Len() != 0 {
+		t.Errorf("Clear() = %d, expected both prompts dropped", n)
+	}
+}
+
+func TestQueueLines(t *testing.T) {
+	items := []string{"fix the tests", "a\nb", "three", "four", "five"}
+	expected := []string{
+		"  ⏳ 1. fix th…", // the hourglass is two columns wide
+		"  ⏳ 2. a⏎b",
+		"  ⏳ 3. three",
+		"  … and 2 more",
+	}
+	if lines := QueueLines(items, 15); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("QueueLines() = %q, expected %q", lines, expected)
+	}
+}
@@ -0,0 +1,521 @@
+// Package lineedit edits prompts with Emacs-style keys, multi-line input,
+// bracketed paste and persistent history with reverse incremental search.
+// State holds the editing model and is shared by the line interface's
+// Editor and the full-screen interface's input box.
+package lineedit
+
+import (
+	"strings"
+	"unicode"
+
+	"agentgo/internal/render"
+	"agentgo/internal/terminal"
+)
+
+// Action is what a key press asks the owner of the editor to do
+type Action int
+
+const (
+	// ActionNone means the key only changed the text
+	ActionNone Action = iota
+	// ActionSubmit means the text is complete
+	ActionSubmit
+	// ActionEOF means Ctrl-D on an empty line
+	ActionEOF
+	// ActionInterrupt means Ctrl-C on an empty line
+	ActionInterrupt
+)
+
+// State is the text being edited and the cursor within it
+type State struct {
+	text   []rune
+	cursor int
+
+	history      *History
+	historyIndex int
+	draft        []rune
+
+	killed []rune
+	search *search
+
+	completer Completer
+	// completions are the candidates listed after Tab
+	completions []Completion
+}
+
+// search is an active reverse incremental search
+type search struct {
+	query    []rune
+	match    int
+	failed   bool
+	original []rune
+	cursor   int
+}
+
+// NewState creates an empty editing state browsing history, which may be
+// nil. The owner adds the text it accepts to history.
+func NewState(history *History) *State {
+	if history == nil {
+		history = NewHistory(0)
+	}
+	s := &State{history: history}
+	s.Reset()
+	return s
+}
+
+// Reset clears the text and leaves history browsing
+func (s *State) Reset() {
+	s.text = nil
+	s.cursor = 0
+	s.historyIndex = len(s.history.Entries())
+	s.draft = nil
+	s.search = nil
+	s.completions = nil
+}
+
+// Text returns the text being edited
+func (s *State) Text() string {
+	return string(s.text)
+}
+
+// SetText replaces the text, putting the cursor at its end
+func (s *State) SetText(text string) {
+	s.text = []rune(text)
+	s.cursor = len(s.text)
+}
+
+// History returns the history browsed and searched from this state
+func (s *State) History() *History {
+	return s.history
+}
+
+// View is what to show for the current state
+type View struct {
+	// Lines are the lines of text, split at newlines
+	Lines []string
+	// CursorLine and CursorColumn locate the cursor, the column in terminal
+	// columns from the start of its line
+	CursorLine   int
+	CursorColumn int
+	// Search is the reverse search prompt when a search is active, shown
+	// in place of the text
+	Search string
+	// Completions are the candidates listed after Tab
+	Completions []Completion
+	// Hint is a placeholder to show after the text, set when the cursor is
+	// at the end of a single line
+	Hint string
+}
+
+// View describes how to draw the current state
+func (s *State) View() View {
+	if s.search != nil {
+		status := "reverse-i-search"
+		if s.search.failed {
+			status = "failing reverse-i-search"
+		}
+		prompt := "(" + status + ")`" + string(s.search.query) + "': "
+		return View{
+			Lines:        []string{prompt + strings.ReplaceAll(expandTabs(string(s.text)), "\n", "⏎")},
+			CursorColumn: render.StringWidth(prompt) - 3,
+			Search:       prompt,
+		}
+	}
+
+	before := string(s.text[:s.cursor])
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	view := View{
+		Lines:        strings.Split(expandTabs(string(s.text)), "\n"),
+		CursorLine:   strings.Count(before, "\n"),
+		CursorColumn: render.StringWidth(expandTabs(before[lineStart:])),
+		Completions:  s.completions,
+	}
+	if s.completer != nil && s.cursor == len(s.text) && len(view.Lines) == 1 {
+		view.Hint = s.completer.Hint(before)
+	}
+	return view
+}
+
+// expandTabs replaces tabs, which have no width of their own, with spaces
+func expandTabs(text string) string {
+	return strings.ReplaceAll(text, "\t", "    ")
+}
+
+// HandleKey applies a key press
+func (s *State) HandleKey(key terminal.Key) Action {
+	switch key.Type {
+	case terminal.KeyTab:
+		if s.search == nil {
+			s.complete()
+			return ActionNone
+		}
+	case terminal.KeyEscape:
+		if s.completions != nil {
+			s.completions = nil
+			return ActionNone
+		}
+	}
+	defer s.updateCompletions()
+
+	if s.search != nil {
+		if s.handleSearchKey(key) {
+			return ActionNone
+		}
+	}
+
+	if key.Type == terminal.KeyRune && key.Ctrl {
+		return s.handleControl(key.Rune)
+	}
+	if key.Type == terminal.KeyRune && key.Alt {
+		s.handleAlt(key.Rune)
+		return ActionNone
+	}
+
+	switch key.Type {
+	case terminal.KeyRune:
+		s.insert([]rune{key.Rune})
+	case terminal.KeyPaste:
+		s.insert([]rune(key.Text))
+	case terminal.KeyEnter:
+		return s.enter(key)
+	case terminal.KeyBackspace:
+		if key.Alt {
+			s.kill(s.previousWord(), s.cursor)
+		} else {
+			s.deleteRange(s.previousBoundary(), s.cursor)
+		}
+	case terminal.KeyDelete:
+		s.deleteRange(s.cursor, s.nextBoundary())
+	case terminal.KeyLeft:
+		if key.Ctrl || key.Alt {
+			s.cursor = s.previousWord()
+		} else {
+			s.cursor = s.previousBoundary()
+		}
+	case terminal.KeyRight:
+		if key.Ctrl || key.Alt {
+			s.cursor = s.nextWord()
+		} else {
+			s.cursor = s.nextBoundary()
+		}
+	case terminal.KeyHome:
+		s.cursor = s.lineStart()
+	case terminal.KeyEnd:
+		s.cursor = s.lineEnd()
+	case terminal.KeyUp:
+		s.up()
+	case terminal.KeyDown:
+		s.down()
+	}
+	return ActionNone
+}
+
+func (s *State) handleControl(r rune) Action {
+	switch r {
+	case 'a':
+		s.cursor = s.lineStart()
+	case 'e':
+		s.cursor = s.lineEnd()
+	case 'b':
+		s.cursor = s.previousBoundary()
+	case 'f':
+		s.cursor = s.nextBoundary()
+	case 'p':
+		s.up()
+	case 'n':
+		s.down()
+	case 'h':
+		s.deleteRange(s.previousBoundary(), s.cursor)
+	case 'd':
+		if len(s.text) == 0 {
+			return ActionEOF
+		}
+		s.deleteRange(s.cursor, s.nextBoundary())
+	case 'k':
+		end := s.lineEnd()
+		if end == s.cursor && end < len(s.text) {
+			// At the end of a line, kill the newline to join the next one
+			end++
+		}
+		s.kill(s.cursor, end)
+	case 'u':
+		s.kill(s.lineStart(), s.cursor)
+	case 'w':
+		s.kill(s.previousWord(), s.cursor)
+	case 'y':
+		s.insert(s.killed)
+	case 't':
+		s.transpose()
+	case 'r':
+		s.startSearch()
+	case 'c':
+		if len(s.text) == 0 {
+			return ActionInterrupt
+		}
+		s.Reset()
+	}
+	return ActionNone
+}
+
+func (s *State) handleAlt(r rune) {
+	switch r {
+	case 'b':
+		s.cursor = s.previousWord()
+	case 'f':
+		s.cursor = s.nextWord()
+	case 'd':
+		s.kill(s.cursor, s.nextWord())
+	}
+}
+
+// enter submits the text, or continues it on a new line after Shift-Enter,
+// Alt-Enter or a trailing backslash
+func (s *State) enter(key terminal.Key) Action {
+	if key.Shift || key.Alt {
+		s.insert([]rune{'\n'})
+		return ActionNone
+	}
+	if s.cursor == len(s.text) && s.cursor > 0 && s.text[s.cursor-1] == '\\' {
+		s.text[s.cursor-1] = '\n'
+		return ActionNone
+	}
+
+	return ActionSubmit
+}
+
+func (s *State) insert(runes []rune) {
+	text := make([]rune, 0, len(s.text)+len(runes))
+	text = append(text, s.text[:s.cursor]...)
+	text = append(text, runes...)
+	s.text = append(text, s.text[s.cursor:]...)
+	s.cursor += len(runes)
+}
+
+func (s *State) deleteRange(start, end int) {
+	if start >= end {
+		return
+	}
+	s.text = append(s.text[:start], s.text[end:]...)
+	s.cursor = start
+}
+
+func (s *State) kill(start, end int) {
+	if start >= end {
+		return
+	}
+	s.killed = append([]rune(nil), s.text[start:end]...)
+	s.deleteRange(start, end)
+}
+
+func (s *State) transpose() {
+	if s.cursor == 0 || len(s.text) < 2 {
+		return
+	}
+	if s.cursor == len(s.text) {
+		s.cursor--
+	}
+	s.text[s.cursor-1], s.text[s.cursor] = s.text[s.cursor], s.text[s.cursor-1]
+	s.cursor++
+}
+
+// boundaries returns the rune offsets where graphemes start, plus the end
+func (s *State) boundaries() []int {
+	offsets := []int{0}
+	offset := 0
+	for _, grapheme := range render.Graphemes(string(s.text)) {
+		offset += len([]rune(grapheme))
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+func (s *State) previousBoundary() int {
+	previous := 0
+	for _, offset := range s.boundaries() {
+		if offset >= s.cursor {
+			break
+		}
+		previous = offset
+	}
+	return previous
+}
+
+func (s *State) nextBoundary() int {
+	for _, offset := range s.boundaries() {
+		if offset > s.cursor {
+			return offset
+		}
+	}
+	return len(s.text)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func (s *State) previousWord() int {
+	i := s.cursor
+	for i > 0 && !isWordRune(s.text[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(s.text[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (s *State) nextWord() int {
+	i := s.cursor
+	for i < len(s.text) && !isWordRune(s.text[i]) {
+		i++
+	}
+	for i < len(s.text) && isWordRune(s.text[i]) {
+		i++
+	}
+	return i
+}
+
+func (s *State) lineStart() int {
+	i := s.cursor
+	for i > 0 && s.text[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+func (s *State) lineEnd() int {
+	i := s.cursor
+	for i < len(s.text) && s.text[i] != '\n' {
+		i++
+	}
+	return i
+}
+
+// up moves to the line above, or to the previous history entry from the
+// first line
+func (s *State) up() {
+	start := s.lineStart()
+	if start == 0 {
+		s.browseHistory(-1)
+		return
+	}
+
+	column := render.StringWidth(string(s.text[start:s.cursor]))
+	s.cursor = start - 1
+	s.cursor = s.columnInLine(s.lineStart(), column)
+}
+
+// down moves to the line below, or to the next history entry from the last
+// line
+func (s *State) down() {
+	end := s.lineEnd()
+	if end == len(s.text) {
+		s.browseHistory(1)
+		return
+	}
+
+	column := render.StringWidth(string(s.text[s.lineStart():s.cursor]))
+	s.cursor = s.columnInLine(end+1, column)
+}
+
+// columnInLine returns the offset in the line starting at start that is
+// closest to column without passing it
+func (s *State) columnInLine(start, column int) int {
+	width := 0
+	offset := start
+	for _, grapheme := range render.Graphemes(string(s.text[start:])) {
+		if grapheme == "\n" || grapheme == "\r\n" {
+			break
+		}
+		w := render.GraphemeWidth(grapheme)
+		if width+w > column {
+			break
+		}
+		width += w
+		offset += len([]rune(grapheme))
+	}
+	return offset
+}
+
+func (s *State) browseHistory(direction int) {
+	entries := s.history.Entries()
+	next := s.historyIndex + direction
+	if next < 0 || next > len(entries) {
+		return
+	}
+
+	if s.historyIndex == len(entries) {
+		s.draft = append([]rune(nil), s.text...)
+	}
+	s.historyIndex = next
+
+	if next == len(entries) {
+		s.SetText(string(s.draft))
+	} else {
+		s.SetText(entries[next])
+	}
+}
+
+func (s *State) startSearch() {
+	s.search = &search{
+		match:    len(s.history.Entries()),
+		original: append([]rune(nil), s.text...),
+		cursor:   s.cursor,
+	}
+}
+
+// handleSearchKey applies a key during reverse search, returning false when
+// the key ends the search and should be handled as usual
+func (s *State) handleSearchKey(key terminal.Key) bool {
+	search := s.search
+
+	switch {
+	case key.Type == terminal.KeyRune && key.Ctrl && key.Rune == 'r':
+		s.findMatch(search.match - 1)
+		return true
+	case key.Type == terminal.KeyRune && key.Ctrl && key.Rune == 'g', key.Type == terminal.KeyEscape:
+		s.text = search.original
+		s.cursor = search.cursor
+		s.search = nil
+		return true
+	case key.Type == terminal.KeyRune && !key.Ctrl && !key.Alt:
+		search.query = append(search.query, key.Rune)
+		s.findMatch(search.match)
+		return true
+	case key.Type == terminal.KeyBackspace:
+		if len(search.query) > 0 {
+			search.query = search.query[:len(search.query)-1]
+		}
+		s.findMatch(len(s.history.Entries()))
+		return true
+	case key.Type == terminal.KeyEnter:
+		// Accept the match for editing rather than submitting it
+		s.search = nil
+		return true
+	}
+
+	s.search = nil
+	return false
+}
+
+// findMatch searches history backwards from index for the query
+func (s *State) findMatch(from int) {
+	search := s.search
+	entries := s.history.Entries()
+	query := string(search.query)
+
+	from = min(from, len(entries)-1)
+	for i := from; i >= 0; i-- {
+		if strings.Contains(entries[i], query) {
+			search.match = i
+			search.failed = false
+			s.text = []rune(entries[i])
+			s.cursor = len(s.text)
+			if at := strings.LastIndex(entries[i], query); at >= 0 {
+				s.cursor = len([]rune(entries[i][:at]))
+			}
+			return
+		}
+	}
+	search.failed = true
+}
@@ -0,0 +1,206 @@
+package lineedit
+
+import (
+	"reflect"
+	"testing"
+
+	"agentgo/internal/terminal"
+)
+
+func runes(s string) []terminal.Key {
+	var keys []terminal.Key
+	for _, r := range s {
+		keys = append(keys, terminal.Key{Type: terminal.KeyRune, Rune: r})
+	}
+	return keys
+}
+
+func ctrl(r rune) terminal.Key {
+	return terminal.Key{Type: terminal.KeyRune, Rune: r, Ctrl: true}
+}
+
+func alt(r rune) terminal.Key {
+	return terminal.Key{Type: terminal.KeyRune, Rune: r, Alt: true}
+}
+
+func key(t terminal.KeyType) terminal.Key {
+	return terminal.Key{Type: t}
+}
+
+// press applies keys, returning the action of the last one
+func press(s *State, keys ...terminal.Key) Action {
+	action := ActionNone
+	for _, k := range keys {
+		action = s.HandleKey(k)
+	}
+	return action
+}
+
+func TestHandleKeyEditing(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []terminal.Key
+		text   string
+		cursor int
+	}{
+		{"typing", runes("hello"), "hello", 5},
+		{"insert in middle", append(append(runes("hllo"), ctrl('a'), ctrl('f')), runes("e")...), "hello", 2},
+		{"backspace", append(runes("helloo"), key(terminal.KeyBackspace)), "hello", 5},
+		{"delete under cursor", append(runes("hello"), ctrl('a'), ctrl('d')), "ello", 0},
+		{"kill to end", append(runes("hello world"), alt('b'), ctrl('k')), "hello ", 6},
+		{"kill to start", append(runes("hello world"), alt('b'), ctrl('u')), "world", 0},
+		{"kill word", append(runes("hello world"), ctrl('w')), "hello ", 6},
+		{"kill and yank", append(runes("hello world"), ctrl('w'), ctrl('a'), ctrl('y')), "worldhello ", 5},
+		{"alt delete word", append(runes("hello world"), ctrl('a'), alt('d')), " world", 0},
+		{"transpose", append(runes("hlelo"), ctrl('a'), ctrl('f'), ctrl('f'), ctrl('t')), "hello", 3},
+		{"ctrl arrows", append(runes("one two three"), terminal.Key{Type: terminal.KeyLeft, Ctrl: true}, terminal.Key{Type: terminal.KeyLeft, Ctrl: true}), "one two three", 4},
+		{"paste", []terminal.Key{{Type: terminal.KeyPaste, Text: "a\nb"}}, "a\nb", 3},
+		{"shift enter", append(runes("a"), terminal.Key{Type: terminal.KeyEnter, Shift: true}), "a\n", 2},
+		{"alt enter", append(runes("a"), terminal.Key{Type: terminal.KeyEnter, Alt: true}), "a\n", 2},
+		{"backslash continues", append(runes(`a\`), key(terminal.KeyEnter)), "a\n", 2},
+		{"ctrl-c clears", append(runes("abc"), ctrl('c')), "", 0},
+		{"emoji is one step", append(runes("a👍🏽"), key(terminal.KeyLeft)), "a👍🏽", 1},
+		{"home and end on a line", append(runes("ab\ncd"), key(terminal.KeyHome)), "ab\ncd", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewState(nil)
+			if action := press(s, tt.keys...); action != ActionNone {
+				t.Errorf("Action %v, expected none", action)
+			}
+			if s.Text() != tt.text || s.cursor != tt.cursor {
+				t.Errorf("Got %q with cursor %d, expected %q with cursor %d", s.Text(), s.cursor, tt.text, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestHandleKeyActions(t *testing.T) {
+	s := NewState(nil)
+	if action := press(s, ctrl('d')); action != ActionEOF {
+		t.Errorf("Ctrl-D on empty line gave %v, expected EOF", action)
+	}
+	if action := press(s, ctrl('c')); action != ActionInterrupt {
+		t.Errorf("Ctrl-C on empty line gave %v, expected interrupt", action)
+	}
+
+	press(s, runes("hi")...)
+	if action := press(s, key(terminal.KeyEnter)); action != ActionSubmit {
+		t.Errorf("Enter gave %v, expected submit", action)
+	}
+	if s.Text() != "hi" {
+		t.Errorf("Text %q, expected %q", s.Text(), "hi")
+	}
+}
+
+func TestMultiLineCursorMovement(t *testing.T) {
+	s := NewState(nil)
+	press(s, runes("first line")...)
+	press(s, terminal.Key{Type: terminal.KeyEnter, Shift: true})
+	press(s, runes("ab")...)
+
+	press(s, key(terminal.KeyUp))
+	if view := s.View(); view.CursorLine != 0 || view.CursorColumn != 2 {
+		t.Errorf("After up, cursor at %d:%d, expected 0:2", view.CursorLine, view.CursorColumn)
+	}
+
+	press(s, key(terminal.KeyEnd), key(terminal.KeyDown))
+	if view := s.View(); view.CursorLine != 1 || view.CursorColumn != 2 {
+		t.Errorf("After down, cursor at %d:%d, expected 1:2", view.CursorLine, view.CursorColumn)
+	}
+
+	view := s.View()
+	if !reflect.DeepEqual(view.Lines, []string{"first line", "ab"}) {
+		t.Errorf("Lines %q", view.Lines)
+	}
+}
+
+func TestViewExpandsTabs(t *testing.T) {
+	s := NewState(nil)
+	press(s, terminal.Key{Type: terminal.KeyPaste, Text: "\tx"})
+
+	view := s.View()
+	if view.Lines[0] != "    x" || view.CursorColumn != 5 {
+		t.Errorf("Got %q with cursor column %d", view.Lines[0], view.CursorColumn)
+	}
+}
+
+func TestHistoryBrowsing(t *testing.T) {
+	history := NewHistory(0)
+	history.Add("first")
+	history.Add("second")
+
+	s := NewState(history)
+	press(s, runes("draft")...)
+
+	press(s, key(terminal.KeyUp))
+	if s.Text() != "second" {
+		t.Errorf("Up gave %q, expected %q", s.Text(), "second")
+	}
+	press(s, ctrl('p'), key(terminal.KeyUp))
+	if s.Text() != "first" {
+		t.Errorf("Up past the oldest entry gave %q, expected %q", s.Text(), "first")
+	}
+	press(s, key(terminal.KeyDown), ctrl('n'))
+	if s.Text() != "draft" {
+		t.Errorf("Down back to the draft gave %q, expected %q", s.Text(), "draft")
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	history := NewHistory(0)
+	history.Add("build the parser")
+	history.Add("fix the tests")
+	history.Add("build the docs")
+
+	s := NewState(history)
+	press(s, ctrl('r'))
+	press(s, runes("build")...)
+	if s.Text() != "build the docs" {
+		t.Errorf("Search gave %q, expected the newest match", s.Text())
+	}
+	if view := s.View(); view.Search != "(reverse-i-search)`build': " {
+		t.Errorf("Search prompt %q", view.Search)
+	}
+
+	press(s, ctrl('r'))
+	if s.Text() != "build the parser" {
+		t.Errorf("Ctrl-R again gave %q, expected the older match", s.Text())
+	}
+
+	press(s, ctrl('r'))
+	if view := s.View(); view.Search != "(failing reverse-i-search)`build': " {
+		t.Errorf("Search prompt %q, expected a failing search", view.Search)
+	}
+
+	// Enter accepts the match for editing without submitting it
+	if action := press(s, key(terminal.KeyEnter)); action != ActionNone {
+		t.Errorf("Enter in search gave %v, expected none", action)
+	}
+	if s.Text() != "build the parser" || s.View().Search != "" {
+		t.Errorf("After accepting, got %q with search %q", s.Text(), s.View().Search)
+	}
+
+	press(s, ctrl('a'), ctrl('k'), ctrl('r'))
+	press(s, runes("tests")...)
+	press(s, ctrl('g'))
+	if s.Text() != "" {
+		t.Errorf("Ctrl-G gave %q, expected the text from before the search", s.Text())
+	}
+}
+
+func TestReverseSearchEndsOnEditingKey(t *testing.T) {
+	history := NewHistory(0)
+	history.Add("fix the tests")
+
+	s := NewState(history)
+	press(s, ctrl('r'))
+	press(s, runes("fix")...)
+	press(s, ctrl('e'))
+	press(s, runes("!")...)
+
+	if s.Text() != "fix the tests!" {
+		t.Errorf("Got %q, expected the match to be edited", s.Text())
+	}
+}
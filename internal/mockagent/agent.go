@@ -0,0 +1,287 @@
+package mockagent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+
+	"agentgo/protocol"
+)
+
+// Agent is a scripted stand-in for an ACP agent speaking JSON-RPC over a
+// pair of streams, normally its own stdin and stdout
+type Agent struct {
+	script    *Script
+	reader    *protocol.MessageReader
+	encoder   *json.Encoder
+	logger    *log.Logger
+	sessionID string
+	nextTurn  int
+	nextID    int
+	// cancelled is set when the client cancels the running turn
+	cancelled bool
+}
+
+// NewAgent creates a mock agent that reads requests from in and writes to out
+func NewAgent(script *Script, in io.Reader, out io.Writer, logger *log.Logger) *Agent {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	sessionID := script.SessionID
+	if sessionID == "" {
+		sessionID = "mock-session"
+	}
+
+	return &Agent{
+		script:    script,
+		reader:    protocol.NewMessageReader(in, 0, logger),
+		encoder:   json.NewEncoder(out),
+		logger:    logger,
+		sessionID: sessionID,
+		nextID:    1000,
+	}
+}
+
+// Run serves requests until the client closes its end of the stream
+func (a *Agent) Run() error {
+	for {
+		message, err := a.reader.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := a.handle(message); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *Agent) handle(message *protocol.Message) error {
+	switch message.Method {
+	case protocol.MethodInitialize:
+		capabilities := protocol.PromptCapabilities{Image: true, EmbeddedContext: true}
+		if a.script.PromptCapabilities != nil {
+			capabilities = *a.script.PromptCapabilities
+		}
+		return a.reply(message.ID, protocol.InitializeResult{
+			ProtocolVersion:   protocol.ProtocolVersion,
+			AgentCapabilities: protocol.AgentCapabilities{PromptCapabilities: capabilities},
+		})
+	case protocol.MethodSessionNew:
+		return a.reply(message.ID, protocol.SessionNewResult{
+			SessionID: a.sessionID,
+			Modes:     a.script.Modes,
+			Models:    a.script.Models,
+		})
+	case protocol.MethodSessionSetMode:
+		return a.setMode(message)
+	case protocol.MethodSessionSetModel:
+		return a.setModel(message)
+	case protocol.MethodSessionPrompt:
+		stopReason, err := a.runTurn()
+		if err != nil {
+			return err
+		}
+		return a.reply(message.ID, protocol.ResponseResult{StopReason: stopReason})
+	case protocol.MethodSessionCancel:
+		a.cancelled = true
+		return nil
+	case "":
+		a.logger.Printf("ignoring unexpected response %s", message.Raw)
+		return nil
+	}
+
+	if !message.IsRequest() {
+		return nil
+	}
+	return a.replyError(message.ID, protocol.ErrorCodeMethodNotFound, fmt.Sprintf("Method not found: %s", message.Method))
+}
+
+// setMode switches to one of the script's modes and reports the change in a
+// current_mode_update, as agents do
+func (a *Agent) setMode(message *protocol.Message) error {
+	var params protocol.SetSessionModeParams
+	json.Unmarshal(message.Params, &params)
+
+	modes := a.script.Modes
+	if modes == nil || !slices.ContainsFunc(modes.AvailableModes, func(m protocol.SessionMode) bool { return m.ID == params.ModeID }) {
+		return a.replyError(message.ID, protocol.ErrorCodeInvalidParams, fmt.Sprintf("Unknown mode: %s", params.ModeID))
+	}
+	modes.CurrentModeID = params.ModeID
+	update, err := json.Marshal(protocol.SessionUpdate{
+		SessionUpdateType: protocol.SessionUpdateCurrentMode,
+		CurrentModeID:     params.ModeID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := a.notify(update); err != nil {
+		return err
+	}
+	return a.reply(message.ID, map[string]any{})
+}
+
+func (a *Agent) setModel(message *protocol.Message) error {
+	var params protocol.SetSessionModelParams
+	json.Unmarshal(message.Params, &params)
+
+	models := a.script.Models
+	if models == nil || !slices.ContainsFunc(models.AvailableModels, func(m protocol.ModelInfo) bool { return m.ModelID == params.ModelID }) {
+		return a.replyError(message.ID, protocol.ErrorCodeInvalidParams, fmt.Sprintf("Unknown model: %s", params.ModelID))
+	}
+	models.CurrentModelID = params.ModelID
+	return a.reply(message.ID, map[string]any{})
+}
+
+func (a *Agent) runTurn() (string, error) {
+	if a.nextTurn >= len(a.script.Turns) {
+		return "end_turn", nil
+	}
+
+	turn := a.script.Turns[a.nextTurn]
+	a.nextTurn++
+
+	a.cancelled = false
+	if err := a.runSteps(turn.Steps); err != nil {
+		return "", err
+	}
+	if a.cancelled {
+		return protocol.StopReasonCancelled, nil
+	}
+
+	if turn.StopReason == "" {
+		return "end_turn", nil
+	}
+	return turn.StopReason, nil
+}
+
+// runSteps runs steps until the turn is cancelled. A cancellation is only
+// seen while waiting for the client to reply to a request.
+func (a *Agent) runSteps(steps []Step) error {
+	for _, step := range steps {
+		if a.cancelled {
+			return nil
+		}
+		if step.DelayMs > 0 {
+			time.Sleep(time.Duration(step.DelayMs) * time.Millisecond)
+		}
+
+		switch {
+		case step.Update != nil:
+			if err := a.notify(step.Update); err != nil {
+				return err
+			}
+		case step.Request != nil:
+			reply, err := a.request(step.Request)
+			if err != nil {
+				return err
+			}
+			if branch, ok := selectBranch(step.Branches, reply); ok {
+				if err := a.runSteps(branch); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Agent) notify(update json.RawMessage) error {
+	return a.send(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  protocol.MethodSessionUpdate,
+		"params": map[string]any{
+			"sessionId": a.sessionID,
+			"update":    update,
+		},
+	})
+}
+
+// request sends a request to the client and waits for its reply
+func (a *Agent) request(request *ScriptRequest) (*protocol.Message, error) {
+	a.nextID++
+	id := a.nextID
+
+	params := request.Params
+	if params == nil {
+		params = json.RawMessage(`{}`)
+	}
+	if err := a.send(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  request.Method,
+		"params":  params,
+	}); err != nil {
+		return nil, err
+	}
+
+	for {
+		message, err := a.reader.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("waiting for reply to %s: %w", request.Method, err)
+		}
+
+		if message.IsResponse() && string(message.ID) == fmt.Sprint(id) {
+			return message, nil
+		}
+		if err := a.handle(message); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// selectBranch picks the steps that follow a reply
+func selectBranch(branches map[string][]Step, reply *protocol.Message) ([]Step, bool) {
+	if len(branches) == 0 {
+		return nil, false
+	}
+
+	key := "default"
+	if len(reply.Error) > 0 {
+		key = "error"
+	} else {
+		var result protocol.ToolPermissionResult
+		if json.Unmarshal(reply.Result, &result) == nil {
+			switch {
+			case result.Outcome.Outcome == protocol.PermissionOutcomeCancelled:
+				key = protocol.PermissionOutcomeCancelled
+			case result.Outcome.OptionID != "":
+				key = result.Outcome.OptionID
+			}
+		}
+	}
+
+	if branch, ok := branches[key]; ok {
+		return branch, true
+	}
+	branch, ok := branches["default"]
+	return branch, ok
+}
+
+func (a *Agent) reply(id json.RawMessage, result any) error {
+	return a.send(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result":  result,
+	})
+}
+
+func (a *Agent) replyError(id json.RawMessage, code int, text string) error {
+	return a.send(protocol.ErrorResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   protocol.ResponseError{Code: code, Message: text},
+	})
+}
+
+func (a *Agent) send(message any) error {
+	return a.encoder.Encode(message)
+}
@@ -0,0 +1,238 @@
+package mockagent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agentgo/protocol"
+)
+
+const scriptEnv = "AGENTGO_MOCK_AGENT_SCRIPT"
+
+// TestMain lets the test binary act as the mock agent process, so
+// BinaryIOProvider can be exercised against a real child process
+func TestMain(m *testing.M) {
+	if scriptFile := os.Getenv(scriptEnv); scriptFile != "" {
+		if err := Main([]string{"-script", scriptFile}); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const testScript = `{
+	"sessionId": "scripted",
+	"turns": [
+		{
+			"steps": [
+				{"update": {"sessionUpdate": "agent_message_chunk", "content": {"type": "text", "text": "Let me edit that"}}},
+				{
+					"request": {
+						"method": "session/request_permission",
+						"params": {"toolCall": {"toolCallId": "tool-1", "rawInput": {"command": "ls"}}, "options": [{"optionId": "allow", "name": "Allow"}, {"optionId": "reject", "name": "Reject"}]}
+					},
+					"branches": {
+						"allow": [{"update": {"sessionUpdate": "agent_message_chunk", "content": {"type": "text", "text": "allowed"}}}],
+						"reject": [{"update": {"sessionUpdate": "agent_message_chunk", "content": {"type": "text", "text": "rejected"}}}]
+					}
+				},
+				{
+					"request": {"method": "fs/read_text_file", "params": {"path": "/tmp/x"}},
+					"branches": {
+						"error": [{"update": {"sessionUpdate": "agent_message_chunk", "content": {"type": "text", "text": "no fs support"}}}]
+					}
+				}
+			],
+			"stopReason": "end_turn"
+		}
+	]
+}`
+
+func TestAgent_EndToEndOverStdio(t *testing.T) {
+	for _, choice := range []string{protocol.OptionIDAllowOnce, protocol.OptionIDRejectOnce} {
+		t.Run(choice, func(t *testing.T) {
+			scriptFile := filepath.Join(t.TempDir(), "script.json")
+			if err := os.WriteFile(scriptFile, []byte(testScript), 0o644); err != nil {
+				t.Fatalf("Failed to write script: %v", err)
+			}
+			t.Setenv(scriptEnv, scriptFile)
+
+			executable, err := os.Executable()
+			if err != nil {
+				t.Fatalf("Failed to find test binary: %v", err)
+			}
+
+			conn, err := protocol.OpenAcpConnection(protocol.NewBinaryIOProvider(executable, "-test.run=^$"))
+			if err != nil {
+				t.Fatalf("OpenAcpConnection() error: %v", err)
+			}
+			defer conn.Close()
+
+			sessionID, err := conn.InitializeSession()
+			if err != nil {
+				t.Fatalf("InitializeSession() error: %v", err)
+			}
+			if sessionID != "scripted" {
+				t.Errorf("Expected session id scripted, got %q", sessionID)
+			}
+			if capabilities := conn.PromptCapabilities(); !capabilities.Image || !capabilities.EmbeddedContext {
+				t.Errorf("Expected the default prompt capabilities, got %+v", capabilities)
+			}
+
+			var mutex sync.Mutex
+			var texts []string
+			protocol.OnNotification(conn.Registry(), protocol.MethodSessionUpdate, func(raw []byte, req protocol.SessionUpdateRequest) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if req.Params.Update.Content != nil {
+					texts = append(texts, req.Params.Update.Content.Text)
+				}
+				return nil
+			})
+			protocol.OnRequest(conn.Registry(), protocol.MethodSessionRequestPermission, func(acpConn *protocol.AcpConnection, raw []byte, req protocol.SessionRequestPermissionRequest) error {
+				return acpConn.SendToolResponse(req.ID, choice)
+			})
+
+			ch := make(chan protocol.TurnResult)
+			go conn.StreamResponses(ch)
+
+			if err := conn.SendMessage("edit the file"); err != nil {
+				t.Fatalf("SendMessage() error: %v", err)
+			}
+
+			select {
+			case <-ch:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for the turn to end")
+			}
+
+			branch := map[string]string{
+				protocol.OptionIDAllowOnce:  "allowed",
+				protocol.OptionIDRejectOnce: "rejected",
+			}[choice]
+			expected := []string{"Let me edit that", branch, "no fs support"}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if len(texts) != len(expected) {
+				t.Fatalf("Expected updates %q, got %q", expected, texts)
+			}
+			for i := range expected {
+				if texts[i] != expected[i] {
+					t.Errorf("Expected updates %q, got %q", expected, texts)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestScriptFromRecording(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "session.jsonl")
+	lines := []string{
+		`{"type":"header","format_version":2,"agentgo_version":"test","session_id":"recorded","started_at":"2025-01-01T00:00:00Z"}`,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","id":0,"result":{"sessionId":"recorded"}}}`,
+		`{"timestamp":"2025-01-01T00:00:01Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{"sessionId":"recorded","update":{"sessionUpdate":"agent_message_chunk"}}}}`,
+		`{"timestamp":"2025-01-01T00:00:02Z","direction":"in","data":{"jsonrpc":"2.0","id":3,"method":"session/request_permission","params":{"options":[]}}}`,
+		`{"timestamp":"2025-01-01T00:00:03Z","direction":"in","data":{"jsonrpc":"2.0","id":1,"result":{"stopReason":"end_turn"}}}`,
+	}
+	if err := os.WriteFile(recordingFile, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write recording: %v", err)
+	}
+
+	script, err := ScriptFromRecording(recordingFile)
+	if err != nil {
+		t.Fatalf("ScriptFromRecording() error: %v", err)
+	}
+
+	if script.SessionID != "recorded" {
+		t.Errorf("Expected session id recorded, got %q", script.SessionID)
+	}
+	if len(script.Turns) != 1 {
+		t.Fatalf("Expected 1 turn, got %d", len(script.Turns))
+	}
+
+	turn := script.Turns[0]
+	if len(turn.Steps) != 2 || turn.Steps[0].Update == nil || turn.Steps[1].Request == nil {
+		t.Fatalf("Unexpected steps %+v", turn.Steps)
+	}
+	if turn.Steps[1].Request.Method != protocol.MethodSessionRequestPermission {
+		t.Errorf("Expected a permission request, got %q", turn.Steps[1].Request.Method)
+	}
+	if turn.StopReason != "end_turn" {
+		t.Errorf("Expected end_turn, got %q", turn.StopReason)
+	}
+}
+
+func TestSelectBranch(t *testing.T) {
+	branches := map[string][]Step{
+		"allow":     {{DelayMs: 1}},
+		"cancelled": {{DelayMs: 2}},
+		"error":     {{DelayMs: 3}},
+		"default":   {{DelayMs: 4}},
+	}
+
+	tests := []struct {
+		reply string
+		delay int
+	}{
+		{`{"jsonrpc":"2.0","id":1,"result":{"outcome":{"outcome":"selected","optionId":"allow"}}}`, 1},
+		{`{"jsonrpc":"2.0","id":1,"result":{"outcome":{"outcome":"cancelled"}}}`, 2},
+		{`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"nope"}}`, 3},
+		{`{"jsonrpc":"2.0","id":1,"result":{"outcome":{"outcome":"selected","optionId":"reject"}}}`, 4},
+	}
+
+	for _, tt := range tests {
+		message := &protocol.Message{}
+		if err := json.Unmarshal([]byte(tt.reply), message); err != nil {
+			t.Fatal(err)
+		}
+		branch, ok := selectBranch(branches, message)
+		if !ok || branch[0].DelayMs != tt.delay {
+			t.Errorf("selectBranch(%s) picked %v, expected the branch with delay %d", tt.reply, branch, tt.delay)
+		}
+	}
+}
+
+func TestAgent_SetMode(t *testing.T) {
+	script := &Script{Modes: &protocol.SessionModeState{
+		CurrentModeID:  "default",
+		AvailableModes: []protocol.SessionMode{{ID: "default", Name: "Default"}, {ID: "plan", Name: "Plan"}},
+	}}
+	requests := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"session/new","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"session/set_mode","params":{"sessionId":"mock-session","modeId":"plan"}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"session/set_mode","params":{"sessionId":"mock-session","modeId":"yolo"}}`,
+	}, "\n") + "\n"
+
+	var out strings.Builder
+	if err := NewAgent(script, strings.NewReader(requests), &out, nil).Run(); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 messages, got %q", lines)
+	}
+	var created struct {
+		Result protocol.SessionNewResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &created); err != nil || created.Result.Modes == nil {
+		t.Fatalf("Expected modes in the session/new result, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"currentModeId":"plan"`) {
+		t.Errorf("Expected a current_mode_update, got %s", lines[1])
+	}
+	if !strings.Contains(lines[2], `"id":2`) || !strings.Contains(lines[2], `"result"`) {
+		t.Errorf("Expected a result for the known mode, got %s", lines[2])
+	}
+	if !strings.Contains(lines[3], `"error"`) {
+		t.Errorf("Expected an error for the unknown mode, got %s", lines[3])
+	}
+}
@@ -0,0 +1,36 @@
+package mockagent
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+)
+
+// Main runs the mock agent on stdin and stdout, following a script or a
+// two-way recording
+func Main(args []string) error {
+	flags := flag.NewFlagSet("mock-agent", flag.ContinueOnError)
+	scriptFile := flags.String("script", "", "JSON script describing the agent's turns")
+	recordingFile := flags.String("recording", "", "Two-way recording to play the agent's side of")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var script *Script
+	var err error
+	switch {
+	case *scriptFile != "":
+		script, err = LoadScript(*scriptFile)
+	case *recordingFile != "":
+		script, err = ScriptFromRecording(*recordingFile)
+	default:
+		err = errors.New("mock-agent needs -script or -recording")
+	}
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stderr, "mock-agent: ", log.LstdFlags)
+	return NewAgent(script, os.Stdin, os.Stdout, logger).Run()
+}
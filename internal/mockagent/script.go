@@ -0,0 +1,131 @@
+package mockagent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"agentgo/protocol"
+)
+
+// Script describes how the mock agent behaves. Each session/prompt runs the
+// next turn; once the turns run out, prompts end immediately with end_turn.
+type Script struct {
+	SessionID string `json:"sessionId"`
+	Turns     []Turn `json:"turns"`
+
+	// Modes and Models are offered in the session/new result, and switched
+	// with session/set_mode and session/set_model
+	Modes  *protocol.SessionModeState  `json:"modes,omitempty"`
+	Models *protocol.SessionModelState `json:"models,omitempty"`
+
+	// PromptCapabilities are reported in the initialize result. Without
+	// them the agent accepts images and embedded resources.
+	PromptCapabilities *protocol.PromptCapabilities `json:"promptCapabilities,omitempty"`
+}
+
+// Turn is the agent's side of a single prompt
+type Turn struct {
+	Steps      []Step `json:"steps"`
+	StopReason string `json:"stopReason,omitempty"`
+}
+
+// Step is one action within a turn. Exactly one of Update or Request is set.
+type Step struct {
+	// DelayMs pauses before the step, to mimic a streaming agent
+	DelayMs int `json:"delayMs,omitempty"`
+
+	// Update is sent as the update of a session/update notification
+	Update json.RawMessage `json:"update,omitempty"`
+
+	// Request is sent to the client, and the agent waits for the reply
+	Request *ScriptRequest `json:"request,omitempty"`
+
+	// Branches continue the turn depending on the client's reply, keyed by the
+	// selected permission optionId, "cancelled" when the permission request
+	// was cancelled, "error" for error replies, or "default"
+	Branches map[string][]Step `json:"branches,omitempty"`
+}
+
+// ScriptRequest is a request the agent sends to the client, such as
+// session/request_permission or fs/read_text_file
+type ScriptRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// LoadScript reads a JSON script file
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	script := &Script{}
+	if err := json.Unmarshal(data, script); err != nil {
+		return nil, fmt.Errorf("invalid mock agent script %s: %v", path, err)
+	}
+	return script, nil
+}
+
+// ScriptFromRecording builds a script that replays the agent's side of a
+// two-way recording. Requests the agent made are sent again and their replies
+// awaited, but the recorded branch is always followed.
+func ScriptFromRecording(path string) (*Script, error) {
+	conversation, err := protocol.ReadRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.Header == nil {
+		return nil, fmt.Errorf("%s is a version 1 recording without our side of the conversation", path)
+	}
+
+	script := &Script{SessionID: conversation.SessionID}
+	var turn *Turn
+
+	for _, recorded := range conversation.Messages {
+		if !recorded.IsInbound() {
+			continue
+		}
+
+		var message protocol.Message
+		if err := json.Unmarshal(recorded.Data, &message); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case message.Method == protocol.MethodSessionUpdate:
+			var params struct {
+				Update json.RawMessage `json:"update"`
+			}
+			if err := json.Unmarshal(message.Params, &params); err != nil {
+				return nil, err
+			}
+			if turn == nil {
+				turn = &Turn{}
+			}
+			turn.Steps = append(turn.Steps, Step{Update: params.Update})
+
+		case message.IsRequest():
+			if turn == nil {
+				turn = &Turn{}
+			}
+			turn.Steps = append(turn.Steps, Step{
+				Request: &ScriptRequest{Method: message.Method, Params: message.Params},
+			})
+
+		case message.IsResponse():
+			var result protocol.ResponseResult
+			_ = json.Unmarshal(message.Result, &result)
+			if result.StopReason == "" || turn == nil {
+				// The session/new result, which the agent answers itself
+				continue
+			}
+			turn.StopReason = result.StopReason
+			script.Turns = append(script.Turns, *turn)
+			turn = nil
+		}
+	}
+
+	return script, nil
+}
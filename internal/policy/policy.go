@@ -0,0 +1,136 @@
+// Package policy decides permission requests without asking the user, by
+// rules keyed on the kind of tool the agent wants to run
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"agentgo/protocol"
+	"agentgo/providers/claude"
+)
+
+// Decision is what a rule does with a permission request
+type Decision string
+
+const (
+	// Ask shows the request to the user
+	Ask Decision = "ask"
+	// Allow selects the agent's allow-once option
+	Allow Decision = "allow"
+	// Reject selects the agent's reject-once option
+	Reject Decision = "reject"
+)
+
+// Decisions lists the decisions a rule can take
+var Decisions = []Decision{Ask, Allow, Reject}
+
+// Tools lists the kinds of tool rules apply to. "other" covers requests
+// that are not recognised as a command, edit or write.
+var Tools = []string{string(claude.ToolBash), string(claude.ToolEdit), string(claude.ToolWrite), "other"}
+
+// ParseDecision reads a decision, ignoring case
+func ParseDecision(text string) (Decision, error) {
+	for _, decision := range Decisions {
+		if strings.EqualFold(text, string(decision)) {
+			return decision, nil
+		}
+	}
+	return "", fmt.Errorf("unknown decision %q, expected ask, allow or reject", text)
+}
+
+// Policy holds a decision for each kind of tool; tools without a rule are
+// asked about. It is safe for concurrent use.
+type Policy struct {
+	mutex sync.Mutex
+	rules map[string]Decision
+}
+
+// New creates a policy that asks about every request
+func New() *Policy {
+	return &Policy{rules: map[string]Decision{}}
+}
+
+// Parse reads rules written as tool=decision, separated by commas, such as
+// "bash=allow,write=reject"
+func Parse(rules string) (*Policy, error) {
+	p := New()
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		tool, text, found := strings.Cut(rule, "=")
+		if !found {
+			return nil, fmt.Errorf("rule %q is not written as tool=decision", rule)
+		}
+		decision, err := ParseDecision(strings.TrimSpace(text))
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Set(strings.TrimSpace(tool), decision); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Set sets the decision for a kind of tool
+func (p *Policy) Set(tool string, decision Decision) error {
+	tool = strings.ToLower(tool)
+	if !slices.Contains(Tools, tool) {
+		return fmt.Errorf("unknown tool %q, expected one of %s", tool, strings.Join(Tools, ", "))
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if decision == Ask {
+		delete(p.rules, tool)
+	} else {
+		p.rules[tool] = decision
+	}
+	return nil
+}
+
+// Rule returns the decision for a kind of tool
+func (p *Policy) Rule(tool string) Decision {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if decision, ok := p.rules[strings.ToLower(tool)]; ok {
+		return decision
+	}
+	return Ask
+}
+
+// Decide returns the option to select for a request, or false when the user
+// should be asked. A rule whose option the agent does not offer asks too.
+func (p *Policy) Decide(params protocol.SessionRequestPermissionParams) (protocol.PermissionOption, bool) {
+	decision := p.Rule(ToolOf(params.ToolCall.RawInput))
+
+	var kind, id string
+	switch decision {
+	case Allow:
+		kind, id = "allow_once", protocol.OptionIDAllowOnce
+	case Reject:
+		kind, id = "reject_once", protocol.OptionIDRejectOnce
+	default:
+		return protocol.PermissionOption{}, false
+	}
+
+	for _, option := range params.Options {
+		if option.Kind == kind || option.OptionID == id {
+			return option, true
+		}
+	}
+	return protocol.PermissionOption{}, false
+}
+
+// ToolOf names the kind of tool a request's raw input belongs to
+func ToolOf(rawInput map[string]any) string {
+	if tool := claude.ClassifyToolInput(rawInput); tool != claude.ToolUnknown {
+		return string(tool)
+	}
+	return "other"
+}
@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"testing"
+
+	"agentgo/protocol"
+)
+
+var options = []protocol.PermissionOption{
+	{OptionID: "allow_always", Name: "Always Allow", Kind: "allow_always"},
+	{OptionID: "yes", Name: "Allow", Kind: "allow_once"},
+	{OptionID: "no", Name: "Reject", Kind: "reject_once"},
+}
+
+func request(rawInput map[string]any) protocol.SessionRequestPermissionParams {
+	return protocol.SessionRequestPermissionParams{
+		ToolCall: protocol.ToolCall{ToolCallID: "t1", RawInput: rawInput},
+		Options:  options,
+	}
+}
+
+func TestPolicy_Decide(t *testing.T) {
+	p := New()
+	if err := p.Set("bash", Allow); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Set("Write", Reject); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		rawInput map[string]any
+		option   string
+		decided  bool
+	}{
+		{"command allowed", map[string]any{"command": "ls"}, "yes", true},
+		{"write rejected", map[string]any{"file_path": "a.go", "content": "x"}, "no", true},
+		{"edit asked", map[string]any{"file_path": "a.go", "old_string": "a", "new_string": "b"}, "", false},
+		{"unknown asked", map[string]any{"url": "https://example.com"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			option, decided := p.Decide(request(tt.rawInput))
+			if decided != tt.decided || option.OptionID != tt.option {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.option, tt.decided, option.OptionID, decided)
+			}
+		})
+	}
+}
+
+func TestPolicy_DecideWithoutMatchingOption(t *testing.T) {
+	p := New()
+	p.Set("other", Reject)
+
+	params := request(nil)
+	params.Options = options[:2]
+	if _, decided := p.Decide(params); decided {
+		t.Error("Expected a request without a reject option to be asked")
+	}
+}
+
+func TestPolicy_SetAskRemovesRule(t *testing.T) {
+	p := New()
+	p.Set("edit", Allow)
+	p.Set("edit", Ask)
+	if rule := p.Rule("edit"); rule != Ask {
+		t.Errorf("Expected ask, got %s", rule)
+	}
+	if err := p.Set("browser", Allow); err == nil {
+		t.Error("Expected an error for an unknown tool")
+	}
+}
+
+func TestParseDecision(t *testing.T) {
+	if decision, err := ParseDecision("ALLOW"); err != nil || decision != Allow {
+		t.Errorf("Expected allow, got %q, %v", decision, err)
+	}
+	if _, err := ParseDecision("maybe"); err == nil {
+		t.Error("Expected an error for an unknown decision")
+	}
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse("bash=allow, Write=reject,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tool, expected := range map[string]Decision{"bash": Allow, "write": Reject, "edit": Ask} {
+		if rule := p.Rule(tool); rule != expected {
+			t.Errorf("Rule(%q) = %s, expected %s", tool, rule, expected)
+		}
+	}
+
+	for _, rules := range []string{"bash", "bash=maybe", "browser=allow"} {
+		if _, err := Parse(rules); err == nil {
+			t.Errorf("Parse(%q) expected an error", rules)
+		}
+	}
+}
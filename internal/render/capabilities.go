@@ -0,0 +1,144 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"agentgo/internal/terminal"
+)
+
+// DefaultWidth is assumed when the terminal width cannot be determined
+const DefaultWidth = 80
+
+// ColorDepth is the number of colors an output can show
+type ColorDepth int
+
+const (
+	ColorNone ColorDepth = iota
+	Color16
+	Color256
+	ColorTrue
+)
+
+// Capabilities describes what an output can display
+type Capabilities struct {
+	ColorDepth ColorDepth
+	Width      int
+	Unicode    bool
+}
+
+// ColorMode overrides whether colors are used
+type ColorMode string
+
+const (
+	// ColorAuto uses colors on terminals that support them
+	ColorAuto ColorMode = "auto"
+	// ColorAlways uses colors even on pipes and files, and despite NO_COLOR
+	ColorAlways ColorMode = "always"
+	// ColorNever turns colors off
+	ColorNever ColorMode = "never"
+)
+
+// ColorModes lists the color modes
+var ColorModes = []ColorMode{ColorAuto, ColorAlways, ColorNever}
+
+// ParseColorMode reads a color mode, ignoring case
+func ParseColorMode(text string) (ColorMode, error) {
+	for _, mode := range ColorModes {
+		if strings.EqualFold(text, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown color mode %q, expected auto, always or never", text)
+}
+
+// colorMode is the mode DetectCapabilities applies, set with SetColorMode
+var colorMode = ColorAuto
+
+// SetColorMode sets the color mode for the outputs detected afterwards
+func SetColorMode(mode ColorMode) {
+	colorMode = mode
+}
+
+// PlainText is for outputs that are not terminals, such as pipes and files
+var PlainText = Capabilities{ColorDepth: ColorNone, Width: DefaultWidth, Unicode: true}
+
+// DetectCapabilities inspects the environment to find what f can display.
+// Files and pipes get plain text; NO_COLOR and TERM=dumb turn colors off.
+func DetectCapabilities(f *os.File) Capabilities {
+	isTerminal := terminal.IsTerminal(f)
+	capabilities := detectCapabilities(isTerminal, os.Getenv)
+	capabilities.ColorDepth = applyColorMode(capabilities.ColorDepth, colorMode, os.Getenv)
+	if isTerminal {
+		if width, _, err := terminal.Size(f); err == nil && width > 0 {
+			capabilities.Width = width
+		}
+	}
+	return capabilities
+}
+
+func detectCapabilities(terminal bool, getenv func(string) string) Capabilities {
+	capabilities := Capabilities{
+		ColorDepth: detectColorDepth(terminal, getenv),
+		Width:      DefaultWidth,
+		Unicode:    detectUnicode(getenv),
+	}
+
+	if columns, err := strconv.Atoi(getenv("COLUMNS")); err == nil && columns > 0 {
+		capabilities.Width = columns
+	}
+
+	return capabilities
+}
+
+func detectColorDepth(terminal bool, getenv func(string) string) ColorDepth {
+	if !terminal || getenv("NO_COLOR") != "" {
+		return ColorNone
+	}
+
+	term := getenv("TERM")
+	switch colorTerm := strings.ToLower(getenv("COLORTERM")); {
+	case term == "dumb":
+		return ColorNone
+	case colorTerm == "truecolor" || colorTerm == "24bit":
+		return ColorTrue
+	case strings.Contains(term, "256color"):
+		return Color256
+	default:
+		return Color16
+	}
+}
+
+// applyColorMode overrides the detected color depth. Forced colors follow
+// the terminal type, with at least 16 colors.
+func applyColorMode(depth ColorDepth, mode ColorMode, getenv func(string) string) ColorDepth {
+	switch mode {
+	case ColorNever:
+		return ColorNone
+	case ColorAlways:
+		withColor := func(name string) string {
+			if name == "NO_COLOR" {
+				return ""
+			}
+			return getenv(name)
+		}
+		return max(detectColorDepth(true, withColor), Color16)
+	}
+	return depth
+}
+
+// detectUnicode follows the locale, using the first of LC_ALL, LC_CTYPE and
+// LANG that is set. Without a locale we assume a modern terminal.
+func detectUnicode(getenv func(string) string) bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		locale := getenv(name)
+		if locale == "" {
+			continue
+		}
+		locale = strings.ToLower(locale)
+		return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+	}
+	return true
+}
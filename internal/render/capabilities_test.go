@@ -0,0 +1,116 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectCapabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		terminal bool
+		env      map[string]string
+		expected Capabilities
+	}{
+		{
+			name:     "pipe",
+			terminal: false,
+			env:      map[string]string{"TERM": "xterm-256color"},
+			expected: Capabilities{ColorDepth: ColorNone, Width: DefaultWidth, Unicode: true},
+		},
+		{
+			name:     "basic terminal",
+			terminal: true,
+			env:      map[string]string{"TERM": "xterm", "LANG": "en_US.UTF-8"},
+			expected: Capabilities{ColorDepth: Color16, Width: DefaultWidth, Unicode: true},
+		},
+		{
+			name:     "256 colors and width",
+			terminal: true,
+			env:      map[string]string{"TERM": "screen-256color", "COLUMNS": "132"},
+			expected: Capabilities{ColorDepth: Color256, Width: 132, Unicode: true},
+		},
+		{
+			name:     "truecolor",
+			terminal: true,
+			env:      map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"},
+			expected: Capabilities{ColorDepth: ColorTrue, Width: DefaultWidth, Unicode: true},
+		},
+		{
+			name:     "NO_COLOR",
+			terminal: true,
+			env:      map[string]string{"TERM": "xterm-256color", "NO_COLOR": "1"},
+			expected: Capabilities{ColorDepth: ColorNone, Width: DefaultWidth, Unicode: true},
+		},
+		{
+			name:     "dumb terminal with C locale",
+			terminal: true,
+			env:      map[string]string{"TERM": "dumb", "LC_ALL": "C", "LANG": "en_US.UTF-8"},
+			expected: Capabilities{ColorDepth: ColorNone, Width: DefaultWidth, Unicode: false},
+		},
+		{
+			name:     "invalid width",
+			terminal: true,
+			env:      map[string]string{"COLUMNS": "wide"},
+			expected: Capabilities{ColorDepth: Color16, Width: DefaultWidth, Unicode: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(name string) string { return tt.env[name] }
+			if got := detectCapabilities(tt.terminal, getenv); got != tt.expected {
+				t.Errorf("detectCapabilities() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectCapabilities_FileIsPlainText(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if depth := DetectCapabilities(f).ColorDepth; depth != ColorNone {
+		t.Errorf("Expected no colors for a file, got %v", depth)
+	}
+}
+
+func TestApplyColorMode(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	tests := []struct {
+		name     string
+		depth    ColorDepth
+		mode     ColorMode
+		env      map[string]string
+		expected ColorDepth
+	}{
+		{"auto keeps the detected depth", Color256, ColorAuto, nil, Color256},
+		{"never turns colors off", ColorTrue, ColorNever, nil, ColorNone},
+		{"always colors a pipe", ColorNone, ColorAlways, map[string]string{"TERM": "xterm-256color"}, Color256},
+		{"always beats NO_COLOR", ColorNone, ColorAlways, map[string]string{"NO_COLOR": "1"}, Color16},
+		{"always beats a dumb terminal", ColorNone, ColorAlways, map[string]string{"TERM": "dumb"}, Color16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if depth := applyColorMode(tt.depth, tt.mode, env(tt.env)); depth != tt.expected {
+				t.Errorf("applyColorMode() = %v, expected %v", depth, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseColorMode(t *testing.T) {
+	if mode, err := ParseColorMode("Always"); err != nil || mode != ColorAlways {
+		t.Errorf("Expected always, got %q, %v", mode, err)
+	}
+	if _, err := ParseColorMode("sometimes"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
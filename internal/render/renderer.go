@@ -0,0 +1,243 @@
+// Package render draws the client's terminal output. Providers write through a
+// Renderer rather than to stdout, so output can be sent to files, pipes or
+// other views and styled for what the output supports.
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"agentgo/internal/terminal"
+)
+
+// Color is an ANSI foreground color
+type Color int
+
+const (
+	DefaultColor Color = 0
+	Black        Color = 30
+	Red          Color = 31
+	Green        Color = 32
+	Yellow       Color = 33
+	Blue         Color = 34
+	Magenta      Color = 35
+	Cyan         Color = 36
+	White        Color = 37
+)
+
+// Style is a text color and weight
+type Style struct {
+	Color Color
+	Bold  bool
+}
+
+// ListItem is one numbered entry of a List
+type ListItem struct {
+	Text  string
+	Style Style
+}
+
+// Renderer draws text and simple layouts onto an output
+type Renderer interface {
+	io.Writer
+
+	// Capabilities reports what the output can display
+	Capabilities() Capabilities
+
+	// Paint returns text wrapped in the escapes for style, or text unchanged
+	// when the output has no colors
+	Paint(style Style, text string) string
+
+	Printf(format string, args ...any)
+	Println(args ...any)
+
+	// Box draws lines inside a titled frame as wide as the output, with the
+	// frame drawn in border. Lines too long for the frame are wrapped.
+	Box(border Style, title string, lines []string)
+
+	// List draws items as a numbered list
+	List(items []ListItem)
+
+	// Rule draws a horizontal line across the output
+	Rule(style Style)
+
+	// Diff draws the lines that differ between before and after
+	Diff(before, after string)
+}
+
+// minLayoutWidth keeps boxes usable on very narrow terminals
+const minLayoutWidth = 20
+
+// TextRenderer renders onto a stream of text, such as a terminal or a file
+type TextRenderer struct {
+	out io.Writer
+
+	mutex        sync.Mutex
+	capabilities Capabilities
+}
+
+// NewTextRenderer creates a renderer writing to out
+func NewTextRenderer(out io.Writer, capabilities Capabilities) *TextRenderer {
+	return &TextRenderer{
+		out:          out,
+		capabilities: capabilities,
+	}
+}
+
+// Stdout creates a renderer for the process's standard output
+func Stdout() *TextRenderer {
+	return NewTextRenderer(os.Stdout, DetectCapabilities(os.Stdout))
+}
+
+func (r *TextRenderer) Write(p []byte) (int, error) {
+	return r.out.Write(p)
+}
+
+func (r *TextRenderer) Capabilities() Capabilities {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.capabilities
+}
+
+// SetWidth changes the width layouts are drawn at
+func (r *TextRenderer) SetWidth(width int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.capabilities.Width = width
+}
+
+// FollowResizes keeps the layout width in step with the terminal behind f,
+// so everything drawn after a resize fits the new size. Call stop to end it.
+func (r *TextRenderer) FollowResizes(f *os.File) (stop func()) {
+	resized := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	terminal.NotifyResize(resized)
+
+	go func() {
+		for {
+			select {
+			case <-resized:
+				if width, _, err := terminal.Size(f); err == nil && width > 0 {
+					r.SetWidth(width)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(resized)
+			close(done)
+		})
+	}
+}
+
+func (r *TextRenderer) layoutWidth() int {
+	return max(r.Capabilities().Width, minLayoutWidth)
+}
+
+func (r *TextRenderer) Paint(style Style, text string) string {
+	if r.Capabilities().ColorDepth == ColorNone || style == (Style{}) {
+		return text
+	}
+
+	code := "0"
+	if style.Bold {
+		code = "1"
+	}
+	if style.Color != DefaultColor {
+		code += fmt.Sprintf(";%d", style.Color)
+	}
+	return "\033[" + code + "m" + text + "\033[0m"
+}
+
+func (r *TextRenderer) Printf(format string, args ...any) {
+	fmt.Fprintf(r.out, format, args...)
+}
+
+func (r *TextRenderer) Println(args ...any) {
+	fmt.Fprintln(r.out, args...)
+}
+
+func (r *TextRenderer) Box(border Style, title string, lines []string) {
+	width := r.layoutWidth()
+	frame := r.frame()
+
+	header := frame.horizontal + " " + Truncate(title, width-6, "...") + " "
+	fill := max(width-2-StringWidth(header), 0)
+	r.Println(r.Paint(border, frame.topLeft+header+strings.Repeat(frame.horizontal, fill)+frame.topRight))
+
+	side := r.Paint(border, frame.vertical)
+	for _, line := range lines {
+		for _, part := range strings.Split(line, "\n") {
+			if part == "" {
+				r.Println(side)
+				continue
+			}
+			for _, wrapped := range Wrap(part, width-2) {
+				r.Println(side + " " + wrapped)
+			}
+		}
+	}
+
+	r.Println(r.Paint(border, frame.bottomLeft+strings.Repeat(frame.horizontal, width-2)+frame.bottomRight))
+}
+
+func (r *TextRenderer) List(items []ListItem) {
+	for i, item := range items {
+		r.Println(r.Paint(item.Style, fmt.Sprintf("%d. %s", i+1, item.Text)))
+	}
+}
+
+func (r *TextRenderer) Rule(style Style) {
+	r.Println(r.Paint(style, strings.Repeat(r.frame().horizontal, r.layoutWidth())))
+}
+
+// Diff shows the lines shared by before and after around the changed ones as
+// context, then the removed lines followed by the added lines
+func (r *TextRenderer) Diff(before, after string) {
+	removed := strings.Split(before, "\n")
+	added := strings.Split(after, "\n")
+
+	prefix := 0
+	for prefix < len(removed) && prefix < len(added) && removed[prefix] == added[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(removed)-prefix && suffix < len(added)-prefix &&
+		removed[len(removed)-1-suffix] == added[len(added)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range removed[:prefix] {
+		r.Println("  " + line)
+	}
+	for _, line := range removed[prefix : len(removed)-suffix] {
+		r.Println(r.Paint(Style{Color: Red}, "- "+line))
+	}
+	for _, line := range added[prefix : len(added)-suffix] {
+		r.Println(r.Paint(Style{Color: Green}, "+ "+line))
+	}
+	for _, line := range removed[len(removed)-suffix:] {
+		r.Println("  " + line)
+	}
+}
+
+type frameChars struct {
+	horizontal, vertical                       string
+	topLeft, topRight, bottomLeft, bottomRight string
+}
+
+func (r *TextRenderer) frame() frameChars {
+	if r.Capabilities().Unicode {
+		return frameChars{"─", "│", "╭", "╮", "╰", "╯"}
+	}
+	return frameChars{"-", "|", "+", "+", "+", "+"}
+}
@@ -0,0 +1,154 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var colorTerminal = Capabilities{ColorDepth: Color16, Width: DefaultWidth, Unicode: true}
+
+func TestPaint(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities Capabilities
+		style        Style
+		expected     string
+	}{
+		{"bold color", colorTerminal, Style{Color: Cyan, Bold: true}, "\033[1;36mtext\033[0m"},
+		{"plain color", colorTerminal, Style{Color: Red}, "\033[0;31mtext\033[0m"},
+		{"bold only", colorTerminal, Style{Bold: true}, "\033[1mtext\033[0m"},
+		{"no style", colorTerminal, Style{}, "text"},
+		{"no colors", PlainText, Style{Color: Cyan, Bold: true}, "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewTextRenderer(&bytes.Buffer{}, tt.capabilities)
+			if got := r.Paint(tt.style, "text"); got != tt.expected {
+				t.Errorf("Paint() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBox(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities Capabilities
+		expected     string
+	}{
+		{
+			name:         "unicode",
+			capabilities: Capabilities{Width: 20, Unicode: true},
+			expected:     "╭─ Title ──────────╮\n│ first\n│\n│ second\n╰──────────────────╯\n",
+		},
+		{
+			name:         "ascii",
+			capabilities: Capabilities{Width: 20},
+			expected:     "+- Title ----------+\n| first\n|\n| second\n+------------------+\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			NewTextRenderer(&output, tt.capabilities).Box(Style{Color: Cyan}, "Title", []string{"first", "", "second"})
+			if output.String() != tt.expected {
+				t.Errorf("Box() drew:\n%s\nexpected:\n%s", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestBox_FitsWidth(t *testing.T) {
+	var output bytes.Buffer
+	r := NewTextRenderer(&output, Capabilities{Width: 24, Unicode: true})
+	r.Box(Style{}, "A title much too long for the box", []string{
+		"  📁 File: /a/very/long/path/to/some/file.go",
+		"two\nlines",
+	})
+
+	expected := "╭─ A title much to... ─╮\n" +
+		"│   📁 File:\n" +
+		"│   /a/very/long/path/to\n" +
+		"│   /some/file.go\n" +
+		"│ two\n" +
+		"│ lines\n" +
+		"╰──────────────────────╯\n"
+	if output.String() != expected {
+		t.Errorf("Box() drew:\n%s\nexpected:\n%s", output.String(), expected)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n") {
+		if width := StringWidth(line); width > 24 {
+			t.Errorf("Line %q is %d columns wide", line, width)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	var output bytes.Buffer
+	NewTextRenderer(&output, colorTerminal).List([]ListItem{
+		{Text: "done", Style: Style{Color: Green}},
+		{Text: "todo"},
+	})
+
+	expected := "\033[0;32m1. done\033[0m\n2. todo\n"
+	if output.String() != expected {
+		t.Errorf("List() = %q, expected %q", output.String(), expected)
+	}
+}
+
+func TestRule(t *testing.T) {
+	var output bytes.Buffer
+	NewTextRenderer(&output, Capabilities{Width: 25, Unicode: true}).Rule(Style{Bold: true})
+
+	if output.String() != strings.Repeat("─", 25)+"\n" {
+		t.Errorf("Rule() = %q", output.String())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		expected string
+	}{
+		{
+			name:     "single line",
+			before:   "want := 2",
+			after:    "want := 3",
+			expected: "- want := 2\n+ want := 3\n",
+		},
+		{
+			name:     "context around change",
+			before:   "a\nb\nc",
+			after:    "a\nB\nB2\nc",
+			expected: "  a\n- b\n+ B\n+ B2\n  c\n",
+		},
+		{
+			name:     "insertion only",
+			before:   "a\nc",
+			after:    "a\nb\nc",
+			expected: "  a\n+ b\n  c\n",
+		},
+		{
+			name:     "unchanged",
+			before:   "same",
+			after:    "same",
+			expected: "  same\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			NewTextRenderer(&output, PlainText).Diff(tt.before, tt.after)
+			if output.String() != tt.expected {
+				t.Errorf("Diff() = %q, expected %q", output.String(), tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// spinnerInterval is how often the spinner advances
+const spinnerInterval = 100 * time.Millisecond
+
+var (
+	unicodeSpinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	asciiSpinnerFrames   = []string{"|", "/", "-", "\\"}
+)
+
+// SpinnerText is the status shown while work has been running for elapsed,
+// e.g. "⠹ Working… 12s"
+func SpinnerText(label string, elapsed time.Duration, unicode bool) string {
+	frames := asciiSpinnerFrames
+	if unicode {
+		frames = unicodeSpinnerFrames
+	}
+	frame := frames[int(elapsed/spinnerInterval)%len(frames)]
+	return fmt.Sprintf("%s %s %s", frame, label, formatElapsed(elapsed))
+}
+
+func formatElapsed(elapsed time.Duration) string {
+	seconds := int(elapsed / time.Second)
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return fmt.Sprintf("%dm%02ds", seconds/60, seconds%60)
+}
+
+// Spinner shows an animated status line with the elapsed time while work is
+// in progress. Output written through it clears the status line first and
+// the spinner is redrawn below it, so the two never interleave. The status
+// line is only drawn while the cursor is at the start of a line, so it stays
+// out of the way of prompts waiting for input.
+type Spinner struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	animate bool
+	painter *TextRenderer
+
+	label       string
+	started     time.Time
+	active      bool
+	paused      int
+	shown       bool
+	atLineStart bool
+	stop        chan struct{}
+}
+
+// NewSpinner creates a spinner writing to out. When animate is false it draws
+// nothing and writes pass straight through, e.g. when out is not a terminal.
+func NewSpinner(out io.Writer, capabilities Capabilities, animate bool) *Spinner {
+	return &Spinner{
+		out:         out,
+		animate:     animate,
+		painter:     NewTextRenderer(nil, capabilities),
+		atLineStart: true,
+	}
+}
+
+// Write writes p to the output, moving the status line below it
+func (s *Spinner) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.clear()
+	n, err := s.out.Write(p)
+	if n > 0 {
+		s.atLineStart = p[n-1] == '\n'
+	}
+	s.draw()
+	return n, err
+}
+
+// Start shows label with a spinner and the time since now
+func (s *Spinner) Start(label string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.active {
+		s.label = label
+		return
+	}
+
+	s.label = label
+	s.started = time.Now()
+	s.active = true
+	if !s.animate {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	go s.tick(s.stop)
+	s.draw()
+}
+
+// Stop removes the status line and returns how long the spinner ran
+func (s *Spinner) Stop() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.active {
+		return 0
+	}
+	s.active = false
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.clear()
+	return time.Since(s.started)
+}
+
+// Pause hides the status line until Resume, e.g. while the user answers a
+// prompt. Calls nest.
+func (s *Spinner) Pause() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.paused++
+	s.clear()
+}
+
+// Resume shows the status line again after Pause
+func (s *Spinner) Resume() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.paused = max(s.paused-1, 0)
+	s.draw()
+}
+
+func (s *Spinner) tick(stop <-chan struct{}) {
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mutex.Lock()
+			s.clear()
+			s.draw()
+			s.mutex.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// draw shows the status line when it has room. The caller holds the mutex.
+func (s *Spinner) draw() {
+	if !s.active || !s.animate || s.paused > 0 || !s.atLineStart {
+		return
+	}
+
+	text := SpinnerText(s.label, time.Since(s.started), s.painter.Capabilities().Unicode)
+	io.WriteString(s.out, s.painter.Paint(Style{Color: Cyan}, text))
+	s.shown = true
+}
+
+// clear erases the status line. The caller holds the mutex.
+func (s *Spinner) clear() {
+	if !s.shown {
+		return
+	}
+	io.WriteString(s.out, "\r\033[K")
+	s.shown = false
+}
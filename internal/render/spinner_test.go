@@ -0,0 +1,80 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpinnerText(t *testing.T) {
+	tests := []struct {
+		elapsed  time.Duration
+		unicode  bool
+		expected string
+	}{
+		{0, true, "⠋ Working… 0s"},
+		{350 * time.Millisecond, true, "⠸ Working… 0s"},
+		{12 * time.Second, false, "| Working… 12s"},
+		{83*time.Second + 100*time.Millisecond, false, "\\ Working… 1m23s"},
+	}
+
+	for _, tt := range tests {
+		if got := SpinnerText("Working…", tt.elapsed, tt.unicode); got != tt.expected {
+			t.Errorf("SpinnerText(%v) = %q, expected %q", tt.elapsed, got, tt.expected)
+		}
+	}
+}
+
+func TestSpinner_MovesBelowOutput(t *testing.T) {
+	var out bytes.Buffer
+	spinner := NewSpinner(&out, PlainText, true)
+
+	spinner.Start("Working…")
+	spinner.Write([]byte("hello\n"))
+	spinner.Write([]byte("Choose: "))
+	elapsed := spinner.Stop()
+	output := out.String()
+
+	if !strings.HasPrefix(output, "⠋ Working… 0s\r\033[Khello\n⠋ Working… 0s") {
+		t.Errorf("Expected the status line to be cleared and redrawn below the output, got %q", output)
+	}
+	if !strings.HasSuffix(output, "\r\033[KChoose: ") {
+		t.Errorf("Expected no status line after a partial line, got %q", output)
+	}
+	if elapsed <= 0 {
+		t.Errorf("Expected a positive elapsed time, got %v", elapsed)
+	}
+}
+
+func TestSpinner_PassesThroughWhenNotAnimated(t *testing.T) {
+	var out bytes.Buffer
+	spinner := NewSpinner(&out, PlainText, false)
+
+	spinner.Start("Working…")
+	spinner.Write([]byte("hello\n"))
+	spinner.Stop()
+
+	if out.String() != "hello\n" {
+		t.Errorf("Expected output unchanged, got %q", out.String())
+	}
+}
+
+func TestSpinner_Pause(t *testing.T) {
+	var out bytes.Buffer
+	spinner := NewSpinner(&out, PlainText, true)
+
+	spinner.Start("Working…")
+	spinner.Pause()
+	spinner.Write([]byte("Choose: 1\n"))
+	paused := out.String()
+	spinner.Resume()
+	spinner.Stop()
+
+	if paused != "⠋ Working… 0s\r\033[KChoose: 1\n" {
+		t.Errorf("Expected no status line while paused, got %q", paused)
+	}
+	if !strings.HasPrefix(out.String(), paused+"⠋ Working…") {
+		t.Errorf("Expected the status line back after resuming, got %q", out.String())
+	}
+}
@@ -0,0 +1,330 @@
+package render
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Graphemes splits s into user-perceived characters: a base character with
+// its combining marks, variation selectors and skin tone modifiers, emoji
+// joined by zero-width joiners, and regional indicator pairs forming flags.
+// Cutting text between graphemes never splits a character on screen.
+func Graphemes(s string) []string {
+	var graphemes []string
+	start := 0
+	var previous rune = -1
+	regionalIndicators := 0
+
+	for i, r := range s {
+		if previous >= 0 && !graphemeBoundary(previous, r, regionalIndicators) {
+			if isRegionalIndicator(r) {
+				regionalIndicators++
+			}
+			previous = r
+			continue
+		}
+
+		if previous >= 0 {
+			graphemes = append(graphemes, s[start:i])
+		}
+		start = i
+		previous = r
+		regionalIndicators = 0
+		if isRegionalIndicator(r) {
+			regionalIndicators = 1
+		}
+	}
+
+	if start < len(s) {
+		graphemes = append(graphemes, s[start:])
+	}
+	return graphemes
+}
+
+func graphemeBoundary(previous, r rune, regionalIndicators int) bool {
+	switch {
+	case previous == '\r' && r == '\n':
+		return false
+	case previous == '\u200d':
+		// Zero-width joiner sequences such as 👩‍💻
+		return false
+	case isGraphemeExtend(r):
+		return false
+	case isRegionalIndicator(previous) && isRegionalIndicator(r):
+		return regionalIndicators%2 == 0
+	}
+	return true
+}
+
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == '\u200d' ||
+		(r >= 0xFE00 && r <= 0xFE0F) ||
+		(r >= 0xE0100 && r <= 0xE01EF) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) ||
+		(r >= 0xE0020 && r <= 0xE007F)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// GraphemeWidth is the number of terminal columns a grapheme occupies
+func GraphemeWidth(grapheme string) int {
+	r, _ := utf8.DecodeRuneInString(grapheme)
+	switch {
+	case r == utf8.RuneError && len(grapheme) <= 1:
+		return 1
+	case unicode.IsControl(r) || isGraphemeExtend(r) || unicode.Is(unicode.Cf, r):
+		return 0
+	case isRegionalIndicator(r), isWide(r):
+		return 2
+	case strings.ContainsRune(grapheme, '\ufe0f'):
+		// The emoji presentation selector widens text symbols such as ✏️
+		return 2
+	}
+	return 1
+}
+
+// StringWidth is the number of terminal columns s occupies. ANSI escape
+// sequences take no space.
+func StringWidth(s string) int {
+	width := 0
+	for _, token := range tokenize(s) {
+		width += token.width
+	}
+	return width
+}
+
+// Truncate shortens s to at most width columns, ending it with tail when
+// anything was cut. It never splits a grapheme, and keeps ANSI escapes.
+func Truncate(s string, width int, tail string) string {
+	if StringWidth(s) <= width {
+		return s
+	}
+
+	limit := width - StringWidth(tail)
+	if limit < 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	used := 0
+	styled := false
+	for _, token := range tokenize(s) {
+		if token.escape {
+			b.WriteString(token.text)
+			styled = token.text != resetEscape
+			continue
+		}
+		if used+token.width > limit {
+			break
+		}
+		b.WriteString(token.text)
+		used += token.width
+	}
+
+	if styled {
+		b.WriteString(resetEscape)
+	}
+	b.WriteString(tail)
+	return b.String()
+}
+
+// StripEscapes removes ANSI escape sequences from s, leaving plain text
+func StripEscapes(s string) string {
+	var b strings.Builder
+	for _, token := range tokenize(s) {
+		if !token.escape {
+			b.WriteString(token.text)
+		}
+	}
+	return b.String()
+}
+
+// Wrap breaks s into lines of at most width columns, preferring to break at
+// spaces. Continuation lines keep the leading indentation of s, and styles
+// open at a break are closed and reopened on the next line.
+func Wrap(s string, width int) []string {
+	if width <= 0 || StringWidth(s) <= width {
+		return []string{s}
+	}
+
+	tokens := tokenize(s)
+
+	var indent []token
+	for _, t := range tokens {
+		if t.text != " " {
+			break
+		}
+		indent = append(indent, t)
+	}
+	if len(indent) >= width/2 {
+		indent = nil
+	}
+
+	var lines []string
+	line := []token{}
+	lineWidth := 0
+	active := ""
+
+	for i := 0; i < len(tokens); i++ {
+		next := tokens[i]
+		if next.escape || lineWidth+next.width <= width || lineWidth == 0 {
+			line = append(line, next)
+			lineWidth += next.width
+			continue
+		}
+
+		// Break at the last space after the indentation, or hard break here
+		cut := len(line)
+		rest := []token{}
+		for j := len(line) - 1; j > len(indent); j-- {
+			if line[j].text == " " {
+				cut = j
+				rest = append(rest, line[j+1:]...)
+				break
+			}
+		}
+
+		lines = append(lines, closeLine(line[:cut], &active))
+
+		line = append(append([]token{}, indent...), styleToken(active)...)
+		line = append(line, rest...)
+		lineWidth = 0
+		for _, t := range line {
+			lineWidth += t.width
+		}
+		i--
+	}
+
+	return append(lines, joinTokens(line))
+}
+
+const resetEscape = "\033[0m"
+
+// closeLine renders a wrapped line, resetting any style still open at its
+// end; active is updated to that style so the next line can reopen it
+func closeLine(line []token, active *string) string {
+	for _, token := range line {
+		if token.escape {
+			if token.text == resetEscape {
+				*active = ""
+			} else {
+				*active = token.text
+			}
+		}
+	}
+
+	text := joinTokens(line)
+	if *active != "" {
+		text += resetEscape
+	}
+	return text
+}
+
+func styleToken(escape string) []token {
+	if escape == "" {
+		return nil
+	}
+	return []token{{text: escape, escape: true}}
+}
+
+func joinTokens(tokens []token) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString(token.text)
+	}
+	return b.String()
+}
+
+// token is a grapheme or an ANSI escape sequence
+type token struct {
+	text   string
+	width  int
+	escape bool
+}
+
+func tokenize(s string) []token {
+	var tokens []token
+	for len(s) > 0 {
+		if n := escapeLength(s); n > 0 {
+			tokens = append(tokens, token{text: s[:n], escape: true})
+			s = s[n:]
+			continue
+		}
+
+		next := strings.IndexByte(s, '\033')
+		if next <= 0 {
+			next = len(s)
+			if s[0] == '\033' {
+				next = 1
+			}
+		}
+		for _, grapheme := range Graphemes(s[:next]) {
+			tokens = append(tokens, token{text: grapheme, width: GraphemeWidth(grapheme)})
+		}
+		s = s[next:]
+	}
+	return tokens
+}
+
+// escapeLength returns the length of the CSI escape sequence s starts with
+func escapeLength(s string) int {
+	if len(s) < 2 || s[0] != '\033' || s[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7E {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// wideRanges are the East Asian wide and fullwidth characters and the emoji
+// shown two columns wide by default
+var wideRanges = []struct{ first, last rune }{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x23F0, 0x23F0}, {0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE},
+	{0x26D4, 0x26D4}, {0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF},
+	{0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F004, 0x1F004},
+	{0x1F0CF, 0x1F0CF}, {0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F251},
+	{0x1F300, 0x1F320}, {0x1F32D, 0x1F335}, {0x1F337, 0x1F37C}, {0x1F37E, 0x1F393},
+	{0x1F3A0, 0x1F3CA}, {0x1F3CF, 0x1F3D3}, {0x1F3E0, 0x1F3F0}, {0x1F3F4, 0x1F3F4},
+	{0x1F3F8, 0x1F43E}, {0x1F440, 0x1F440}, {0x1F442, 0x1F4FC}, {0x1F4FF, 0x1F53D},
+	{0x1F54B, 0x1F54E}, {0x1F550, 0x1F567}, {0x1F57A, 0x1F57A}, {0x1F595, 0x1F596},
+	{0x1F5A4, 0x1F5A4}, {0x1F5FB, 0x1F64F}, {0x1F680, 0x1F6C5}, {0x1F6CC, 0x1F6CC},
+	{0x1F6D0, 0x1F6D2}, {0x1F6D5, 0x1F6D7}, {0x1F6DC, 0x1F6DF}, {0x1F6EB, 0x1F6EC},
+	{0x1F6F4, 0x1F6FC}, {0x1F7E0, 0x1F7EB}, {0x1F7F0, 0x1F7F0}, {0x1F90C, 0x1F93A},
+	{0x1F93C, 0x1F945}, {0x1F947, 0x1F9FF}, {0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+func isWide(r rune) bool {
+	if r < wideRanges[0].first {
+		return false
+	}
+	low, high := 0, len(wideRanges)-1
+	for low <= high {
+		mid := (low + high) / 2
+		switch {
+		case r < wideRanges[mid].first:
+			high = mid - 1
+		case r > wideRanges[mid].last:
+			low = mid + 1
+		default:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"ascii", "abc", []string{"a", "b", "c"}},
+		{"combining accent", "éx", []string{"é", "x"}},
+		{"variation selector", "✏️ a", []string{"✏️", " ", "a"}},
+		{"skin tone", "👍🏽!", []string{"👍🏽", "!"}},
+		{"zwj sequence", "👩‍💻x", []string{"👩‍💻", "x"}},
+		{"flags", "🇳🇱🇯🇵", []string{"🇳🇱", "🇯🇵"}},
+		{"crlf", "a\r\nb", []string{"a", "\r\n", "b"}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Graphemes(tt.input); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Graphemes(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"hello", 5},
+		{"héllo", 5},
+		{"é", 1},
+		{"日本語", 6},
+		{"🔧 edit", 7},
+		{"✏️  With", 8},
+		{"👩‍💻", 2},
+		{"🇳🇱", 2},
+		{"\033[1;36m│\033[0m ok", 4},
+		{"ｆｕｌｌ", 8},
+	}
+
+	for _, tt := range tests {
+		if got := StringWidth(tt.input); got != tt.expected {
+			t.Errorf("StringWidth(%q) = %d, expected %d", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestStripEscapes(t *testing.T) {
+	input := "\033[1;36m│\033[0m ok \033[31mé\033[0m"
+	if got := StripEscapes(input); got != "│ ok é" {
+		t.Errorf("StripEscapes(%q) = %q", input, got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		width    int
+		expected string
+	}{
+		{"fits", "short", 10, "short"},
+		{"ascii", "abcdefghij", 6, "abc..."},
+		{"wide characters", "日本語のテキスト", 9, "日本語..."},
+		{"does not split emoji", "ab👩‍💻cd", 5, "ab..."},
+		{"keeps accents", "ééééé", 4, "é..."},
+		{"closes styles", "\033[0;31mredredred\033[0m", 6, "\033[0;31mred\033[0m..."},
+		{"too narrow for tail", "abcdef", 2, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.input, tt.width, "..."); got != tt.expected {
+				t.Errorf("Truncate(%q, %d) = %q, expected %q", tt.input, tt.width, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		width    int
+		expected []string
+	}{
+		{"fits", "one two", 10, []string{"one two"}},
+		{"at spaces", "one two three four", 9, []string{"one two", "three", "four"}},
+		{"hard break", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"wide characters", "日本語テキスト", 6, []string{"日本語", "テキス", "ト"}},
+		{"keeps indentation", "  • key: some long value", 12, []string{"  • key:", "  some long", "  value"}},
+		{
+			"reopens styles",
+			"x \033[0;32maaa bbb\033[0m",
+			6,
+			[]string{"x \033[0;32maaa\033[0m", "\033[0;32mbbb\033[0m"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Wrap(tt.input, tt.width)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Wrap(%q, %d) = %q, expected %q", tt.input, tt.width, got, tt.expected)
+			}
+			for _, line := range got {
+				if StringWidth(line) > tt.width {
+					t.Errorf("Line %q is wider than %d", line, tt.width)
+				}
+			}
+		})
+	}
+}
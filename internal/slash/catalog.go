@@ -0,0 +1,234 @@
+// Package slash keeps the slash commands the agent advertises with
+// available_commands_update, alongside the commands agentgo handles itself.
+// It completes them in the prompt, lists them in help and rewrites prompts
+// that invoke agent commands into the form agents expect.
+package slash
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"agentgo/internal/lineedit"
+	"agentgo/internal/render"
+	"agentgo/protocol"
+)
+
+// HelpCommand lists the available commands
+const HelpCommand = "/help"
+
+// Command is a command the user can type, named with its leading slash or
+// colon
+type Command struct {
+	Name        string
+	Description string
+	// Hint describes the input expected after the name, if any
+	Hint string
+}
+
+// Catalog holds the agent's latest command list and the local commands. It
+// is safe for concurrent use.
+type Catalog struct {
+	mutex sync.Mutex
+	agent []Command
+	local []Command
+}
+
+// NewCatalog creates a catalog with local commands, which are listed after
+// /help
+func NewCatalog(local ...Command) *Catalog {
+	return &Catalog{
+		local: append([]Command{{Name: HelpCommand, Description: "List the available commands"}}, local...),
+	}
+}
+
+// AddLocal adds commands agentgo handles itself, listed after the others
+func (c *Catalog) AddLocal(commands ...Command) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.local = append(c.local, commands...)
+}
+
+// Update replaces the agent's commands with those from an
+// available_commands_update
+func (c *Catalog) Update(commands []protocol.Command) {
+	agent := make([]Command, 0, len(commands))
+	for _, command := range commands {
+		name := strings.TrimPrefix(command.Name, "/")
+		if name == "" {
+			continue
+		}
+		hint := ""
+		if command.Input != nil {
+			hint = command.Input.Hint
+		}
+		agent = append(agent, Command{Name: "/" + name, Description: command.Description, Hint: hint})
+	}
+	slices.SortFunc(agent, func(a, b Command) int { return strings.Compare(a.Name, b.Name) })
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.agent = agent
+}
+
+// Agent returns the agent's commands, sorted by name
+func (c *Catalog) Agent() []Command {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return slices.Clone(c.agent)
+}
+
+// Local returns the commands agentgo handles itself
+func (c *Catalog) Local() []Command {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return slices.Clone(c.local)
+}
+
+// all lists local commands first, so they win over agent commands of the
+// same name. The caller holds the mutex.
+func (c *Catalog) all() []Command {
+	return append(slices.Clone(c.local), c.agent...)
+}
+
+// Parse splits text typed at the prompt into a command name, with its
+// leading slash or colon, and the input after it. It returns false when the
+// text does not start with a command.
+func Parse(text string) (name, input string, ok bool) {
+	text = strings.TrimSpace(text)
+	if len(text) < 2 || (text[0] != '/' && text[0] != ':') {
+		return "", "", false
+	}
+
+	name, input = text, ""
+	if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+		name, input = text[:i], strings.TrimSpace(text[i:])
+	}
+	if text[0] == '/' && strings.Contains(name[1:], "/") {
+		// A path such as /usr/bin, not a command
+		return "", "", false
+	}
+	return name, input, true
+}
+
+// Lookup finds a command by name, ignoring case
+func (c *Catalog) Lookup(name string) (Command, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, command := range c.all() {
+		if strings.EqualFold(command.Name, name) {
+			return command, true
+		}
+	}
+	return Command{}, false
+}
+
+// Prompt returns the prompt to send for text. A known agent command is sent
+// as its name, spelled as the agent listed it, followed by a single space
+// and its input; other text is sent unchanged.
+func (c *Catalog) Prompt(text string) string {
+	name, input, ok := Parse(text)
+	if !ok {
+		return text
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, command := range c.agent {
+		if strings.EqualFold(command.Name, name) {
+			if input == "" {
+				return command.Name
+			}
+			return command.Name + " " + input
+		}
+	}
+	return text
+}
+
+// Complete offers the commands whose names start with text, when text is
+// the start of a command name
+func (c *Catalog) Complete(text string) []lineedit.Completion {
+	if text == "" || (text[0] != '/' && text[0] != ':') || strings.ContainsAny(text, " \t\n") {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var completions []lineedit.Completion
+	for _, command := range c.all() {
+		if strings.HasPrefix(strings.ToLower(command.Name), strings.ToLower(text)) {
+			completions = append(completions, lineedit.Completion{Text: command.Name, Description: command.Description})
+		}
+	}
+	return completions
+}
+
+// Hint suggests Tab after a lone slash, and the expected input once a
+// command name and a space are typed
+func (c *Catalog) Hint(text string) string {
+	if text == "/" {
+		return "Tab to list commands"
+	}
+
+	name, rest, found := strings.Cut(text, " ")
+	if !found || rest != "" {
+		return ""
+	}
+	if command, ok := c.Lookup(name); ok {
+		return command.Hint
+	}
+	return ""
+}
+
+var (
+	helpHeadingStyle = render.Style{Bold: true}
+	helpNameStyle    = render.Style{Color: render.Cyan}
+	helpHintStyle    = render.Style{Color: render.Black, Bold: true}
+)
+
+// WriteHelp lists the agent's commands and the local ones
+func (c *Catalog) WriteHelp(r render.Renderer) {
+	agent, local := c.Agent(), c.Local()
+
+	r.Println(r.Paint(helpHeadingStyle, "Agent commands:"))
+	if len(agent) == 0 {
+		r.Println(r.Paint(helpHintStyle, "  The agent has not listed any commands"))
+	}
+	writeCommands(r, agent)
+
+	r.Println(r.Paint(helpHeadingStyle, "Local commands:"))
+	writeCommands(r, local)
+	prefixes := "/"
+	if slices.ContainsFunc(local, func(command Command) bool { return strings.HasPrefix(command.Name, ":") }) {
+		prefixes = "/ or :"
+	}
+	r.Println(r.Paint(helpHintStyle, "Type "+prefixes+" and press Tab to complete a command"))
+	r.Println("")
+}
+
+func writeCommands(r render.Renderer, commands []Command) {
+	width := 0
+	for _, command := range commands {
+		width = max(width, render.StringWidth(usage(command)))
+	}
+	for _, command := range commands {
+		name := usage(command)
+		padding := strings.Repeat(" ", width-render.StringWidth(name)+2)
+		r.Println("  " + r.Paint(helpNameStyle, name) + padding + command.Description)
+	}
+}
+
+// usage is the command name followed by its input hint, which is put in
+// angle brackets unless it already describes its arguments with brackets
+func usage(command Command) string {
+	switch {
+	case command.Hint == "":
+		return command.Name
+	case strings.HasPrefix(command.Hint, "<"), strings.HasPrefix(command.Hint, "["):
+		return command.Name + " " + command.Hint
+	}
+	return command.Name + " <" + command.Hint + ">"
+}
@@ -0,0 +1,157 @@
+package slash
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"agentgo/internal/lineedit"
+	"agentgo/internal/render"
+	"agentgo/protocol"
+)
+
+const commandsUpdate = `{
+	"sessionUpdate": "available_commands_update",
+	"availableCommands": [
+		{"name": "review", "description": "Review the current changes", "input": {"hint": "focus"}},
+		{"name": "init", "description": "Write a project guide", "input": null},
+		{"name": "mcp:docs:search", "description": "Search the docs"}
+	]
+}`
+
+func newTestCatalog(t *testing.T) *Catalog {
+	var update protocol.SessionUpdate
+	if err := json.Unmarshal([]byte(commandsUpdate), &update); err != nil {
+		t.Fatal(err)
+	}
+	catalog := NewCatalog()
+	catalog.Update(update.AvailableCommands)
+	return catalog
+}
+
+func TestCatalog_Update(t *testing.T) {
+	catalog := newTestCatalog(t)
+
+	expected := []Command{
+		{Name: "/init", Description: "Write a project guide"},
+		{Name: "/mcp:docs:search", Description: "Search the docs"},
+		{Name: "/review", Description: "Review the current changes", Hint: "focus"},
+	}
+	if agent := catalog.Agent(); !reflect.DeepEqual(agent, expected) {
+		t.Errorf("Agent() = %+v, expected %+v", agent, expected)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text, name, input string
+		ok                bool
+	}{
+		{"/review  the parser ", "/review", "the parser", true},
+		{"/init", "/init", "", true},
+		{"/mcp:docs:search go", "/mcp:docs:search", "go", true},
+		{":quit", ":quit", "", true},
+		{"/usr/bin is missing", "", "", false},
+		{"fix /review", "", "", false},
+		{"/", "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, input, ok := Parse(tt.text)
+		if name != tt.name || input != tt.input || ok != tt.ok {
+			t.Errorf("Parse(%q) = %q, %q, %v, expected %q, %q, %v", tt.text, name, input, ok, tt.name, tt.input, tt.ok)
+		}
+	}
+}
+
+func TestCatalog_Prompt(t *testing.T) {
+	catalog := newTestCatalog(t)
+
+	tests := map[string]string{
+		"/Review   the parser": "/review the parser",
+		"/init":                "/init",
+		"/unknown thing":       "/unknown thing",
+		"plain text":           "plain text",
+	}
+	for text, expected := range tests {
+		if got := catalog.Prompt(text); got != expected {
+			t.Errorf("Prompt(%q) = %q, expected %q", text, got, expected)
+		}
+	}
+}
+
+func TestCatalog_Complete(t *testing.T) {
+	catalog := newTestCatalog(t)
+
+	expected := []lineedit.Completion{
+		{Text: "/help", Description: "List the available commands"},
+		{Text: "/init", Description: "Write a project guide"},
+		{Text: "/mcp:docs:search", Description: "Search the docs"},
+		{Text: "/review", Description: "Review the current changes"},
+	}
+	if got := catalog.Complete("/"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Complete(\"/\") = %+v", got)
+	}
+	if got := catalog.Complete("/RE"); len(got) != 1 || got[0].Text != "/review" {
+		t.Errorf("Complete(\"/RE\") = %+v, expected /review", got)
+	}
+	if got := catalog.Complete("/review x"); got != nil {
+		t.Errorf("Expected no completions after the command name, got %+v", got)
+	}
+}
+
+func TestCatalog_Hint(t *testing.T) {
+	catalog := newTestCatalog(t)
+
+	tests := map[string]string{
+		"/":            "Tab to list commands",
+		"/review ":     "focus",
+		"/review":      "",
+		"/review x":    "",
+		"/init ":       "",
+		"hello there ": "",
+	}
+	for text, expected := range tests {
+		if got := catalog.Hint(text); got != expected {
+			t.Errorf("Hint(%q) = %q, expected %q", text, got, expected)
+		}
+	}
+}
+
+func TestCatalog_WriteHelp(t *testing.T) {
+	var out bytes.Buffer
+	newTestCatalog(t).WriteHelp(render.NewTextRenderer(&out, render.PlainText))
+
+	for _, expected := range []string{
+		"Agent commands:",
+		"  /review <focus>   Review the current changes",
+		"Local commands:",
+		"  /help  List the available commands",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected help to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestCatalog_AddLocal(t *testing.T) {
+	catalog := newTestCatalog(t)
+	catalog.AddLocal(Command{Name: ":save", Description: "Save the transcript", Hint: "file"})
+
+	if _, ok := catalog.Lookup(":SAVE"); !ok {
+		t.Error("Expected the added command to be found")
+	}
+	if completions := catalog.Complete(":s"); len(completions) != 1 || completions[0].Text != ":save" {
+		t.Errorf("Expected :save to complete, got %+v", completions)
+	}
+
+	var out bytes.Buffer
+	catalog.WriteHelp(render.NewTextRenderer(&out, render.PlainText))
+	for _, expected := range []string{"  :save <file>  Save the transcript", "Type / or : and press Tab"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected help to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
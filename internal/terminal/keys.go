@@ -0,0 +1,259 @@
+package terminal
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Escape sequences that ask the terminal to mark pasted text, so a paste
+// arrives as one KeyPaste instead of a stream of keys
+const (
+	EnableBracketedPaste  = "\033[?2004h"
+	DisableBracketedPaste = "\033[?2004l"
+)
+
+// KeyType identifies a key read from the terminal
+type KeyType int
+
+const (
+	KeyUnknown KeyType = iota
+	KeyRune
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyDelete
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyPageUp
+	KeyPageDown
+	KeyPaste
+)
+
+// Key is a key press, or a whole bracketed paste
+type Key struct {
+	Type KeyType
+
+	// Rune is the character typed, for KeyRune. With Ctrl set it is the
+	// lower-case letter, so Ctrl-A is Key{Type: KeyRune, Rune: 'a', Ctrl: true}.
+	Rune rune
+
+	// Text is the pasted text, for KeyPaste
+	Text string
+
+	Ctrl  bool
+	Alt   bool
+	Shift bool
+}
+
+// KeyReader decodes key presses from raw terminal input
+type KeyReader struct {
+	in *bufio.Reader
+}
+
+// NewKeyReader creates a key reader over raw terminal input
+func NewKeyReader(in io.Reader) *KeyReader {
+	return &KeyReader{in: bufio.NewReader(in)}
+}
+
+// ReadKey blocks until the next key press
+func (k *KeyReader) ReadKey() (Key, error) {
+	b, err := k.in.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch {
+	case b == 0x1b:
+		return k.readEscape()
+	case b == '\r' || b == '\n':
+		return Key{Type: KeyEnter}, nil
+	case b == '\t':
+		return Key{Type: KeyTab}, nil
+	case b == 0x7f || b == 0x08:
+		return Key{Type: KeyBackspace}, nil
+	case b >= 0x01 && b <= 0x1a:
+		return Key{Type: KeyRune, Rune: rune('a' + b - 1), Ctrl: true}, nil
+	case b < 0x20:
+		return Key{Type: KeyUnknown}, nil
+	}
+
+	if err := k.in.UnreadByte(); err != nil {
+		return Key{}, err
+	}
+	r, _, err := k.in.ReadRune()
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Type: KeyRune, Rune: r}, nil
+}
+
+// readEscape decodes what follows an ESC byte. A lone ESC with nothing else
+// waiting is the Escape key itself.
+func (k *KeyReader) readEscape() (Key, error) {
+	if k.in.Buffered() == 0 {
+		return Key{Type: KeyEscape}, nil
+	}
+
+	b, err := k.in.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch b {
+	case '[':
+		return k.readCSI()
+	case 'O':
+		final, err := k.in.ReadByte()
+		if err != nil {
+			return Key{}, err
+		}
+		return cursorKey(final, ""), nil
+	case 0x1b:
+		return Key{Type: KeyEscape}, nil
+	}
+
+	if err := k.in.UnreadByte(); err != nil {
+		return Key{}, err
+	}
+	key, err := k.ReadKey()
+	key.Alt = true
+	return key, err
+}
+
+// readCSI decodes a control sequence such as ESC [ 1 ; 5 C
+func (k *KeyReader) readCSI() (Key, error) {
+	var params strings.Builder
+	for {
+		b, err := k.in.ReadByte()
+		if err != nil {
+			return Key{}, err
+		}
+		if b >= 0x40 && b <= 0x7e {
+			return k.decodeCSI(params.String(), b)
+		}
+		params.WriteByte(b)
+	}
+}
+
+func (k *KeyReader) decodeCSI(params string, final byte) (Key, error) {
+	fields := strings.Split(params, ";")
+
+	switch final {
+	case '~':
+		switch fields[0] {
+		case "200":
+			return k.readPaste()
+		case "27":
+			// xterm modifyOtherKeys: ESC [ 27 ; modifier ; code ~
+			if len(fields) == 3 {
+				return withModifiers(codeKey(fields[2]), fields[1]), nil
+			}
+		}
+		key := Key{Type: tildeKeys[fields[0]]}
+		if len(fields) > 1 {
+			key = withModifiers(key, fields[1])
+		}
+		return key, nil
+	case 'u':
+		// Kitty keyboard protocol: ESC [ code ; modifier u
+		key := codeKey(fields[0])
+		if len(fields) > 1 {
+			key = withModifiers(key, fields[1])
+		}
+		return key, nil
+	case 'Z':
+		return Key{Type: KeyTab, Shift: true}, nil
+	}
+
+	modifiers := ""
+	if len(fields) > 1 {
+		modifiers = fields[1]
+	}
+	return cursorKey(final, modifiers), nil
+}
+
+var tildeKeys = map[string]KeyType{
+	"1": KeyHome,
+	"7": KeyHome,
+	"4": KeyEnd,
+	"8": KeyEnd,
+	"3": KeyDelete,
+	"5": KeyPageUp,
+	"6": KeyPageDown,
+}
+
+func cursorKey(final byte, modifiers string) Key {
+	types := map[byte]KeyType{
+		'A': KeyUp,
+		'B': KeyDown,
+		'C': KeyRight,
+		'D': KeyLeft,
+		'H': KeyHome,
+		'F': KeyEnd,
+	}
+	return withModifiers(Key{Type: types[final]}, modifiers)
+}
+
+// codeKey is the key for a character code sent by extended keyboard protocols
+func codeKey(code string) Key {
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return Key{Type: KeyUnknown}
+	}
+
+	switch n {
+	case 13:
+		return Key{Type: KeyEnter}
+	case 9:
+		return Key{Type: KeyTab}
+	case 27:
+		return Key{Type: KeyEscape}
+	case 127:
+		return Key{Type: KeyBackspace}
+	}
+	return Key{Type: KeyRune, Rune: rune(n)}
+}
+
+// withModifiers applies an xterm modifier parameter, which is one more than
+// a bit mask of shift (1), alt (2) and ctrl (4)
+func withModifiers(key Key, modifiers string) Key {
+	n, err := strconv.Atoi(modifiers)
+	if err != nil || n < 2 {
+		return key
+	}
+
+	mask := n - 1
+	key.Shift = mask&1 != 0
+	key.Alt = mask&2 != 0
+	key.Ctrl = mask&4 != 0
+	return key
+}
+
+// readPaste collects bracketed paste text up to the closing ESC [ 201 ~
+func (k *KeyReader) readPaste() (Key, error) {
+	const end = "\033[201~"
+
+	var text strings.Builder
+	for {
+		b, err := k.in.ReadByte()
+		if err != nil {
+			return Key{Type: KeyPaste, Text: text.String()}, err
+		}
+		text.WriteByte(b)
+
+		if strings.HasSuffix(text.String(), end) {
+			pasted := strings.TrimSuffix(text.String(), end)
+			// Terminals send line breaks in pastes as carriage returns
+			pasted = strings.ReplaceAll(pasted, "\r\n", "\n")
+			pasted = strings.ReplaceAll(pasted, "\r", "\n")
+			return Key{Type: KeyPaste, Text: pasted}, nil
+		}
+	}
+}
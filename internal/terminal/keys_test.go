@@ -0,0 +1,59 @@
+package terminal
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Key
+	}{
+		{"runes", "aé日", []Key{{Type: KeyRune, Rune: 'a'}, {Type: KeyRune, Rune: 'é'}, {Type: KeyRune, Rune: '日'}}},
+		{"enter", "\r\n", []Key{{Type: KeyEnter}, {Type: KeyEnter}}},
+		{"tab and backspace", "\t\x7f\x08", []Key{{Type: KeyTab}, {Type: KeyBackspace}, {Type: KeyBackspace}}},
+		{"ctrl letters", "\x01\x05\x12", []Key{{Type: KeyRune, Rune: 'a', Ctrl: true}, {Type: KeyRune, Rune: 'e', Ctrl: true}, {Type: KeyRune, Rune: 'r', Ctrl: true}}},
+		{"arrows", "\x1b[A\x1b[B\x1b[C\x1b[D", []Key{{Type: KeyUp}, {Type: KeyDown}, {Type: KeyRight}, {Type: KeyLeft}}},
+		{"application arrows", "\x1bOA\x1bOH", []Key{{Type: KeyUp}, {Type: KeyHome}}},
+		{"ctrl arrow", "\x1b[1;5D", []Key{{Type: KeyLeft, Ctrl: true}}},
+		{"tilde keys", "\x1b[3~\x1b[5~\x1b[6~\x1b[1~\x1b[4~", []Key{{Type: KeyDelete}, {Type: KeyPageUp}, {Type: KeyPageDown}, {Type: KeyHome}, {Type: KeyEnd}}},
+		{"alt letter", "\x1bb", []Key{{Type: KeyRune, Rune: 'b', Alt: true}}},
+		{"alt enter", "\x1b\r", []Key{{Type: KeyEnter, Alt: true}}},
+		{"shift enter modifyOtherKeys", "\x1b[27;2;13~", []Key{{Type: KeyEnter, Shift: true}}},
+		{"shift enter kitty", "\x1b[13;2u", []Key{{Type: KeyEnter, Shift: true}}},
+		{"shift tab", "\x1b[Z", []Key{{Type: KeyTab, Shift: true}}},
+		{"lone escape", "\x1b", []Key{{Type: KeyEscape}}},
+		{
+			"bracketed paste",
+			"\x1b[200~func main() {\r\tfmt.Println(\"\x1b[A\")\r}\x1b[201~x",
+			[]Key{{Type: KeyPaste, Text: "func main() {\n\tfmt.Println(\"\x1b[A\")\n}"}, {Type: KeyRune, Rune: 'x'}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewKeyReader(strings.NewReader(tt.input))
+
+			var keys []Key
+			for {
+				key, err := reader.ReadKey()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadKey() error: %v", err)
+				}
+				keys = append(keys, key)
+			}
+
+			if !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("Read %+v, expected %+v", keys, tt.expected)
+			}
+		})
+	}
+}
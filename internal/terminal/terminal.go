@@ -0,0 +1,23 @@
+// Package terminal talks to the user's terminal: its size, raw input mode and
+// the keys read from it.
+package terminal
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrNotSupported is returned where the platform has no terminal control
+var ErrNotSupported = errors.New("terminal control is not supported on this platform")
+
+// IsTerminal reports whether f is connected to a terminal
+func IsTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package terminal
+
+import "os"
+
+func Size(f *os.File) (width, height int, err error) {
+	return 0, 0, ErrNotSupported
+}
+
+// NotifyResize is a no-op where terminals do not signal resizes
+func NotifyResize(ch chan<- os.Signal) {}
+
+func MakeRaw(f *os.File) (restore func() error, err error) {
+	return nil, ErrNotSupported
+}
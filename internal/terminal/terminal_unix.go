@@ -0,0 +1,63 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package terminal
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// Size returns the width and height of the terminal behind f
+func Size(f *os.File) (width, height int, err error) {
+	var size struct {
+		rows, columns, xpixels, ypixels uint16
+	}
+	if err := ioctl(f, syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil {
+		return 0, 0, err
+	}
+	return int(size.columns), int(size.rows), nil
+}
+
+// NotifyResize delivers a signal on ch whenever the terminal is resized.
+// Use signal.Stop to stop the notifications.
+func NotifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
+
+// MakeRaw puts the terminal behind f into raw mode: input is passed through
+// a byte at a time without echo, line editing or signals. Call restore to
+// return the terminal to the mode it was in.
+func MakeRaw(f *os.File) (restore func() error, err error) {
+	var original syscall.Termios
+	if err := ioctl(f, ioctlGetTermios, unsafe.Pointer(&original)); err != nil {
+		return nil, err
+	}
+
+	raw := original
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(f, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return ioctl(f, ioctlSetTermios, unsafe.Pointer(&original))
+	}, nil
+}
+
+func ioctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,526 @@
+// Package tui is the optional full-screen interface: a scrollable transcript,
+// a sidebar with the agent's plan and tool calls, and a fixed input box.
+// Permission requests open as modal dialogs.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"agentgo/internal/attach"
+	"agentgo/internal/events"
+	"agentgo/internal/lineedit"
+	"agentgo/internal/render"
+	"agentgo/internal/slash"
+	"agentgo/internal/terminal"
+	"agentgo/protocol"
+	"agentgo/providers/claude"
+)
+
+// Escape sequences for the alternate screen, which keeps the shell's
+// scrollback intact while the interface is open
+const (
+	enterAltScreen = "\033[?1049h\033[H\033[2J"
+	leaveAltScreen = "\033[?1049l"
+	hideCursor     = "\033[?25l"
+	showCursor     = "\033[?25h"
+)
+
+// maxTools is how many recent tool calls the tools pane keeps
+const maxTools = 50
+
+// toolActivity is the latest state of one tool call
+type toolActivity struct {
+	id     string
+	title  string
+	kind   string
+	status string
+}
+
+// Agent receives the prompts entered in the interface
+type Agent interface {
+	SendPrompt(prompt []protocol.Prompt) error
+	// PromptCapabilities tells which content blocks files mentioned in a
+	// prompt may be sent as
+	PromptCapabilities() protocol.PromptCapabilities
+	// SendCancel asks the agent to end the running turn early
+	SendCancel() error
+}
+
+// App is the full-screen interface. It implements the permission prompt and
+// notification handlers, drawing the provider's output into its panes.
+type App struct {
+	in  *os.File
+	out *os.File
+
+	capabilities render.Capabilities
+	transcript   *transcript
+	conversation *render.TextRenderer
+	commands     *slash.Catalog
+
+	// prompts queues permission requests so one modal is open at a time
+	prompts chan struct{}
+	redraw  chan struct{}
+	done    chan struct{}
+
+	mutex   sync.Mutex
+	width   int
+	height  int
+	scroll  int
+	input   *lineedit.State
+	working bool
+	started time.Time
+	// cancelling is set once the running turn has been interrupted
+	cancelling bool
+	// queue holds prompts entered while the agent works
+	queue lineedit.Queue
+	plan  *claude.TodoListData
+	tools []toolActivity
+	modal *permissionModal
+
+	closeOnce sync.Once
+	restore   func() error
+}
+
+// New creates a full-screen interface on the given terminal
+func New(in, out *os.File) *App {
+	capabilities := render.DetectCapabilities(out)
+	transcript := &transcript{}
+	commands := slash.NewCatalog()
+	input := lineedit.NewState(nil)
+	input.SetCompleter(commands)
+
+	return &App{
+		in:           in,
+		out:          out,
+		capabilities: capabilities,
+		transcript:   transcript,
+		conversation: render.NewTextRenderer(transcript, capabilities),
+		commands:     commands,
+		input:        input,
+		prompts:      make(chan struct{}, 1),
+		redraw:       make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		width:        capabilities.Width,
+		height:       24,
+	}
+}
+
+// SetHistory sets the prompt history browsed and searched from the input box
+func (a *App) SetHistory(history *lineedit.History) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.input = lineedit.NewState(history)
+	a.input.SetCompleter(a.commands)
+}
+
+// SetCommands sets the catalog of commands completed in the input box and
+// kept up to date from the agent's updates
+func (a *App) SetCommands(commands *slash.Catalog) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.commands = commands
+	a.input.SetCompleter(commands)
+}
+
+// LogWriter returns a writer that shows log output in the transcript, since
+// writing to stderr would corrupt the screen
+func (a *App) LogWriter() io.Writer {
+	return &logWriter{app: a}
+}
+
+type logWriter struct {
+	app *App
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.app.conversation.Println(w.app.conversation.Paint(render.Style{Color: render.Black, Bold: true}, line))
+	}
+	w.app.requestRedraw()
+	return len(p), nil
+}
+
+// Run shows the interface until the user quits or the stream from the agent
+// ends. Prompts the user enters are sent to agent, those entered while it
+// works once the running turn ends; turns delivers the result of each one
+// when the agent finishes responding.
+func (a *App) Run(agent Agent, turns <-chan protocol.TurnResult, streamErr <-chan error) error {
+	restore, err := terminal.MakeRaw(a.in)
+	if err != nil {
+		return fmt.Errorf("failed to enter raw mode: %v", err)
+	}
+	a.restore = restore
+	defer a.Close()
+
+	io.WriteString(a.out, enterAltScreen+terminal.EnableBracketedPaste)
+
+	keys, keyErrs := a.readKeys()
+
+	resized := make(chan os.Signal, 1)
+	terminal.NotifyResize(resized)
+	defer signal.Stop(resized)
+
+	// Animates the spinner in the status line while the agent works
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+
+	a.resize()
+	a.draw()
+
+	for {
+		select {
+		case key := <-keys:
+			quit, err := a.handleKey(key, agent)
+			if err != nil || quit {
+				return err
+			}
+		case err := <-keyErrs:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-resized:
+			a.resize()
+		case <-a.redraw:
+		case <-ticker.C:
+			a.mutex.Lock()
+			working := a.working
+			a.mutex.Unlock()
+			if !working {
+				continue
+			}
+		case result := <-turns:
+			if err := a.endTurn(result, agent); err != nil {
+				return err
+			}
+		case err := <-streamErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		a.draw()
+	}
+}
+
+// Close restores the terminal. It is safe to call more than once, and from
+// a signal handler while Run is active.
+func (a *App) Close() {
+	a.closeOnce.Do(func() {
+		close(a.done)
+		if a.restore == nil {
+			return
+		}
+		io.WriteString(a.out, terminal.DisableBracketedPaste+showCursor+leaveAltScreen)
+		a.restore()
+	})
+}
+
+func (a *App) readKeys() (<-chan terminal.Key, <-chan error) {
+	keys := make(chan terminal.Key)
+	errs := make(chan error, 1)
+	reader := terminal.NewKeyReader(a.in)
+
+	go func() {
+		for {
+			key, err := reader.ReadKey()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case keys <- key:
+			case <-a.done:
+				return
+			}
+		}
+	}()
+
+	return keys, errs
+}
+
+func (a *App) requestRedraw() {
+	select {
+	case a.redraw <- struct{}{}:
+	default:
+	}
+}
+
+func (a *App) resize() {
+	width, height, err := terminal.Size(a.out)
+	if err != nil || width <= 0 || height <= 0 {
+		return
+	}
+
+	a.mutex.Lock()
+	a.width, a.height = width, height
+	a.mutex.Unlock()
+
+	a.conversation.SetWidth(conversationWidth(width))
+}
+
+// handleKey applies a key press, returning true when the user quits. While
+// the agent works, Ctrl-C or Esc interrupts it and Ctrl-C again quits.
+func (a *App) handleKey(key terminal.Key, agent Agent) (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if key.Ctrl && key.Rune == 'c' {
+		if !a.working || a.cancelling {
+			return true, nil
+		}
+		return false, a.interrupt(agent)
+	}
+
+	if a.modal != nil {
+		if key.Type == terminal.KeyEscape {
+			return false, a.interrupt(agent)
+		}
+		a.modal.handleKey(key)
+		return false, nil
+	}
+
+	if view := a.input.View(); a.working && view.Search == "" && view.Completions == nil {
+		text := a.input.Text()
+		switch {
+		case key.Type == terminal.KeyEscape:
+			return false, a.interrupt(agent)
+		case key.Type == terminal.KeyRune && key.Ctrl && key.Rune == 's' && strings.TrimSpace(text) != "":
+			// Send now: the prompt goes first once the turn is cancelled
+			a.input.Reset()
+			a.input.History().Add(strings.TrimSpace(text))
+			a.queue.PushFront(strings.TrimSpace(text))
+			return false, a.interrupt(agent)
+		case key.Type == terminal.KeyUp && text == "":
+			if queued, ok := a.queue.TakeLast(); ok {
+				a.input.SetText(queued)
+				return false, nil
+			}
+		}
+	}
+
+	switch {
+	case key.Type == terminal.KeyPageUp:
+		a.scroll += a.pageSize()
+		return false, nil
+	case key.Type == terminal.KeyPageDown:
+		a.scroll = max(a.scroll-a.pageSize(), 0)
+		return false, nil
+	case key.Type == terminal.KeyEnd && a.scroll > 0:
+		a.scroll = 0
+		return false, nil
+	}
+
+	switch a.input.HandleKey(key) {
+	case lineedit.ActionEOF:
+		return true, nil
+	case lineedit.ActionSubmit:
+		text := strings.TrimSpace(a.input.Text())
+		a.input.Reset()
+		if text == "" {
+			return false, nil
+		}
+		a.input.History().Add(text)
+
+		if name, _, ok := slash.Parse(text); ok && strings.EqualFold(name, slash.HelpCommand) {
+			a.scroll = 0
+			a.commands.WriteHelp(a.conversation)
+			return false, nil
+		}
+		if a.working {
+			a.queue.Push(text)
+			return false, nil
+		}
+		return false, a.send(text, agent)
+	}
+
+	return false, nil
+}
+
+// send shows a prompt in the transcript and sends it with the files it
+// mentions, rewriting agent commands into the form the agent expects. The
+// caller holds the mutex.
+func (a *App) send(text string, agent Agent) error {
+	a.scroll = 0
+	a.working = true
+	a.started = time.Now()
+	claude.DisplayNotification(a.conversation, events.Event{Type: events.Prompt, Text: text})
+
+	prompt, attached, problems := attach.Prompt(a.commands.Prompt(text), nil, agent.PromptCapabilities())
+	for _, problem := range problems {
+		a.conversation.Println("⚠ Not attached: " + problem.Error())
+	}
+	descriptions := make([]string, 0, len(attached))
+	for _, item := range attached {
+		descriptions = append(descriptions, item.Describe())
+	}
+	claude.DisplayAttachments(a.conversation, descriptions)
+	return agent.SendPrompt(prompt)
+}
+
+// interrupt cancels the running turn, answering an open permission dialog
+// as cancelled. The caller holds the mutex.
+func (a *App) interrupt(agent Agent) error {
+	if a.cancelling {
+		return nil
+	}
+	a.cancelling = true
+	if a.modal != nil {
+		a.modal.cancel()
+	}
+	return agent.SendCancel()
+}
+
+// endTurn shows how the turn ended and sends the next queued prompt
+func (a *App) endTurn(result protocol.TurnResult, agent Agent) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	claude.DisplayTurnEnd(a.conversation, result, time.Since(a.started))
+	a.cancelling = false
+
+	next, ok := a.queue.Pop()
+	if !ok {
+		a.working = false
+		return nil
+	}
+	return a.send(next, agent)
+}
+
+func (a *App) pageSize() int {
+	return max(a.bodyHeight()/2, 1)
+}
+
+// HandleNotification shows an update from the agent in the matching pane
+func (a *App) HandleNotification(raw []byte, req protocol.SessionUpdateRequest) error {
+	update := req.Params.Update
+	defer a.requestRedraw()
+
+	switch update.SessionUpdateType {
+	case protocol.SessionUpdateAvailableCommands:
+		a.commands.Update(update.AvailableCommands)
+		return nil
+	case protocol.SessionUpdatePlan:
+		a.mutex.Lock()
+		a.plan = claude.TodoListFromPlan(update.Entries)
+		a.mutex.Unlock()
+		return nil
+	case protocol.SessionUpdateToolCall, protocol.SessionUpdateToolCallUpdate:
+		a.updateTool(update)
+		return nil
+	}
+
+	event, ok := events.FromUpdate(update, time.Now())
+	if !ok {
+		return nil
+	}
+	return claude.DisplayNotification(a.conversation, event)
+}
+
+func (a *App) updateTool(update protocol.SessionUpdate) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i := range a.tools {
+		if a.tools[i].id != update.ToolCallID {
+			continue
+		}
+		tool := &a.tools[i]
+		if update.Title != "" {
+			tool.title = update.Title
+		}
+		if update.Kind != "" {
+			tool.kind = update.Kind
+		}
+		if update.Status != "" {
+			tool.status = update.Status
+		}
+		return
+	}
+
+	a.tools = append(a.tools, toolActivity{
+		id:     update.ToolCallID,
+		title:  update.Title,
+		kind:   update.Kind,
+		status: update.Status,
+	})
+	if len(a.tools) > maxTools {
+		a.tools = a.tools[len(a.tools)-maxTools:]
+	}
+}
+
+// HandlePermissionRequest opens a modal dialog and answers the agent with
+// the option the user picks
+func (a *App) HandlePermissionRequest(
+	acpConn *protocol.AcpConnection,
+	raw []byte,
+	req protocol.SessionRequestPermissionRequest,
+) error {
+	select {
+	case a.prompts <- struct{}{}:
+	case <-a.done:
+		return nil
+	}
+	defer func() { <-a.prompts }()
+
+	// With nothing to choose from, the request can only be cancelled
+	if len(req.Params.Options) == 0 {
+		return acpConn.SendToolCancelled(req.ID)
+	}
+
+	modal := newPermissionModal(req.Params, a.capabilities)
+	a.mutex.Lock()
+	a.modal = modal
+	a.mutex.Unlock()
+	a.requestRedraw()
+
+	var choice int
+	select {
+	case choice = <-modal.chosen:
+	case <-a.done:
+		return nil
+	}
+
+	a.mutex.Lock()
+	a.modal = nil
+	a.mutex.Unlock()
+
+	if choice < 0 {
+		modal.drawRequest(a.conversation)
+		claude.ShowPermissionCancelled(a.conversation)
+		a.requestRedraw()
+		return acpConn.SendToolCancelled(req.ID)
+	}
+
+	selected := req.Params.Options[choice]
+	modal.drawRequest(a.conversation)
+	claude.ShowUserSelection(a.conversation, selected.Name)
+	a.requestRedraw()
+
+	return acpConn.SendToolResponse(req.ID, selected.OptionID)
+}
+
+func (a *App) draw() {
+	a.mutex.Lock()
+	rows, cursorRow, cursorCol := a.frame()
+	modalOpen := a.modal != nil
+	a.mutex.Unlock()
+
+	var b strings.Builder
+	b.WriteString(hideCursor)
+	for i, row := range rows {
+		fmt.Fprintf(&b, "\033[%d;1H%s\033[0m", i+1, row)
+	}
+	if !modalOpen {
+		fmt.Fprintf(&b, "\033[%d;%dH%s", cursorRow+1, cursorCol+1, showCursor)
+	}
+	io.WriteString(a.out, b.String())
+}
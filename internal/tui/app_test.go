@@ -0,0 +1,451 @@
+package tui
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"agentgo/internal/lineedit"
+	"agentgo/internal/render"
+	"agentgo/internal/terminal"
+	"agentgo/protocol"
+)
+
+// newTestApp creates an app with a fixed screen size and no terminal
+func newTestApp(width, height int) *App {
+	app := New(nil, nil)
+	app.width, app.height = width, height
+	app.conversation.SetWidth(conversationWidth(width))
+	return app
+}
+
+func frameText(t *testing.T, app *App) string {
+	t.Helper()
+
+	app.mutex.Lock()
+	rows, _, _ := app.frame()
+	width, height := app.width, app.height
+	app.mutex.Unlock()
+
+	if len(rows) != height {
+		t.Fatalf("Expected %d rows, got %d", height, len(rows))
+	}
+	for i, row := range rows {
+		if w := render.StringWidth(row); w != width {
+			t.Errorf("Row %d is %d columns wide, expected %d: %q", i, w, width, row)
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
+func update(sessionUpdate protocol.SessionUpdate) protocol.SessionUpdateRequest {
+	return protocol.SessionUpdateRequest{Params: protocol.SessionUpdateParams{Update: sessionUpdate}}
+}
+
+func TestFrame_Panes(t *testing.T) {
+	app := newTestApp(100, 20)
+
+	app.HandleNotification(nil, update(protocol.SessionUpdate{
+		SessionUpdateType: "agent_message_chunk",
+		Content:           &protocol.UpdateContent{Type: "text", Text: "Looking at the parser"},
+	}))
+	app.HandleNotification(nil, update(protocol.SessionUpdate{
+		SessionUpdateType: "plan",
+		Entries:           []protocol.PlanEntry{{Content: "Fix the test", Status: "in_progress"}},
+	}))
+	app.HandleNotification(nil, update(protocol.SessionUpdate{
+		SessionUpdateType: "tool_call", ToolCallID: "t1", Title: "Read parser.go", Status: "pending",
+	}))
+	app.HandleNotification(nil, update(protocol.SessionUpdate{
+		SessionUpdateType: "tool_call_update", ToolCallID: "t1", Status: "completed",
+	}))
+
+	screen := frameText(t, app)
+	for _, expected := range []string{"🤖 Assistant: Looking at the parser", "🔄 Fix the test", "✅ Read parser.go", "> "} {
+		if !strings.Contains(screen, expected) {
+			t.Errorf("Expected screen to contain %q:\n%s", expected, screen)
+		}
+	}
+	if len(app.tools) != 1 {
+		t.Errorf("Expected the tool call update to update the same entry, got %+v", app.tools)
+	}
+}
+
+func TestFrame_NarrowTerminalHasNoSidebar(t *testing.T) {
+	app := newTestApp(60, 12)
+	app.HandleNotification(nil, update(protocol.SessionUpdate{
+		SessionUpdateType: "plan",
+		Entries:           []protocol.PlanEntry{{Content: "Hidden", Status: "pending"}},
+	}))
+
+	if screen := frameText(t, app); strings.Contains(screen, "Hidden") {
+		t.Errorf("Expected no sidebar on a narrow terminal:\n%s", screen)
+	}
+}
+
+func TestFrame_TooSmall(t *testing.T) {
+	app := newTestApp(30, 5)
+	if screen := frameText(t, app); !strings.Contains(screen, "Terminal too small") {
+		t.Errorf("Expected a size warning:\n%s", screen)
+	}
+}
+
+// fakeAgent records what the interface sends
+type fakeAgent struct {
+	sent    []string
+	prompts [][]protocol.Prompt
+	cancels int
+}
+
+func (f *fakeAgent) SendPrompt(prompt []protocol.Prompt) error {
+	f.sent = append(f.sent, prompt[0].Text)
+	f.prompts = append(f.prompts, prompt)
+	return nil
+}
+
+func (f *fakeAgent) PromptCapabilities() protocol.PromptCapabilities {
+	return protocol.PromptCapabilities{EmbeddedContext: true}
+}
+
+func (f *fakeAgent) SendCancel() error {
+	f.cancels++
+	return nil
+}
+
+func TestHandleKey_Submit(t *testing.T) {
+	app := newTestApp(100, 20)
+	agent := &fakeAgent{}
+
+	for _, r := range "hi there" {
+		app.handleKey(terminal.Key{Type: terminal.KeyRune, Rune: r}, agent)
+	}
+	app.handleKey(terminal.Key{Type: terminal.KeyBackspace}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter}, agent)
+
+	if len(agent.sent) != 1 || agent.sent[0] != "hi ther" {
+		t.Errorf("Expected one prompt \"hi ther\", got %q", agent.sent)
+	}
+	if !strings.Contains(frameText(t, app), "👤 You: hi ther") {
+		t.Error("Expected the prompt in the transcript")
+	}
+
+	quit, _ := app.handleKey(terminal.Key{Type: terminal.KeyRune, Rune: 'c', Ctrl: true}, agent)
+	if quit || agent.cancels != 1 {
+		t.Errorf("Expected Ctrl-C to interrupt the turn, got quit %v and %d cancels", quit, agent.cancels)
+	}
+	quit, _ = app.handleKey(terminal.Key{Type: terminal.KeyRune, Rune: 'c', Ctrl: true}, agent)
+	if !quit {
+		t.Error("Expected Ctrl-C while interrupting to quit")
+	}
+}
+
+func TestHandleKey_QueuesWhileWorking(t *testing.T) {
+	app := newTestApp(100, 20)
+	agent := &fakeAgent{}
+
+	for _, text := range []string{"first", "second", "third"} {
+		app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: text}, agent)
+		app.handleKey(terminal.Key{Type: terminal.KeyEnter}, agent)
+	}
+	if len(agent.sent) != 1 {
+		t.Fatalf("Expected only the first prompt sent, got %q", agent.sent)
+	}
+	if screen := frameText(t, app); !strings.Contains(screen, "⏳ 1. second") || !strings.Contains(screen, "⏳ 2. third") {
+		t.Errorf("Expected the queued prompts on screen:\n%s", screen)
+	}
+
+	// Up on an empty line takes back the last queued prompt for editing
+	app.handleKey(terminal.Key{Type: terminal.KeyUp}, agent)
+	if app.input.Text() != "third" || app.queue.Len() != 1 {
+		t.Errorf("Expected \"third\" back in the input, got %q with %d queued", app.input.Text(), app.queue.Len())
+	}
+	app.input.Reset()
+
+	app.endTurn(protocol.TurnResult{StopReason: protocol.StopReasonEndTurn}, agent)
+	if len(agent.sent) != 2 || agent.sent[1] != "second" {
+		t.Errorf("Expected the queued prompt sent when the turn ended, got %q", agent.sent)
+	}
+
+	app.endTurn(protocol.TurnResult{StopReason: protocol.StopReasonEndTurn}, agent)
+	if app.working {
+		t.Error("Expected the agent idle once the queue is empty")
+	}
+}
+
+func TestHandleKey_SendNow(t *testing.T) {
+	app := newTestApp(100, 20)
+	agent := &fakeAgent{}
+
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: "first"}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: "later"}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: "urgent"}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyRune, Rune: 's', Ctrl: true}, agent)
+
+	if agent.cancels != 1 {
+		t.Errorf("Expected Ctrl-S to interrupt the turn, got %d cancels", agent.cancels)
+	}
+	if items := app.queue.Items(); !reflect.DeepEqual(items, []string{"urgent", "later"}) {
+		t.Errorf("Expected the prompt at the front of the queue, got %q", items)
+	}
+
+	app.endTurn(protocol.TurnResult{StopReason: protocol.StopReasonCancelled}, agent)
+	if agent.sent[len(agent.sent)-1] != "urgent" {
+		t.Errorf("Expected the prompt sent after the cancellation, got %q", agent.sent)
+	}
+}
+
+func TestHandleKey_MultiLineInput(t *testing.T) {
+	app := newTestApp(100, 20)
+
+	agent := &fakeAgent{}
+
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: "line one"}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter, Shift: true}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: "line two"}, agent)
+
+	app.mutex.Lock()
+	rows, cursorRow, cursorCol := app.frame()
+	app.mutex.Unlock()
+	if !strings.HasPrefix(rows[cursorRow], "> line one⏎line two") || cursorCol != 19 {
+		t.Errorf("Input row %q with cursor at %d", rows[cursorRow], cursorCol)
+	}
+
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter}, agent)
+	if len(agent.sent) != 1 || agent.sent[0] != "line one\nline two" {
+		t.Errorf("Expected the multi-line prompt, got %q", agent.sent)
+	}
+}
+
+func TestInputLine_KeepsCursorInView(t *testing.T) {
+	state := lineedit.NewState(nil)
+	state.SetText("abcdefghij")
+
+	line, cursor := inputLine(state.View(), 5)
+	if line != "fghij" || cursor != 5 {
+		t.Errorf("Got %q with cursor %d, expected the end of the text", line, cursor)
+	}
+
+	state.HandleKey(terminal.Key{Type: terminal.KeyHome})
+	line, cursor = inputLine(state.View(), 5)
+	if line != "abcde" || cursor != 0 {
+		t.Errorf("Got %q with cursor %d, expected the start of the text", line, cursor)
+	}
+}
+
+func TestHandlePermissionRequest_Modal(t *testing.T) {
+	recording := filepath.Join(t.TempDir(), "empty.jsonl")
+	if err := os.WriteFile(recording, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := protocol.OpenAcpReplayConnection(recording, protocol.ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	app := newTestApp(100, 30)
+	req := protocol.SessionRequestPermissionRequest{
+		ID: 7,
+		Params: protocol.SessionRequestPermissionParams{
+			ToolCall: protocol.ToolCall{ToolCallID: "tool-1", RawInput: map[string]any{"command": "ls"}},
+			Options: []protocol.PermissionOption{
+				{OptionID: "allow", Name: "Allow"},
+				{OptionID: "reject", Name: "Reject"},
+			},
+		},
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- app.HandlePermissionRequest(conn, nil, req)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		app.mutex.Lock()
+		open := app.modal != nil
+		app.mutex.Unlock()
+		if open {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the modal")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	screen := frameText(t, app)
+	for _, expected := range []string{"Tool Request", "💻 Command: ls", "❯ Allow"} {
+		if !strings.Contains(screen, expected) {
+			t.Errorf("Expected modal to contain %q:\n%s", expected, screen)
+		}
+	}
+
+	app.handleKey(terminal.Key{Type: terminal.KeyDown}, nil)
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter}, nil)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("HandlePermissionRequest() error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the answer")
+	}
+
+	if screen := frameText(t, app); !strings.Contains(screen, "✓ Selected: Reject") {
+		t.Errorf("Expected the choice in the transcript:\n%s", screen)
+	}
+}
+
+func TestHandlePermissionRequest_Interrupted(t *testing.T) {
+	recording := filepath.Join(t.TempDir(), "empty.jsonl")
+	if err := os.WriteFile(recording, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := protocol.OpenAcpReplayConnection(recording, protocol.ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	app := newTestApp(100, 30)
+	app.working = true
+	req := protocol.SessionRequestPermissionRequest{
+		ID: 7,
+		Params: protocol.SessionRequestPermissionParams{
+			ToolCall: protocol.ToolCall{ToolCallID: "tool-1", RawInput: map[string]any{"command": "ls"}},
+			Options:  []protocol.PermissionOption{{OptionID: "allow", Name: "Allow"}},
+		},
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- app.HandlePermissionRequest(conn, nil, req)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		app.mutex.Lock()
+		open := app.modal != nil
+		app.mutex.Unlock()
+		if open {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the modal")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	agent := &fakeAgent{}
+	app.handleKey(terminal.Key{Type: terminal.KeyEscape}, agent)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("HandlePermissionRequest() error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the modal to close")
+	}
+
+	if agent.cancels != 1 {
+		t.Errorf("Expected Esc to cancel the turn, got %d cancels", agent.cancels)
+	}
+	if screen := frameText(t, app); !strings.Contains(screen, "⏹ Cancelled") {
+		t.Errorf("Expected the cancellation in the transcript:\n%s", screen)
+	}
+}
+
+func TestHandlePermissionRequest_NoOptions(t *testing.T) {
+	recording := filepath.Join(t.TempDir(), "empty.jsonl")
+	if err := os.WriteFile(recording, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := protocol.OpenAcpReplayConnection(recording, protocol.ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var sent bytes.Buffer
+	conn.SetLogger(log.New(&sent, "", 0))
+	conn.SetTrace(true)
+
+	app := newTestApp(100, 30)
+	req := protocol.SessionRequestPermissionRequest{
+		ID:     7,
+		Params: protocol.SessionRequestPermissionParams{ToolCall: protocol.ToolCall{ToolCallID: "tool-1"}},
+	}
+	if err := app.HandlePermissionRequest(conn, nil, req); err != nil {
+		t.Fatalf("HandlePermissionRequest() error: %v", err)
+	}
+
+	expected := `→ {"jsonrpc":"2.0","id":7,"result":{"outcome":{"outcome":"cancelled"}}}`
+	if !strings.Contains(sent.String(), expected) {
+		t.Errorf("Expected the request to be cancelled, sent:\n%s", sent.String())
+	}
+	if app.modal != nil {
+		t.Error("Expected no modal for a request without options")
+	}
+}
+
+func TestHandleKey_SlashCommands(t *testing.T) {
+	app := newTestApp(100, 30)
+	agent := &fakeAgent{}
+	app.HandleNotification(nil, protocol.SessionUpdateRequest{Params: protocol.SessionUpdateParams{Update: protocol.SessionUpdate{
+		SessionUpdateType: protocol.SessionUpdateAvailableCommands,
+		AvailableCommands: []protocol.Command{{Name: "review", Description: "Review the changes", Input: &protocol.CommandInput{Hint: "focus"}}},
+	}}})
+
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: "/rev"}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyTab}, agent)
+	if screen := frameText(t, app); !strings.Contains(screen, "> /review focus") {
+		t.Errorf("Expected the completed command with its input hint:\n%s", screen)
+	}
+
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: " parser"}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter}, agent)
+	if len(agent.sent) != 1 || agent.sent[0] != "/review parser" {
+		t.Errorf("Expected the command sent, got %q", agent.sent)
+	}
+
+	app.working = false
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: "/help"}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter}, agent)
+	if len(agent.sent) != 1 {
+		t.Errorf("Expected /help to stay local, got %q", agent.sent)
+	}
+	if screen := frameText(t, app); !strings.Contains(screen, "/review <focus>") {
+		t.Errorf("Expected the agent's commands in the help:\n%s", screen)
+	}
+}
+
+func TestHandleKey_MentionAttachesFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("NOTES", []byte("# Notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApp(100, 30)
+	agent := &fakeAgent{}
+	app.handleKey(terminal.Key{Type: terminal.KeyPaste, Text: "summarise @NOTES"}, agent)
+	app.handleKey(terminal.Key{Type: terminal.KeyEnter}, agent)
+
+	if len(agent.prompts) != 1 || len(agent.prompts[0]) != 2 {
+		t.Fatalf("Expected the text and the file, got %+v", agent.prompts)
+	}
+	if resource := agent.prompts[0][1].Resource; resource == nil || resource.Text != "# Notes" {
+		t.Errorf("Expected the file embedded, got %+v", agent.prompts[0][1])
+	}
+	if screen := frameText(t, app); !strings.Contains(screen, "NOTES (text/plain, 7 B, embedded)") {
+		t.Errorf("Expected the attachment listed:\n%s", screen)
+	}
+}
@@ -0,0 +1,244 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"agentgo/internal/lineedit"
+	"agentgo/internal/render"
+	"agentgo/providers/claude"
+)
+
+// The smallest terminal the layout is drawn in, and the narrowest one that
+// still gets a sidebar
+const (
+	minWidth        = 40
+	minHeight       = 10
+	minSidebarWidth = 24
+	maxSidebarWidth = 40
+	sidebarFrom     = 80
+)
+
+// spinnerInterval is how often the status line redraws while the agent works
+const spinnerInterval = 100 * time.Millisecond
+
+var (
+	paneTitleStyle = render.Style{Color: render.Cyan, Bold: true}
+	dimStyle       = render.Style{Color: render.Black, Bold: true}
+)
+
+// sidebarWidth is the width of the plan and tools column, or 0 when the
+// terminal is too narrow for one
+func sidebarWidth(width int) int {
+	if width < sidebarFrom {
+		return 0
+	}
+	return min(max(width/3, minSidebarWidth), maxSidebarWidth)
+}
+
+// conversationWidth is the width of the transcript pane
+func conversationWidth(width int) int {
+	if sidebar := sidebarWidth(width); sidebar > 0 {
+		return width - sidebar - 1
+	}
+	return width
+}
+
+// bodyHeight is the height of the panes above the input box, which takes the
+// queued prompts, completions, a status line, the input line and a hint line
+func (a *App) bodyHeight() int {
+	return max(a.height-3-len(a.queueLines())-len(a.completionLines()), 1)
+}
+
+// completionLines lists the candidates offered after Tab. The caller holds
+// the mutex.
+func (a *App) completionLines() []string {
+	return lineedit.CompletionLines(a.input.View().Completions, a.width)
+}
+
+// queueLines lists the prompts waiting for the running turn to end. The
+// caller holds the mutex.
+func (a *App) queueLines() []string {
+	return lineedit.QueueLines(a.queue.Items(), a.width)
+}
+
+// frame lays out the whole screen as rows exactly width columns wide, and
+// returns where the cursor belongs. The caller holds the mutex.
+func (a *App) frame() (rows []string, cursorRow, cursorCol int) {
+	width, height := a.width, a.height
+	painter := render.NewTextRenderer(nil, a.capabilities)
+
+	if width < minWidth || height < minHeight {
+		rows = make([]string, height)
+		for i := range rows {
+			rows[i] = pad("", width)
+		}
+		rows[0] = pad(fmt.Sprintf("Terminal too small (%dx%d), need %dx%d", width, height, minWidth, minHeight), width)
+		return rows, 0, 0
+	}
+
+	bodyHeight := a.bodyHeight()
+	conversation, maxScroll := a.transcript.view(conversationWidth(width), bodyHeight, a.scroll)
+	a.scroll = min(a.scroll, maxScroll)
+
+	// Anchor the conversation to the bottom of its pane
+	conversation = append(make([]string, bodyHeight-len(conversation)), conversation...)
+
+	sidebar := a.sidebar(painter, sidebarWidth(width), bodyHeight)
+	separator := painter.Paint(dimStyle, "│")
+
+	for i := 0; i < bodyHeight; i++ {
+		row := pad(conversation[i], conversationWidth(width))
+		if sidebar != nil {
+			row += separator + sidebar[i]
+		}
+		rows = append(rows, row)
+	}
+
+	for _, line := range a.queueLines() {
+		rows = append(rows, painter.Paint(dimStyle, pad(line, width)))
+	}
+	for _, line := range a.completionLines() {
+		rows = append(rows, painter.Paint(dimStyle, pad(line, width)))
+	}
+	rows = append(rows, painter.Paint(dimStyle, pad(a.statusLine(), width)))
+
+	prompt := "> "
+	view := a.input.View()
+	input, inputCursor := inputLine(view, width-render.StringWidth(prompt)-1)
+	if hint := lineedit.HintText(input, view.Hint); hint != "" && render.StringWidth(prompt+input+hint) < width {
+		input += painter.Paint(dimStyle, hint)
+	}
+	rows = append(rows, pad(prompt+input, width))
+	cursorRow, cursorCol = len(rows)-1, render.StringWidth(prompt)+inputCursor
+
+	rows = append(rows, painter.Paint(dimStyle, pad(a.hintLine(), width)))
+
+	if a.modal != nil {
+		rows = a.modal.overlay(rows, width, painter)
+	}
+
+	return rows, cursorRow, cursorCol
+}
+
+func (a *App) hintLine() string {
+	switch {
+	case a.working && a.queue.Len() > 0:
+		return "Enter queue · ↑ edit last queued · Ctrl-S send now · Esc interrupt · PgUp/PgDn scroll"
+	case a.working:
+		return "Enter queue · Ctrl-S send now · Esc interrupt · PgUp/PgDn scroll · End latest"
+	}
+	return "Enter send · Shift-Enter newline · Ctrl-R history · PgUp/PgDn scroll · End latest · Ctrl-C quit"
+}
+
+func (a *App) statusLine() string {
+	var parts []string
+	if a.working {
+		label := "agent is working…"
+		if a.cancelling {
+			label = "interrupting…"
+		}
+		parts = append(parts, render.SpinnerText(label, time.Since(a.started), a.capabilities.Unicode))
+	} else {
+		parts = append(parts, "ready")
+	}
+	if a.scroll > 0 {
+		parts = append(parts, fmt.Sprintf("scrolled up %d lines", a.scroll))
+	}
+	return "── " + strings.Join(parts, " · ") + " "
+}
+
+// sidebar draws the plan above the tool activity, each row width columns wide
+func (a *App) sidebar(painter *render.TextRenderer, width, height int) []string {
+	if width == 0 {
+		return nil
+	}
+
+	var plan []string
+	plan = append(plan, painter.Paint(paneTitleStyle, " Plan"))
+	if a.plan == nil || len(a.plan.Entries) == 0 {
+		plan = append(plan, painter.Paint(dimStyle, " No plan yet"))
+	} else {
+		for _, entry := range a.plan.Entries {
+			icon, style := claude.TodoStatusStyle(entry.Status)
+			plan = append(plan, render.Wrap(painter.Paint(style, fmt.Sprintf(" %s %s", icon, entry.Content)), width)...)
+		}
+	}
+
+	var tools []string
+	tools = append(tools, painter.Paint(paneTitleStyle, " Tools"))
+	if len(a.tools) == 0 {
+		tools = append(tools, painter.Paint(dimStyle, " No tool calls yet"))
+	}
+	for _, tool := range a.tools {
+		title := tool.title
+		if title == "" {
+			title = tool.kind
+		}
+		tools = append(tools, fmt.Sprintf(" %s %s", toolStatusIcon(tool.status), title))
+	}
+
+	// The plan gets the top half; the tools pane shows its most recent calls
+	planHeight := min(len(plan), height/2)
+	plan = plan[:planHeight]
+	toolsHeight := max(height-planHeight-1, 1)
+	if len(tools) > toolsHeight {
+		tools = append(tools[:1], tools[len(tools)-toolsHeight+1:]...)
+	}
+
+	rows := append(plan, painter.Paint(dimStyle, strings.Repeat("─", width)))
+	rows = append(rows, tools...)
+	for len(rows) < height {
+		rows = append(rows, "")
+	}
+	for i := range rows {
+		rows[i] = pad(rows[i], width)
+	}
+	return rows[:height]
+}
+
+func toolStatusIcon(status string) string {
+	switch status {
+	case "completed":
+		return "✅"
+	case "in_progress":
+		return "🔄"
+	case "failed":
+		return "❌"
+	default:
+		return "⏳"
+	}
+}
+
+// pad fits s to exactly width columns, cutting or filling with spaces
+func pad(s string, width int) string {
+	s = render.Truncate(s, width, "")
+	if fill := width - render.StringWidth(s); fill > 0 {
+		s += strings.Repeat(" ", fill)
+	}
+	return s
+}
+
+// inputLine flattens the text being edited onto one row of width columns,
+// showing newlines as ⏎ and scrolling so the cursor stays in view. It returns
+// the row and the cursor's column within it.
+func inputLine(view lineedit.View, width int) (string, int) {
+	text := strings.Join(view.Lines, "⏎")
+	cursor := view.CursorColumn
+	for _, line := range view.Lines[:view.CursorLine] {
+		cursor += render.StringWidth(line) + 1
+	}
+	if cursor <= width {
+		return render.Truncate(text, width, ""), cursor
+	}
+
+	// Drop graphemes from the start until the cursor fits
+	graphemes := render.Graphemes(text)
+	start := 0
+	for cursor > width && start < len(graphemes) {
+		cursor -= render.GraphemeWidth(graphemes[start])
+		start++
+	}
+	return render.Truncate(strings.Join(graphemes[start:], ""), width, ""), cursor
+}
@@ -0,0 +1,109 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"agentgo/internal/render"
+	"agentgo/internal/terminal"
+	"agentgo/protocol"
+	"agentgo/providers/claude"
+)
+
+// maxModalWidth keeps permission dialogs readable on wide terminals
+const maxModalWidth = 72
+
+// permissionModal is the dialog for one permission request
+type permissionModal struct {
+	params       protocol.SessionRequestPermissionParams
+	capabilities render.Capabilities
+	selected     int
+	answered     bool
+
+	// chosen receives the index of the option the user picked, or -1 when
+	// the turn is cancelled
+	chosen chan int
+}
+
+func newPermissionModal(params protocol.SessionRequestPermissionParams, capabilities render.Capabilities) *permissionModal {
+	return &permissionModal{
+		params:       params,
+		capabilities: capabilities,
+		chosen:       make(chan int, 1),
+	}
+}
+
+// handleKey moves the selection or picks an option. The caller holds the
+// app's mutex.
+func (m *permissionModal) handleKey(key terminal.Key) {
+	if m.answered {
+		return
+	}
+
+	options := len(m.params.Options)
+	switch key.Type {
+	case terminal.KeyUp, terminal.KeyLeft:
+		m.selected = (m.selected + options - 1) % options
+	case terminal.KeyDown, terminal.KeyRight, terminal.KeyTab:
+		m.selected = (m.selected + 1) % options
+	case terminal.KeyEnter:
+		m.choose(m.selected)
+	case terminal.KeyRune:
+		if n := int(key.Rune - '0'); !key.Ctrl && n >= 1 && n <= options {
+			m.choose(n - 1)
+		}
+	}
+}
+
+func (m *permissionModal) choose(option int) {
+	m.selected = option
+	m.answered = true
+	m.chosen <- option
+}
+
+// cancel closes the dialog without a choice. The caller holds the app's
+// mutex.
+func (m *permissionModal) cancel() {
+	if m.answered {
+		return
+	}
+	m.answered = true
+	m.chosen <- -1
+}
+
+// drawRequest draws the tool request the way the line interface shows it
+func (m *permissionModal) drawRequest(r render.Renderer) {
+	claude.DisplayToolRequest(r, claude.ToolRequestEvent(m.params, time.Now()), m.params.Options)
+}
+
+// overlay draws the dialog centered over rows
+func (m *permissionModal) overlay(rows []string, width int, painter render.Renderer) []string {
+	modalWidth := min(width-4, maxModalWidth)
+
+	var box bytes.Buffer
+	capabilities := m.capabilities
+	capabilities.Width = modalWidth
+	m.drawRequest(render.NewTextRenderer(&box, capabilities))
+
+	lines := strings.Split(strings.Trim(box.String(), "\n"), "\n")
+	selected := m.params.Options[m.selected]
+	lines = append(lines,
+		"",
+		painter.Paint(render.Style{Color: render.Yellow, Bold: true}, fmt.Sprintf("❯ %s", selected.Name)),
+		painter.Paint(dimStyle, fmt.Sprintf("↑/↓ to change, Enter or 1-%d to choose", len(m.params.Options))),
+	)
+
+	// Keep the end of the dialog, with the options, when it is too tall
+	if len(lines) > len(rows) {
+		lines = lines[len(lines)-len(rows):]
+	}
+
+	top := (len(rows) - len(lines)) / 2
+	left := (width - modalWidth) / 2
+	for i, line := range lines {
+		rows[top+i] = pad(strings.Repeat(" ", left)+pad(line, modalWidth), width)
+	}
+	return rows
+}
@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"strings"
+	"sync"
+
+	"agentgo/internal/render"
+)
+
+// maxTranscriptLines bounds the scrollback; the oldest lines are dropped
+const maxTranscriptLines = 5000
+
+// transcript collects everything drawn into the conversation pane. It is an
+// io.Writer so the provider's renderers can draw into it.
+type transcript struct {
+	mutex   sync.Mutex
+	lines   []string
+	partial string
+}
+
+func (t *transcript) Write(p []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	text := t.partial + string(p)
+	lines := strings.Split(text, "\n")
+	t.partial = lines[len(lines)-1]
+	t.lines = append(t.lines, lines[:len(lines)-1]...)
+
+	if excess := len(t.lines) - maxTranscriptLines; excess > 0 {
+		t.lines = append([]string(nil), t.lines[excess:]...)
+	}
+	return len(p), nil
+}
+
+// view returns the rows of the transcript visible in a pane of the given
+// size, scrolled up by scroll rows from the bottom. It also returns the
+// largest useful scroll offset.
+func (t *transcript) view(width, height, scroll int) (rows []string, maxScroll int) {
+	t.mutex.Lock()
+	lines := append([]string(nil), t.lines...)
+	if t.partial != "" {
+		lines = append(lines, t.partial)
+	}
+	t.mutex.Unlock()
+
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, render.Wrap(line, width)...)
+	}
+
+	maxScroll = max(len(wrapped)-height, 0)
+	scroll = min(max(scroll, 0), maxScroll)
+
+	end := len(wrapped) - scroll
+	start := max(end-height, 0)
+	return wrapped[start:end], maxScroll
+}
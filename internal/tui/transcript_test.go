@@ -0,0 +1,49 @@
+package tui
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTranscript_View(t *testing.T) {
+	tr := &transcript{}
+	fmt.Fprint(tr, "first line\nsecond ")
+	fmt.Fprint(tr, "line continues\nthird")
+
+	rows, maxScroll := tr.view(20, 10, 0)
+	expected := []string{"first line", "second line", "continues", "third"}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("view() = %q, expected %q", rows, expected)
+	}
+	if maxScroll != 0 {
+		t.Errorf("Expected no scrolling when everything fits, got %d", maxScroll)
+	}
+
+	rows, maxScroll = tr.view(20, 2, 0)
+	if !reflect.DeepEqual(rows, expected[2:]) {
+		t.Errorf("view() of the bottom = %q, expected %q", rows, expected[2:])
+	}
+	if maxScroll != 2 {
+		t.Errorf("Expected max scroll 2, got %d", maxScroll)
+	}
+
+	rows, _ = tr.view(20, 2, 99)
+	if !reflect.DeepEqual(rows, expected[:2]) {
+		t.Errorf("view() scrolled past the top = %q, expected %q", rows, expected[:2])
+	}
+}
+
+func TestTranscript_DropsOldestLines(t *testing.T) {
+	tr := &transcript{}
+	for i := 0; i < maxTranscriptLines+10; i++ {
+		fmt.Fprintf(tr, "line %d\n", i)
+	}
+
+	if len(tr.lines) != maxTranscriptLines {
+		t.Fatalf("Expected %d lines, got %d", maxTranscriptLines, len(tr.lines))
+	}
+	if tr.lines[0] != "line 10" {
+		t.Errorf("Expected the oldest lines to be dropped, first is %q", tr.lines[0])
+	}
+}
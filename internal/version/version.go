@@ -0,0 +1,5 @@
+package version
+
+// Version is the agentgo release, overridden at build time with
+// -ldflags "-X agentgo/internal/version.Version=..."
+var Version = "0.1.0-dev"
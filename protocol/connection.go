@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"sync"
+	"time"
 )
 
 type AcpConnection struct {
@@ -12,7 +15,23 @@ type AcpConnection struct {
 	reader    io.Reader
 	writer    io.Writer
 	sessionID string
-	recorder  ConversationRecorder
+	session   SessionNewResult
+	agent     AgentCapabilities
+	// skipInitialize is set when replaying a recording made before
+	// agentgo sent initialize
+	skipInitialize bool
+	recorder       ConversationRecorder
+	messages       *MessageReader
+	maxMessageSize int
+	logger         *log.Logger
+	// trace logs every message sent and received
+	trace         bool
+	registry      *Registry
+	dispatchQueue int
+	outbound      *MessageWriter
+	outboundOnce  sync.Once
+	writeTimeout  time.Duration
+	requests      clientRequests
 }
 
 // OpenAcpConnection creates a new ACP connection with the given IO provider
@@ -22,12 +41,112 @@ func OpenAcpConnection(provider IOProvider) (*AcpConnection, error) {
 	}
 
 	return &AcpConnection{
-		provider: provider,
-		reader:   provider.GetReader(),
-		writer:   provider.GetWriter(),
+		provider:       provider,
+		reader:         provider.GetReader(),
+		writer:         provider.GetWriter(),
+		maxMessageSize: DefaultMaxMessageSize,
+		writeTimeout:   DefaultWriteTimeout,
+		logger:         log.New(os.Stderr, "acp: ", log.LstdFlags),
 	}, nil
 }
 
+// SetMaxMessageSize sets the largest message accepted from the agent.
+// It must be called before the first message is read.
+func (acpConn *AcpConnection) SetMaxMessageSize(size int) {
+	acpConn.maxMessageSize = size
+}
+
+// SetDispatchQueueSize sets how many messages are buffered per session before
+// reading from the agent pauses
+func (acpConn *AcpConnection) SetDispatchQueueSize(size int) {
+	acpConn.dispatchQueue = size
+}
+
+// SetWriteTimeout sets how long a single outgoing message may take to reach the
+// agent. It must be called before the first message is sent.
+func (acpConn *AcpConnection) SetWriteTimeout(timeout time.Duration) {
+	acpConn.writeTimeout = timeout
+}
+
+// SetLogger sets the logger used to report agent noise and malformed messages
+func (acpConn *AcpConnection) SetLogger(logger *log.Logger) {
+	acpConn.logger = logger
+}
+
+// SetTrace turns on logging of every message exchanged with the agent
+func (acpConn *AcpConnection) SetTrace(trace bool) {
+	acpConn.trace = trace
+}
+
+// SessionID returns the ID of the session, once it has been created
+func (acpConn *AcpConnection) SessionID() string {
+	return acpConn.sessionID
+}
+
+// Registry returns the method registry used to route incoming agent messages
+func (acpConn *AcpConnection) Registry() *Registry {
+	if acpConn.registry == nil {
+		acpConn.registry = NewRegistry()
+	}
+	return acpConn.registry
+}
+
+// logf reports a protocol event on the connection's logger
+func (acpConn *AcpConnection) logf(format string, args ...any) {
+	if acpConn == nil || acpConn.logger == nil {
+		return
+	}
+	acpConn.logger.Printf(format, args...)
+}
+
+// messageReader returns the shared line-framed reader, creating it on first use
+func (acpConn *AcpConnection) messageReader() *MessageReader {
+	if acpConn.messages == nil {
+		acpConn.messages = NewMessageReader(acpConn.reader, acpConn.maxMessageSize, acpConn.logger)
+	}
+	return acpConn.messages
+}
+
+// messageWriter returns the shared outbound writer, starting it on first use
+func (acpConn *AcpConnection) messageWriter() *MessageWriter {
+	acpConn.outboundOnce.Do(func() {
+		acpConn.outbound = NewMessageWriter(acpConn.writer, acpConn.writeTimeout, 0, acpConn.recordOutbound)
+	})
+	return acpConn.outbound
+}
+
+// recordOutbound records a message once it has been sent to the agent
+func (acpConn *AcpConnection) recordOutbound(data []byte) {
+	if acpConn.trace {
+		acpConn.logf("→ %s", data)
+	}
+	if acpConn.recorder == nil {
+		return
+	}
+	if err := acpConn.recorder.RecordOutbound(data); err != nil {
+		acpConn.logf("failed to record outgoing message: %v", err)
+	}
+}
+
+// readMessage reads the next message from the agent and records it
+func (acpConn *AcpConnection) readMessage() (*Message, error) {
+	message, err := acpConn.messageReader().ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if acpConn.trace {
+		acpConn.logf("← %s", message.Raw)
+	}
+
+	if acpConn.recorder != nil {
+		if err := acpConn.recorder.RecordMessage(message.Raw); err != nil {
+			return nil, fmt.Errorf("failed to record message: %v", err)
+		}
+	}
+
+	return message, nil
+}
+
 // OpenAcpStdioConnection creates a new ACP connection using binary execution (backward compatible)
 func OpenAcpStdioConnection(
 	command string,
@@ -57,26 +176,40 @@ func OpenAcpRecordingConnection(
 		conn.Close()
 		return nil, err
 	}
+	recorder.header.AgentCommand = append([]string{command}, args...)
 	conn.recorder = recorder
 	return conn, nil
 }
 
 // OpenAcpReplayConnection creates a connection that replays recorded messages
-func OpenAcpReplayConnection(recordingFile string) (*AcpConnection, error) {
-	provider, err := NewReplayIOProvider(recordingFile)
+func OpenAcpReplayConnection(recordingFile string, options ReplayOptions) (*AcpConnection, error) {
+	provider, err := NewReplayIOProvider(recordingFile, options)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := OpenAcpConnection(provider)
 	if err != nil {
 		return nil, err
 	}
-	return OpenAcpConnection(provider)
+	conn.skipInitialize = !provider.initializes
+	conn.requests.replay(provider.requestIDs)
+	return conn, nil
 }
 
-// InitializeSession initializes a new session and returns the session ID
+// InitializeSession exchanges capabilities with the agent, then creates a
+// new session and returns its ID
 func (acpConn *AcpConnection) InitializeSession() (string, error) {
+	if !acpConn.skipInitialize {
+		if err := acpConn.initialize(); err != nil {
+			return "", err
+		}
+	}
+
 	cwd, _ := os.Getwd()
 	sessionNewReq := SessionNewRequest{
 		JSONRPC: "2.0",
-		ID:      0,
-		Method:  "session/new",
+		ID:      acpConn.requests.reserve(MethodSessionNew),
+		Method:  MethodSessionNew,
 		Params: SessionParams{
 			Cwd:        cwd,
 			MCPServers: make([]MCPServer, 0),
@@ -85,41 +218,91 @@ func (acpConn *AcpConnection) InitializeSession() (string, error) {
 
 	data, err := json.Marshal(sessionNewReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to encode request to gemini: %v", err)
+		return "", fmt.Errorf("failed to encode request to gemini: %w", err)
 	}
 
-	_, err = acpConn.writer.Write(append(data, '\n'))
+	err = acpConn.messageWriter().WriteMessage(data)
 	if err != nil {
-		return "", fmt.Errorf("failed to write request to gemini: %v", err)
+		return "", fmt.Errorf("failed to write request to gemini: %w", err)
 	}
 
-	response := map[string]any{}
-	if err := json.NewDecoder(acpConn.reader).Decode(&response); err != nil {
-		return "", fmt.Errorf("failed to decode response from gemini: %v", err)
+	response, err := acpConn.readMessage()
+	if err != nil {
+		return "", fmt.Errorf("failed to decode response from gemini: %w", err)
 	}
 
-	if _, ok := response["result"]; !ok {
-		return "", fmt.Errorf("expected result, did not get it %v", response)
+	if len(response.Result) == 0 {
+		return "", fmt.Errorf("expected result, did not get it %s", response.Raw)
 	}
 
-	resultMap := response["result"].(map[string]any)
+	var result SessionNewResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return "", fmt.Errorf("expected sessionID to be type of string: %v", err)
+	}
 
-	if sessionID, hasSessionID := resultMap["sessionId"]; hasSessionID {
-		if _, ok := sessionID.(string); !ok {
-			return "", fmt.Errorf("expected sessionID to be type of string: %v", sessionID)
+	if result.SessionID == "" {
+		return "", fmt.Errorf("session/new result has no sessionId: %s", response.Result)
+	}
+
+	acpConn.sessionID = result.SessionID
+	acpConn.session = result
+
+	if acpConn.recorder != nil {
+		if err := acpConn.recorder.SetSessionID(result.SessionID); err != nil {
+			return "", fmt.Errorf("failed to write recording header: %v", err)
 		}
+	}
+
+	return result.SessionID, nil
+}
+
+// initialize sends initialize and keeps the agent's capabilities. An agent
+// that rejects it is still used, without any optional capabilities.
+func (acpConn *AcpConnection) initialize() error {
+	data, err := json.Marshal(InitializeRequest{
+		JSONRPC: "2.0",
+		ID:      acpConn.requests.reserve(MethodInitialize),
+		Method:  MethodInitialize,
+		Params:  Params{ProtocolVersion: ProtocolVersion},
+	})
+	if err != nil {
+		return err
+	}
+	if err := acpConn.messageWriter().WriteMessage(data); err != nil {
+		return fmt.Errorf("failed to write initialize request: %w", err)
+	}
+
+	response, err := acpConn.readMessage()
+	if err != nil {
+		return fmt.Errorf("failed to read initialize response: %w", err)
+	}
+	if err := responseError(response); err != nil {
+		acpConn.logf("initialize failed, continuing without agent capabilities: %v", err)
+		return nil
+	}
 
-		acpConn.sessionID = sessionID.(string)
-		return sessionID.(string), nil
+	var result InitializeResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return fmt.Errorf("invalid initialize result: %v", err)
 	}
+	acpConn.agent = result.AgentCapabilities
+	return nil
+}
 
-	return "", fmt.Errorf("")
+// PromptCapabilities returns the content the agent accepts in prompts, as
+// it reported in its initialize response
+func (acpConn *AcpConnection) PromptCapabilities() PromptCapabilities {
+	return acpConn.agent.PromptCapabilities
 }
 
 // Close closes the connection and cleans up resources
 func (acpConn *AcpConnection) Close() error {
 	var err error
 
+	if acpConn.outbound != nil {
+		acpConn.outbound.Close()
+	}
+
 	if acpConn.recorder != nil {
 		if recErr := acpConn.recorder.Close(); recErr != nil {
 			err = recErr
@@ -137,15 +320,20 @@ func (acpConn *AcpConnection) Close() error {
 
 // SendMessage sends a user message to the session
 func (acpConn *AcpConnection) SendMessage(message string) error {
+	return acpConn.SendPrompt([]Prompt{{Type: "text", Text: message}})
+}
+
+// SendPrompt starts a turn with a prompt made of several content blocks,
+// such as text followed by attached files. The prompt's response ends the
+// turn.
+func (acpConn *AcpConnection) SendPrompt(prompt []Prompt) error {
 	promptReq := SessionPromptRequest{
 		JSONRPC: "2.0",
-		ID:      1,
-		Method:  "session/prompt",
+		ID:      acpConn.requests.addPrompt(),
+		Method:  MethodSessionPrompt,
 		Params: SessionPromptParams{
 			SessionID: acpConn.sessionID,
-			Prompt: []Prompt{
-				{Type: "text", Text: message},
-			},
+			Prompt:    prompt,
 		},
 	}
 
@@ -154,7 +342,7 @@ func (acpConn *AcpConnection) SendMessage(message string) error {
 		return err
 	}
 
-	_, err = acpConn.writer.Write(append(data, '\n'))
+	err = acpConn.messageWriter().WriteMessage(data)
 	if err != nil {
 		return err
 	}
@@ -164,14 +352,60 @@ func (acpConn *AcpConnection) SendMessage(message string) error {
 
 // SendToolResponse sends a tool permission response
 func (acpConn *AcpConnection) SendToolResponse(reqID int, optionID string) error {
+	return acpConn.sendPermissionOutcome(reqID, ToolPermissionOutcome{
+		Outcome:  PermissionOutcomeSelected,
+		OptionID: optionID,
+	})
+}
+
+// SendToolCancelled answers a permission request that was pending when the
+// turn was cancelled
+func (acpConn *AcpConnection) SendToolCancelled(reqID int) error {
+	return acpConn.sendPermissionOutcome(reqID, ToolPermissionOutcome{Outcome: PermissionOutcomeCancelled})
+}
+
+func (acpConn *AcpConnection) sendPermissionOutcome(reqID int, outcome ToolPermissionOutcome) error {
 	resp := ToolPermissionResponse{
 		JSONRPC: "2.0",
 		ID:      reqID,
 		Result: ToolPermissionResult{
-			Outcome: ToolPermissionOutcome{
-				Outcome:  "selected",
-				OptionID: optionID,
-			},
+			Outcome: outcome,
+		},
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	return acpConn.messageWriter().WriteMessage(data)
+}
+
+// SendCancel asks the agent to stop the running turn. The agent still
+// answers the prompt, with the cancelled stop reason.
+func (acpConn *AcpConnection) SendCancel() error {
+	cancel := SessionCancelNotification{
+		JSONRPC: "2.0",
+		Method:  MethodSessionCancel,
+		Params:  SessionCancelParams{SessionID: acpConn.sessionID},
+	}
+
+	data, err := json.Marshal(cancel)
+	if err != nil {
+		return err
+	}
+
+	return acpConn.messageWriter().WriteMessage(data)
+}
+
+// SendError replies to an agent request with a JSON-RPC error
+func (acpConn *AcpConnection) SendError(reqID any, code int, message string) error {
+	resp := ErrorResponse{
+		JSONRPC: "2.0",
+		ID:      reqID,
+		Error: ResponseError{
+			Code:    code,
+			Message: message,
 		},
 	}
 
@@ -180,7 +414,7 @@ func (acpConn *AcpConnection) SendToolResponse(reqID int, optionID string) error
 		return err
 	}
 
-	_, err = acpConn.writer.Write(append(data, '\n'))
+	err = acpConn.messageWriter().WriteMessage(data)
 	if err != nil {
 		return err
 	}
@@ -1,7 +1,13 @@
 package protocol
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAcpConnection_Construction(t *testing.T) {
@@ -21,22 +27,21 @@ func TestAcpConnection_Construction(t *testing.T) {
 		t.Error("Expected error for non-existent binary")
 	}
 
-	_, err = OpenAcpReplayConnection("non-existent-file.jsonl")
+	_, err = OpenAcpReplayConnection("non-existent-file.jsonl", RealTimeReplay)
 	if err == nil {
 		t.Error("Expected error for non-existent file")
 	}
 }
 
 func TestMessageRouting(t *testing.T) {
-	ch := make(chan int, 1)
-
-	handler := &MockHandler{}
+	ch := make(chan TurnResult, 1)
 
 	response := map[string]any{
-		"method": "unknown/method",
+		"jsonrpc": "2.0",
+		"method":  "unknown/method",
 	}
 
-	err := RouteMessage(handler, ch, nil, response)
+	err := RouteMessage(ch, &AcpConnection{}, messageFrom(t, response))
 	if err != nil {
 		t.Errorf("RouteMessage should not error on unknown methods: %v", err)
 	}
@@ -48,12 +53,215 @@ func TestMessageRouting(t *testing.T) {
 	}
 }
 
-type MockHandler struct{}
+func TestMessageRouting_UnknownRequest(t *testing.T) {
+	var out bytes.Buffer
+	conn := &AcpConnection{writer: &out}
+
+	response := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      7.0,
+		"method":  "fs/read_text_file",
+	}
+
+	if err := RouteMessage(make(chan TurnResult, 1), conn, messageFrom(t, response)); err != nil {
+		t.Fatalf("RouteMessage returned error: %v", err)
+	}
+
+	var reply ErrorResponse
+	if err := json.Unmarshal(out.Bytes(), &reply); err != nil {
+		t.Fatalf("Expected an error response, got %q: %v", out.String(), err)
+	}
+	if reply.ID != 7.0 {
+		t.Errorf("Expected id 7, got %v", reply.ID)
+	}
+	if reply.Error.Code != ErrorCodeMethodNotFound {
+		t.Errorf("Expected code %d, got %d", ErrorCodeMethodNotFound, reply.Error.Code)
+	}
+}
+
+func TestMessageRouting_RegisteredMethod(t *testing.T) {
+	var out bytes.Buffer
+	conn := &AcpConnection{writer: &out}
+
+	var called bool
+	conn.Registry().Handle("_vendor/ping", func(acpConn *AcpConnection, msg *Message) error {
+		called = true
+		return nil
+	})
+
+	response := map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "_vendor/ping",
+	}
+
+	if err := RouteMessage(make(chan TurnResult, 1), conn, messageFrom(t, response)); err != nil {
+		t.Fatalf("RouteMessage returned error: %v", err)
+	}
+	if !called {
+		t.Error("Expected registered handler to be called")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no reply for a handled notification, got %q", out.String())
+	}
+}
+
+// messageFrom encodes a message literal the way it would arrive on the wire
+func messageFrom(t *testing.T, fields map[string]any) *Message {
+	t.Helper()
 
-func (m *MockHandler) HandlePermissionRequest(*AcpConnection, []byte, SessionRequestPermissionRequest) error {
-	return nil
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+
+	message := &Message{}
+	if err := json.Unmarshal(raw, message); err != nil {
+		t.Fatalf("Failed to decode message: %v", err)
+	}
+	message.Raw = raw
+	return message
 }
 
-func (m *MockHandler) HandleNotification([]byte, SessionUpdateRequest) error {
-	return nil
+func TestMessageRouting_PromptResponseEndsTurn(t *testing.T) {
+	tests := []struct {
+		name     string
+		response map[string]any
+		expected TurnResult
+		failed   bool
+	}{
+		{
+			name:     "stop reason",
+			response: map[string]any{"jsonrpc": "2.0", "result": map[string]any{"stopReason": "max_tokens"}},
+			expected: TurnResult{StopReason: StopReasonMaxTokens},
+		},
+		{
+			name:     "error",
+			response: map[string]any{"jsonrpc": "2.0", "error": map[string]any{"code": -32603, "message": "overloaded"}},
+			failed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &AcpConnection{}
+			tt.response["id"] = conn.requests.addPrompt()
+			turns := make(chan TurnResult, 1)
+			if err := RouteMessage(turns, conn, messageFrom(t, tt.response)); err != nil {
+				t.Fatalf("RouteMessage returned error: %v", err)
+			}
+
+			select {
+			case result := <-turns:
+				if tt.failed {
+					if result.Err == nil || !strings.Contains(result.Err.Error(), "overloaded") {
+						t.Errorf("Expected the agent's error, got %+v", result)
+					}
+				} else if result != tt.expected {
+					t.Errorf("Expected %+v, got %+v", tt.expected, result)
+				}
+			default:
+				t.Error("Expected the response to end the turn")
+			}
+
+			// Another response with the same ID does not end another turn
+			if err := RouteMessage(turns, conn, messageFrom(t, tt.response)); err != nil {
+				t.Fatalf("RouteMessage returned error: %v", err)
+			}
+			if len(turns) > 0 {
+				t.Error("Expected the turn to end only once")
+			}
+		})
+	}
+}
+
+func TestMessageRouting_OtherResponsesDoNotEndTurn(t *testing.T) {
+	turns := make(chan TurnResult, 1)
+	response := map[string]any{"jsonrpc": "2.0", "id": 5, "result": map[string]any{}}
+
+	if err := RouteMessage(turns, &AcpConnection{}, messageFrom(t, response)); err != nil {
+		t.Fatalf("RouteMessage returned error: %v", err)
+	}
+
+	select {
+	case result := <-turns:
+		t.Errorf("Response to another request ended the turn: %+v", result)
+	default:
+	}
+}
+
+func TestSendCancel(t *testing.T) {
+	var out bytes.Buffer
+	conn := &AcpConnection{writer: &out, sessionID: "s1"}
+
+	if err := conn.SendCancel(); err != nil {
+		t.Fatalf("SendCancel() error: %v", err)
+	}
+	if err := conn.SendToolCancelled(4); err != nil {
+		t.Fatalf("SendToolCancelled() error: %v", err)
+	}
+
+	expected := `{"jsonrpc":"2.0","method":"session/cancel","params":{"sessionId":"s1"}}` + "\n" +
+		`{"jsonrpc":"2.0","id":4,"result":{"outcome":{"outcome":"cancelled"}}}` + "\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestSetTrace_LogsMessages(t *testing.T) {
+	var out, logged bytes.Buffer
+	conn := &AcpConnection{
+		writer: &out,
+		reader: strings.NewReader(`{"jsonrpc":"2.0","id":7,"result":{}}` + "\n"),
+		logger: log.New(&logged, "", 0),
+	}
+	conn.SetTrace(true)
+
+	if err := conn.SendCancel(); err != nil {
+		t.Fatalf("SendCancel() error: %v", err)
+	}
+	if _, err := conn.readMessage(); err != nil {
+		t.Fatalf("readMessage() error: %v", err)
+	}
+
+	expected := `→ {"jsonrpc":"2.0","method":"session/cancel","params":{"sessionId":""}}` + "\n" +
+		`← {"jsonrpc":"2.0","id":7,"result":{}}` + "\n"
+	if logged.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, logged.String())
+	}
+}
+
+func TestSetMode_WaitsForResponse(t *testing.T) {
+	reader, writer := io.Pipe()
+	conn := &AcpConnection{writer: writer, sessionID: "s1"}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- conn.SetMode("plan")
+	}()
+
+	sent := &Message{}
+	if err := json.NewDecoder(reader).Decode(sent); err != nil {
+		t.Fatalf("Failed to read request: %v", err)
+	}
+	if sent.Method != MethodSessionSetMode || string(sent.Params) != `{"sessionId":"s1","modeId":"plan"}` {
+		t.Errorf("Unexpected request %s %s", sent.Method, sent.Params)
+	}
+
+	turns := make(chan TurnResult, 1)
+	response := map[string]any{"jsonrpc": "2.0", "id": json.RawMessage(sent.ID), "error": map[string]any{"code": -32602, "message": "unknown mode"}}
+	if err := RouteMessage(turns, conn, messageFrom(t, response)); err != nil {
+		t.Fatalf("RouteMessage returned error: %v", err)
+	}
+
+	select {
+	case err := <-result:
+		if err == nil || !strings.Contains(err.Error(), "unknown mode") {
+			t.Errorf("Expected the agent's error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for SetMode")
+	}
+	if len(turns) != 0 {
+		t.Error("Expected the response not to end a turn")
+	}
 }
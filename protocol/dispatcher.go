@@ -0,0 +1,122 @@
+package protocol
+
+// DefaultDispatchQueueSize is the number of messages buffered per session
+// before reading from the agent pauses
+const DefaultDispatchQueueSize = 256
+
+// MaxConcurrentRequests is the number of agent requests handled at once.
+// A session's worker waits while that many are in flight.
+const MaxConcurrentRequests = 64
+
+// dispatcher decouples reading agent messages from handling them. Messages
+// are queued per session and a worker takes them in arrival order:
+// notifications and responses are handled in turn, and each request is
+// started on its own goroutine, up to MaxConcurrentRequests at once, so a
+// pending permission prompt never blocks the stream. A request is thus
+// never handled before the updates the agent sent ahead of it.
+type dispatcher struct {
+	acpConn   *AcpConnection
+	turns     chan TurnResult
+	queueSize int
+	sessions  map[string]chan *Message
+	requests  chan struct{}
+	errs      chan error
+}
+
+func newDispatcher(acpConn *AcpConnection, turns chan TurnResult, queueSize int) *dispatcher {
+	if queueSize <= 0 {
+		queueSize = DefaultDispatchQueueSize
+	}
+
+	return &dispatcher{
+		acpConn:   acpConn,
+		turns:     turns,
+		queueSize: queueSize,
+		sessions:  make(map[string]chan *Message),
+		requests:  make(chan struct{}, MaxConcurrentRequests),
+		errs:      make(chan error, 1),
+	}
+}
+
+// dispatch queues a message for its session's worker. When the queue is
+// full it logs and blocks until the handlers catch up, returning early if
+// a handler has failed.
+func (d *dispatcher) dispatch(message *Message) error {
+	sessionID := d.sessionOf(message)
+	queue := d.sessionQueue(sessionID)
+
+	select {
+	case queue <- message:
+		return nil
+	default:
+	}
+
+	d.acpConn.logf("dispatch queue for session %q is full, pausing reads until handlers catch up", sessionID)
+	select {
+	case queue <- message:
+		return nil
+	case err := <-d.errs:
+		return err
+	}
+}
+
+// startRequest handles a request on its own goroutine once one of the
+// request slots is free
+func (d *dispatcher) startRequest(message *Message) {
+	select {
+	case d.requests <- struct{}{}:
+	default:
+		d.acpConn.logf("%d agent requests are in flight, waiting for one to finish", cap(d.requests))
+		d.requests <- struct{}{}
+	}
+
+	go func() {
+		defer func() { <-d.requests }()
+		d.handle(message)
+	}()
+}
+
+// close stops the session workers once their queues drain
+func (d *dispatcher) close() {
+	for _, queue := range d.sessions {
+		close(queue)
+	}
+}
+
+func (d *dispatcher) sessionQueue(sessionID string) chan *Message {
+	queue, ok := d.sessions[sessionID]
+	if !ok {
+		queue = make(chan *Message, d.queueSize)
+		d.sessions[sessionID] = queue
+		go func() {
+			for message := range queue {
+				if message.IsRequest() {
+					d.startRequest(message)
+				} else {
+					d.handle(message)
+				}
+			}
+		}()
+	}
+	return queue
+}
+
+// sessionOf returns the session a message belongs to, decoding it for its
+// handler once. Responses carry no session ID, and are ordered with the
+// connection's own session along with messages no typed handler takes.
+func (d *dispatcher) sessionOf(message *Message) string {
+	d.acpConn.Registry().decode(message)
+	if message.SessionID != "" {
+		return message.SessionID
+	}
+	return d.acpConn.sessionID
+}
+
+func (d *dispatcher) handle(message *Message) {
+	if err := RouteMessage(d.turns, d.acpConn, message); err != nil {
+		select {
+		case d.errs <- err:
+		default:
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package protocol
+
+import (
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamResponses_PermissionDoesNotBlockStream(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":5,"method":"session/request_permission","params":{"sessionId":"s1"}}`,
+		`{"jsonrpc":"2.0","method":"session/update","params":{"sessionId":"s1","update":{"sessionUpdate":"one"}}}`,
+		`{"jsonrpc":"2.0","method":"session/update","params":{"sessionId":"s1","update":{"sessionUpdate":"two"}}}`,
+		`{"jsonrpc":"2.0","id":0,"result":{"stopReason":"end_turn"}}`,
+	}, "\n") + "\n"
+
+	conn := &AcpConnection{reader: strings.NewReader(input), sessionID: "s1"}
+	conn.requests.addPrompt()
+
+	release := make(chan struct{})
+	permissionDone := make(chan struct{})
+	OnRequest(conn.Registry(), MethodSessionRequestPermission, func(acpConn *AcpConnection, raw []byte, req SessionRequestPermissionRequest) error {
+		<-release
+		close(permissionDone)
+		return nil
+	})
+
+	updates := make(chan string, 2)
+	OnNotification(conn.Registry(), MethodSessionUpdate, func(raw []byte, req SessionUpdateRequest) error {
+		updates <- req.Params.Update.SessionUpdateType
+		return nil
+	})
+
+	ch := make(chan TurnResult)
+	go conn.StreamResponses(ch)
+
+	for _, expected := range []string{"one", "two"} {
+		select {
+		case got := <-updates:
+			if got != expected {
+				t.Errorf("Expected update %q, got %q", expected, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Updates were blocked by the pending permission request")
+		}
+	}
+
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("Expected turn completion to be signalled")
+	}
+
+	close(release)
+	select {
+	case <-permissionDone:
+	case <-time.After(time.Second):
+		t.Fatal("Permission handler did not finish")
+	}
+}
+
+func TestStreamResponses_HandlerErrorStopsStream(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"session/update","params":{"sessionId":"s1"}}` + "\n" +
+		`{"jsonrpc":"2.0","method":"session/update","params":{"sessionId":"s1"}}` + "\n"
+
+	// Block the reader after the input so the stream only ends via the handler error
+	reader := &blockingReader{data: strings.NewReader(input), block: make(chan struct{})}
+	defer close(reader.block)
+
+	conn := &AcpConnection{reader: reader}
+	conn.Registry().Handle(MethodSessionUpdate, func(acpConn *AcpConnection, msg *Message) error {
+		return errTestHandler
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- conn.StreamResponses(make(chan TurnResult)) }()
+
+	select {
+	case err := <-done:
+		if err != errTestHandler {
+			t.Errorf("Expected handler error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StreamResponses did not return the handler error")
+	}
+}
+
+func TestDispatcher_BoundsConcurrentRequests(t *testing.T) {
+	conn := &AcpConnection{logger: log.New(io.Discard, "", 0)}
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	conn.Registry().Handle(MethodSessionRequestPermission, func(acpConn *AcpConnection, msg *Message) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	d := newDispatcher(conn, make(chan TurnResult), 0)
+	d.requests = make(chan struct{}, 2)
+	request := &Message{ID: []byte("1"), Method: MethodSessionRequestPermission}
+
+	for range 3 {
+		if err := d.dispatch(request); err != nil {
+			t.Fatalf("Unexpected dispatch error %v", err)
+		}
+	}
+
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("Expected two requests to be handled")
+		}
+	}
+	select {
+	case <-started:
+		t.Fatal("Expected the third request to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- struct{}{}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the third request to start once a slot freed")
+	}
+	close(release)
+}
+
+func TestDispatcher_RequestsWaitForEarlierUpdates(t *testing.T) {
+	conn := &AcpConnection{sessionID: "s1"}
+	var handled []string
+	var mutex sync.Mutex
+	record := func(name string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		handled = append(handled, name)
+	}
+
+	releaseUpdate := make(chan struct{})
+	permission := make(chan struct{})
+	conn.Registry().Handle(MethodSessionUpdate, func(acpConn *AcpConnection, msg *Message) error {
+		<-releaseUpdate
+		record("update")
+		return nil
+	})
+	conn.Registry().Handle(MethodSessionRequestPermission, func(acpConn *AcpConnection, msg *Message) error {
+		record("permission")
+		close(permission)
+		return nil
+	})
+
+	d := newDispatcher(conn, make(chan TurnResult), 0)
+	defer d.close()
+	for _, method := range []string{MethodSessionUpdate, MethodSessionRequestPermission} {
+		message := &Message{Method: method}
+		if method == MethodSessionRequestPermission {
+			message.ID = []byte("1")
+		}
+		if err := d.dispatch(message); err != nil {
+			t.Fatalf("Unexpected dispatch error %v", err)
+		}
+	}
+
+	select {
+	case <-permission:
+		t.Fatal("Expected the request to wait for the update before it")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(releaseUpdate)
+	select {
+	case <-permission:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the request to be handled after the update")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if strings.Join(handled, ",") != "update,permission" {
+		t.Errorf("Expected the update before the request, got %v", handled)
+	}
+}
+
+type testError string
+
+func (e testError) Error() string { return string(e) }
+
+const errTestHandler = testError("handler failed")
+
+// blockingReader serves data and then blocks until released
+type blockingReader struct {
+	data  *strings.Reader
+	block chan struct{}
+}
+
+func (b *blockingReader) Read(p []byte) (int, error) {
+	if b.data.Len() > 0 {
+		return b.data.Read(p)
+	}
+	<-b.block
+	return 0, errTestHandler
+}
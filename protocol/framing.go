@@ -0,0 +1,137 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// DefaultMaxMessageSize is the largest single message accepted from the agent
+const DefaultMaxMessageSize = 16 * 1024 * 1024
+
+// ErrMessageTooLarge is reported when a line exceeds the configured maximum size
+var ErrMessageTooLarge = errors.New("message exceeds maximum size")
+
+// MessageReader reads newline-delimited JSON-RPC messages from an agent.
+// Lines that are not JSON objects are treated as agent noise and skipped,
+// so a stray log line never ends the session.
+type MessageReader struct {
+	reader  *bufio.Reader
+	maxSize int
+	logger  *log.Logger
+}
+
+// NewMessageReader creates a line-framed reader with the given size limit
+func NewMessageReader(r io.Reader, maxSize int, logger *log.Logger) *MessageReader {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	return &MessageReader{
+		reader:  bufio.NewReader(r),
+		maxSize: maxSize,
+		logger:  logger,
+	}
+}
+
+// ReadMessage returns the next well-formed JSON-RPC message.
+// Noise, oversized lines and malformed envelopes are logged and skipped.
+func (m *MessageReader) ReadMessage() (*Message, error) {
+	for {
+		line, err := m.readLine()
+		if errors.Is(err, ErrMessageTooLarge) {
+			m.logger.Printf("dropped agent message: %v (limit %d bytes)", err, m.maxSize)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] != '{' {
+			m.logger.Printf("agent noise: %s", line)
+			continue
+		}
+
+		message := &Message{}
+		if jsonErr := json.Unmarshal(line, message); jsonErr != nil {
+			m.logger.Printf("agent noise (invalid JSON: %v): %s", jsonErr, line)
+			continue
+		}
+
+		if envErr := ValidateEnvelope(message); envErr != nil {
+			m.logger.Printf("malformed JSON-RPC envelope: %v: %s", envErr, line)
+			continue
+		}
+
+		message.Raw = line
+		return message, nil
+	}
+}
+
+// readLine reads a single line, discarding the remainder when it is too large
+func (m *MessageReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := m.reader.ReadLine()
+		if err != nil {
+			return line, err
+		}
+
+		if len(line)+len(chunk) > m.maxSize {
+			for isPrefix {
+				if _, isPrefix, err = m.reader.ReadLine(); err != nil {
+					return nil, err
+				}
+			}
+			return nil, ErrMessageTooLarge
+		}
+
+		line = append(line, chunk...)
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// ValidateEnvelope checks that a decoded message is a well-formed JSON-RPC 2.0
+// request, notification or response
+func ValidateEnvelope(message *Message) error {
+	if message.JSONRPC == "" {
+		return errors.New("missing jsonrpc field")
+	}
+	if message.JSONRPC != "2.0" {
+		return fmt.Errorf("unsupported jsonrpc version %q", message.JSONRPC)
+	}
+
+	hasID := len(message.ID) > 0
+	hasResult := len(message.Result) > 0
+	hasError := len(message.Error) > 0
+
+	if message.Method != "" {
+		if hasResult || hasError {
+			return errors.New("message has both method and result/error")
+		}
+		return nil
+	}
+
+	if !hasID {
+		return errors.New("message has neither id nor method")
+	}
+	if hasResult == hasError {
+		return errors.New("response must have exactly one of result or error")
+	}
+
+	return nil
+}
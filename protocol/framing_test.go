@@ -0,0 +1,124 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestMessageReader_SkipsNoise(t *testing.T) {
+	input := strings.Join([]string{
+		"starting agent...",
+		`{"jsonrpc":"2.0","method":"session/update","params":{}}`,
+		"",
+		`{"jsonrpc":"2.0","id":1,"result":{"stopReason":"end_turn"}}`,
+		"{not json",
+	}, "\n")
+
+	var logs bytes.Buffer
+	reader := NewMessageReader(strings.NewReader(input), 0, log.New(&logs, "", 0))
+
+	first, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error: %v", err)
+	}
+	if first.Method != "session/update" {
+		t.Errorf("Expected session/update, got %v", first.Method)
+	}
+
+	second, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error: %v", err)
+	}
+	if !second.IsResponse() || len(second.Result) == 0 {
+		t.Errorf("Expected response with result, got %s", second.Raw)
+	}
+
+	if _, err := reader.ReadMessage(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "agent noise: starting agent...") {
+		t.Errorf("Expected noise to be logged, got %q", logs.String())
+	}
+	if !strings.Contains(logs.String(), "invalid JSON") {
+		t.Errorf("Expected invalid JSON to be logged, got %q", logs.String())
+	}
+}
+
+func TestMessageReader_MaxMessageSize(t *testing.T) {
+	large := `{"jsonrpc":"2.0","method":"session/update","params":{"text":"` +
+		strings.Repeat("x", 128) + `"}}`
+	small := `{"jsonrpc":"2.0","id":1,"result":{}}`
+
+	var logs bytes.Buffer
+	reader := NewMessageReader(strings.NewReader(large+"\n"+small+"\n"), 64, log.New(&logs, "", 0))
+
+	message, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error: %v", err)
+	}
+	if string(message.Raw) != small {
+		t.Errorf("Expected to resynchronize on the small message, got %s", message.Raw)
+	}
+	if !strings.Contains(logs.String(), ErrMessageTooLarge.Error()) {
+		t.Errorf("Expected oversized message to be logged, got %q", logs.String())
+	}
+}
+
+func TestValidateEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		message map[string]any
+		wantErr bool
+	}{
+		{
+			name:    "request",
+			message: map[string]any{"jsonrpc": "2.0", "id": 1.0, "method": "session/request_permission"},
+		},
+		{
+			name:    "notification",
+			message: map[string]any{"jsonrpc": "2.0", "method": "session/update"},
+		},
+		{
+			name:    "response",
+			message: map[string]any{"jsonrpc": "2.0", "id": 1.0, "result": map[string]any{}},
+		},
+		{
+			name:    "missing jsonrpc",
+			message: map[string]any{"id": 1.0, "result": map[string]any{}},
+			wantErr: true,
+		},
+		{
+			name:    "wrong version",
+			message: map[string]any{"jsonrpc": "1.0", "method": "session/update"},
+			wantErr: true,
+		},
+		{
+			name:    "method with result",
+			message: map[string]any{"jsonrpc": "2.0", "id": 1.0, "method": "x", "result": map[string]any{}},
+			wantErr: true,
+		},
+		{
+			name:    "id without result or error",
+			message: map[string]any{"jsonrpc": "2.0", "id": 1.0},
+			wantErr: true,
+		},
+		{
+			name:    "neither id nor method",
+			message: map[string]any{"jsonrpc": "2.0", "result": map[string]any{}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEnvelope(messageFrom(t, tt.message))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEnvelope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
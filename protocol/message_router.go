@@ -5,79 +5,128 @@ import (
 	"fmt"
 )
 
-// Handler interface for handling different types of protocol messages
-type Handler interface {
-	HandlePermissionRequest(
-		acpConn *AcpConnection,
-		raw []byte,
-		req SessionRequestPermissionRequest,
-	) error
-	HandleNotification(raw []byte, req SessionUpdateRequest) error
+// TurnResult reports the end of a prompt turn: the stop reason from the
+// session/prompt response, or the error the agent replied with instead
+type TurnResult struct {
+	StopReason string
+	Err        error
 }
 
-// StreamResponses processes incoming messages and routes them to appropriate handlers
-func (acpConn *AcpConnection) StreamResponses(handlers Handler, ch chan int) error {
-	decoder := json.NewDecoder(acpConn.reader)
+// StreamResponses reads incoming messages and dispatches them to the handlers
+// registered on the connection, sending the result of each prompt turn to
+// turns. Reading continues while handlers run, and the first handler error
+// ends the stream.
+func (acpConn *AcpConnection) StreamResponses(turns chan TurnResult) error {
+	dispatcher := newDispatcher(acpConn, turns, acpConn.dispatchQueue)
+	defer dispatcher.close()
+
+	done := make(chan struct{})
+	defer close(done)
+	reads := acpConn.readMessages(done)
 
 	for {
-		response := map[string]any{}
-		if err := decoder.Decode(&response); err != nil {
-			return fmt.Errorf("JSON decode error in StreamResponses: %v", err)
+		var read readResult
+		select {
+		case read = <-reads:
+		case err := <-dispatcher.errs:
+			return err
 		}
 
-		if acpConn.recorder != nil {
-			if err := acpConn.recorder.RecordMessage(response); err != nil {
-				return fmt.Errorf("failed to record message: %v", err)
-			}
+		if read.err != nil {
+			return fmt.Errorf("read error in StreamResponses: %w", read.err)
 		}
 
-		if err := RouteMessage(handlers, ch, acpConn, response); err != nil {
+		if err := dispatcher.dispatch(read.message); err != nil {
 			return err
 		}
 	}
 }
 
-// RouteMessage routes a single message to the appropriate handler based on method
+type readResult struct {
+	message *Message
+	err     error
+}
+
+// readMessages reads from the agent on its own goroutine until a read fails
+// or done is closed
+func (acpConn *AcpConnection) readMessages(done <-chan struct{}) <-chan readResult {
+	reads := make(chan readResult)
+
+	go func() {
+		for {
+			message, err := acpConn.readMessage()
+			select {
+			case reads <- readResult{message: message, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return reads
+}
+
+// RouteMessage routes a single message to the registered handler for its
+// method. The response to a prompt ends its turn and is sent to turns.
 func RouteMessage(
-	handlers Handler,
-	ch chan int,
+	turns chan TurnResult,
 	acpConn *AcpConnection,
-	response map[string]any,
+	msg *Message,
 ) error {
-	method, ok := response["method"].(string)
-	if !ok || method == "" {
-		ch <- 0
+	if msg.IsResponse() {
+		switch {
+		case acpConn.requests.endPrompt(msg):
+			turns <- turnResultOf(msg)
+		case !acpConn.requests.deliver(msg):
+			acpConn.logf("ignoring response to unknown request %s", msg.ID)
+		}
 		return nil
 	}
 
-	jsonData, err := json.Marshal(response)
-	if err != nil {
-		return err
+	if handler, ok := acpConn.Registry().Lookup(msg.Method); ok {
+		return handler(acpConn, msg)
 	}
 
-	switch method {
-	case "session/update":
-		var req SessionUpdateRequest
-		if err := json.Unmarshal(jsonData, &req); err != nil {
-			return err
-		}
-		if err := handlers.HandleNotification(jsonData, req); err != nil {
-			return err
-		}
-	case "session/request_permission":
-		var req SessionRequestPermissionRequest
-		if err := json.Unmarshal(jsonData, &req); err != nil {
-			return err
-		}
-		if err := handlers.HandlePermissionRequest(acpConn, jsonData, req); err != nil {
-			return err
-		}
-	default:
-		fmt.Println()
-		fmt.Println("======UNKNOWN=======")
-		fmt.Println(string(jsonData))
-		fmt.Println("=====================")
+	return routeUnknownMethod(acpConn, msg)
+}
+
+// routeUnknownMethod replies to unhandled requests with "method not found"
+// and logs unhandled notifications
+func routeUnknownMethod(acpConn *AcpConnection, msg *Message) error {
+	if !msg.IsRequest() {
+		acpConn.logf("ignoring unsupported notification %q", msg.Method)
+		return nil
 	}
 
-	return nil
+	acpConn.logf("rejecting unsupported request %q", msg.Method)
+	return acpConn.SendError(msg.ID, ErrorCodeMethodNotFound, fmt.Sprintf("Method not found: %s", msg.Method))
+}
+
+// turnResultOf decodes the response to a session/prompt request
+func turnResultOf(msg *Message) TurnResult {
+	if err := responseError(msg); err != nil {
+		return TurnResult{Err: err}
+	}
+
+	var result ResponseResult
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return TurnResult{Err: fmt.Errorf("invalid session/prompt result %s: %v", msg.Result, err)}
+	}
+	return TurnResult{StopReason: result.StopReason}
+}
+
+// responseError returns the error a response carries, or nil for a result
+func responseError(msg *Message) error {
+	if len(msg.Error) == 0 {
+		return nil
+	}
+
+	var responseError ResponseError
+	if err := json.Unmarshal(msg.Error, &responseError); err != nil {
+		return fmt.Errorf("agent returned an error: %s", msg.Error)
+	}
+	return fmt.Errorf("agent returned an error: %s (code %d)", responseError.Message, responseError.Code)
 }
@@ -0,0 +1,127 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// inboundStream returns the agent's side of a recording in testdata, as
+// the agent wrote it
+func inboundStream(b *testing.B, name string) (stream []byte, handled int) {
+	b.Helper()
+
+	conversation, err := ReadRecording(filepath.Join("testdata", name))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, message := range conversation.Messages {
+		if !message.IsInbound() {
+			continue
+		}
+		buf.Write(message.Data)
+		buf.WriteByte('\n')
+		if bytes.Contains(message.Data, []byte(`"method"`)) {
+			handled++
+		}
+	}
+	return buf.Bytes(), handled
+}
+
+// BenchmarkRouteStream reads a recorded session and dispatches it to typed
+// handlers, as StreamResponses does for a live agent
+func BenchmarkRouteStream(b *testing.B) {
+	stream, handled := inboundStream(b, "session.jsonl")
+
+	var wg sync.WaitGroup
+	var chunks atomic.Int64
+	registry := NewRegistry()
+	OnNotification(registry, MethodSessionUpdate, func(raw []byte, req SessionUpdateRequest) error {
+		if req.Params.Update.Content != nil {
+			chunks.Add(int64(len(req.Params.Update.Content.Text)))
+		}
+		wg.Done()
+		return nil
+	})
+	OnRequest(registry, MethodSessionRequestPermission, func(acpConn *AcpConnection, raw []byte, req SessionRequestPermissionRequest) error {
+		chunks.Add(int64(len(req.Params.ToolCall.RawInput)))
+		wg.Done()
+		return nil
+	})
+
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		conn := &AcpConnection{reader: bytes.NewReader(stream), writer: io.Discard, registry: registry, sessionID: "bench-session"}
+		turns := make(chan TurnResult, 16)
+
+		wg.Add(handled)
+		if err := conn.StreamResponses(turns); !errors.Is(err, io.EOF) {
+			b.Fatal(err)
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkRouteStreamLegacy reproduces the previous routing path for
+// comparison: decode to a map, re-marshal, decode into the request struct,
+// then decode into maps again for display parsing and tool detection
+func BenchmarkRouteStreamLegacy(b *testing.B) {
+	stream, _ := inboundStream(b, "session.jsonl")
+
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		decoder := json.NewDecoder(bytes.NewReader(stream))
+		for {
+			response := map[string]any{}
+			if err := decoder.Decode(&response); err != nil {
+				break
+			}
+
+			method, _ := response["method"].(string)
+			if method == "" {
+				continue
+			}
+
+			jsonData, err := json.Marshal(response)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			switch method {
+			case MethodSessionUpdate:
+				var req SessionUpdateRequest
+				if err := json.Unmarshal(jsonData, &req); err != nil {
+					b.Fatal(err)
+				}
+				var notification map[string]any
+				if err := json.Unmarshal(jsonData, &notification); err != nil {
+					b.Fatal(err)
+				}
+			case MethodSessionRequestPermission:
+				var req SessionRequestPermissionRequest
+				if err := json.Unmarshal(jsonData, &req); err != nil {
+					b.Fatal(err)
+				}
+				for pass := 0; pass < 2; pass++ {
+					var toolCall map[string]any
+					if err := json.Unmarshal(jsonData, &toolCall); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,9 +1,9 @@
 package protocol
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
-	"time"
 )
 
 func TestReplayConnection(t *testing.T) {
@@ -11,25 +11,9 @@ func TestReplayConnection(t *testing.T) {
 	recordingFile := "test_recording.jsonl"
 	defer os.Remove(recordingFile)
 
-	testMessages := []ConversationMessage{
-		{
-			Timestamp: time.Now(),
-			Data: map[string]any{
-				"jsonrpc": "2.0",
-				"result": map[string]any{
-					"sessionId": "test123",
-				},
-			},
-		},
-		{
-			Timestamp: time.Now(),
-			Data: map[string]any{
-				"method": "session/update",
-				"params": map[string]any{
-					"content": "test message",
-				},
-			},
-		},
+	testMessages := []string{
+		`{"jsonrpc":"2.0","id":0,"result":{"sessionId":"test123"}}`,
+		`{"jsonrpc":"2.0","method":"session/update","params":{"content":"test message"}}`,
 	}
 
 	recorder, err := NewFileRecorder(recordingFile)
@@ -38,7 +22,7 @@ func TestReplayConnection(t *testing.T) {
 	}
 
 	for _, msg := range testMessages {
-		if err := recorder.RecordMessage(msg.Data); err != nil {
+		if err := recorder.RecordMessage(json.RawMessage(msg)); err != nil {
 			t.Fatalf("Failed to record message: %v", err)
 		}
 	}
@@ -47,7 +31,7 @@ func TestReplayConnection(t *testing.T) {
 		t.Fatalf("Failed to close recorder: %v", err)
 	}
 
-	conn, err := OpenAcpReplayConnection(recordingFile)
+	conn, err := OpenAcpReplayConnection(recordingFile, RealTimeReplay)
 	if err != nil {
 		t.Fatalf("Failed to create replay connection: %v", err)
 	}
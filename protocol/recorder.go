@@ -1,36 +1,75 @@
 package protocol
 
 import (
+	"bufio"
 	"encoding/json"
 	"os"
 	"sync"
 	"time"
+
+	"agentgo/internal/version"
+)
+
+// RecordingFormatVersion is the version of the recording file format.
+// Version 1 files have no header and contain inbound messages only.
+const RecordingFormatVersion = 2
+
+// Message directions in a recording
+const (
+	DirectionInbound  = "in"
+	DirectionOutbound = "out"
 )
 
+// RecordingHeader is the first line of a recording file
+type RecordingHeader struct {
+	Type           string    `json:"type"`
+	FormatVersion  int       `json:"format_version"`
+	AgentgoVersion string    `json:"agentgo_version"`
+	AgentCommand   []string  `json:"agent_command,omitempty"`
+	Cwd            string    `json:"cwd,omitempty"`
+	SessionID      string    `json:"session_id,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+}
+
 // ConversationMessage represents a single message in a recorded conversation
 type ConversationMessage struct {
-	Timestamp time.Time              `json:"timestamp"`
-	Data      map[string]interface{} `json:"data"`
+	Timestamp time.Time       `json:"timestamp"`
+	Direction string          `json:"direction,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// IsInbound returns true for messages sent by the agent. Messages from
+// version 1 recordings have no direction and are always inbound.
+func (m *ConversationMessage) IsInbound() bool {
+	return m.Direction != DirectionOutbound
 }
 
 // RecordedConversation represents a complete recorded conversation
 type RecordedConversation struct {
+	Header    *RecordingHeader      `json:"header,omitempty"`
 	SessionID string                `json:"session_id,omitempty"`
 	Messages  []ConversationMessage `json:"messages"`
 }
 
 // ConversationRecorder interface for recording conversations
 type ConversationRecorder interface {
-	RecordMessage(data map[string]interface{}) error
+	RecordMessage(data json.RawMessage) error
+	RecordOutbound(data json.RawMessage) error
+	SetSessionID(sessionID string) error
 	Close() error
 }
 
-// FileRecorder implements ConversationRecorder by writing to a JSON file
+// FileRecorder implements ConversationRecorder by writing to a JSON file.
+// Messages are held back until the session ID is known so the header can
+// be written first.
 type FileRecorder struct {
 	filePath string
 	file     *os.File
 	encoder  *json.Encoder
 	mutex    sync.Mutex
+	header   RecordingHeader
+	pending  []ConversationMessage
+	started  bool
 }
 
 // NewFileRecorder creates a new file recorder
@@ -39,35 +78,161 @@ func NewFileRecorder(filePath string) (*FileRecorder, error) {
 	if err != nil {
 		return nil, err
 	}
-	
+
+	cwd, _ := os.Getwd()
+
 	return &FileRecorder{
 		filePath: filePath,
 		file:     file,
 		encoder:  json.NewEncoder(file),
+		header: RecordingHeader{
+			Type:           "header",
+			FormatVersion:  RecordingFormatVersion,
+			AgentgoVersion: version.Version,
+			Cwd:            cwd,
+			StartedAt:      time.Now(),
+		},
 	}, nil
 }
 
-// RecordMessage records a message to the conversation
-func (f *FileRecorder) RecordMessage(data map[string]interface{}) error {
+// RecordMessage records a message received from the agent
+func (f *FileRecorder) RecordMessage(data json.RawMessage) error {
+	return f.record(DirectionInbound, data)
+}
+
+// RecordOutbound records a message sent to the agent
+func (f *FileRecorder) RecordOutbound(data json.RawMessage) error {
+	return f.record(DirectionOutbound, data)
+}
+
+// SetSessionID completes the header and writes it with any held back messages
+func (f *FileRecorder) SetSessionID(sessionID string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.header.SessionID = sessionID
+	return f.start()
+}
+
+func (f *FileRecorder) record(direction string, data json.RawMessage) error {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
 	message := ConversationMessage{
 		Timestamp: time.Now(),
+		Direction: direction,
 		Data:      data,
 	}
 
+	if !f.started {
+		f.pending = append(f.pending, message)
+		return nil
+	}
+
 	// Write each message as a separate JSON line
 	return f.encoder.Encode(message)
 }
 
+// start writes the header and pending messages; the caller holds the mutex
+func (f *FileRecorder) start() error {
+	if f.started {
+		return nil
+	}
+	f.started = true
+
+	if err := f.encoder.Encode(f.header); err != nil {
+		return err
+	}
+	for _, message := range f.pending {
+		if err := f.encoder.Encode(message); err != nil {
+			return err
+		}
+	}
+	f.pending = nil
+	return nil
+}
+
 // Close closes the file and flushes any remaining data
 func (f *FileRecorder) Close() error {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
-	if f.file != nil {
-		return f.file.Close()
+	if f.file == nil {
+		return nil
 	}
-	return nil
+
+	err := f.start()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+	return err
+}
+
+// ReadRecording loads a recording file. Version 1 files without a header
+// are accepted, with every message treated as inbound.
+func ReadRecording(recordingFile string) (*RecordedConversation, error) {
+	file, err := os.Open(recordingFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	conversation := &RecordedConversation{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), DefaultMaxMessageSize)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry struct {
+			Type string `json:"type"`
+			ConversationMessage
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+
+		if entry.Type == "header" && conversation.Header == nil {
+			header := &RecordingHeader{}
+			if err := json.Unmarshal(line, header); err != nil {
+				continue
+			}
+			conversation.Header = header
+			conversation.SessionID = header.SessionID
+			continue
+		}
+
+		if len(entry.Data) == 0 {
+			continue
+		}
+		conversation.Messages = append(conversation.Messages, entry.ConversationMessage)
+	}
+
+	return conversation, scanner.Err()
+}
+
+// ReadRecordingHeader reads the header of a recording without loading its
+// messages. Version 1 files have none, and give nil.
+func ReadRecordingHeader(recordingFile string) (*RecordingHeader, error) {
+	file, err := os.Open(recordingFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), DefaultMaxMessageSize)
+	if !scanner.Scan() {
+		return nil, scanner.Err()
+	}
+
+	header := &RecordingHeader{}
+	if err := json.Unmarshal(scanner.Bytes(), header); err != nil || header.Type != "header" {
+		return nil, nil
+	}
+	return header, nil
 }
@@ -0,0 +1,124 @@
+package protocol
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileRecorder_BothDirectionsWithHeader(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "session.jsonl")
+
+	recorder, err := NewFileRecorder(recordingFile)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	recorder.header.AgentCommand = []string{"claude-code-acp"}
+
+	steps := []func() error{
+		func() error {
+			return recorder.RecordOutbound(json.RawMessage(`{"jsonrpc":"2.0","id":0,"method":"session/new"}`))
+		},
+		func() error {
+			return recorder.RecordMessage(json.RawMessage(`{"jsonrpc":"2.0","id":0,"result":{"sessionId":"abc"}}`))
+		},
+		func() error { return recorder.SetSessionID("abc") },
+		func() error {
+			return recorder.RecordOutbound(json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"session/prompt"}`))
+		},
+		recorder.Close,
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("Step %d failed: %v", i, err)
+		}
+	}
+
+	conversation, err := ReadRecording(recordingFile)
+	if err != nil {
+		t.Fatalf("ReadRecording() error: %v", err)
+	}
+
+	if conversation.Header == nil {
+		t.Fatal("Expected a header")
+	}
+	if conversation.Header.FormatVersion != RecordingFormatVersion {
+		t.Errorf("Expected format version %d, got %d", RecordingFormatVersion, conversation.Header.FormatVersion)
+	}
+	if conversation.SessionID != "abc" || conversation.Header.SessionID != "abc" {
+		t.Errorf("Expected session id abc, got %q", conversation.SessionID)
+	}
+	if len(conversation.Header.AgentCommand) != 1 || conversation.Header.AgentCommand[0] != "claude-code-acp" {
+		t.Errorf("Unexpected agent command %v", conversation.Header.AgentCommand)
+	}
+
+	directions := []string{DirectionOutbound, DirectionInbound, DirectionOutbound}
+	if len(conversation.Messages) != len(directions) {
+		t.Fatalf("Expected %d messages, got %d", len(directions), len(conversation.Messages))
+	}
+	for i, direction := range directions {
+		if conversation.Messages[i].Direction != direction {
+			t.Errorf("Message %d: expected direction %q, got %q", i, direction, conversation.Messages[i].Direction)
+		}
+	}
+}
+
+func TestReadRecordingHeader(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current.jsonl")
+	old := filepath.Join(dir, "old.jsonl")
+	os.WriteFile(current, []byte(`{"type":"header","format_version":2,"session_id":"abc","cwd":"/src"}`+"\n"+`{"direction":"in","data":{}}`+"\n"), 0o644)
+	os.WriteFile(old, []byte(`{"timestamp":"2025-01-01T00:00:00Z","data":{}}`+"\n"), 0o644)
+
+	header, err := ReadRecordingHeader(current)
+	if err != nil || header == nil || header.SessionID != "abc" || header.Cwd != "/src" {
+		t.Errorf("Expected the header, got %+v, %v", header, err)
+	}
+	if header, err := ReadRecordingHeader(old); err != nil || header != nil {
+		t.Errorf("Expected no header for a version 1 file, got %+v, %v", header, err)
+	}
+}
+
+func TestReplayIOProvider_SkipsOutboundAndReadsOldFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	current := filepath.Join(dir, "current.jsonl")
+	writeFile(t, current,
+		`{"type":"header","format_version":2,"agentgo_version":"test","session_id":"abc","started_at":"2025-01-01T00:00:00Z"}`,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"out","data":{"jsonrpc":"2.0","id":1,"method":"session/prompt"}}`,
+		`{"timestamp":"2025-01-01T00:00:01Z","direction":"in","data":{"jsonrpc":"2.0","id":1,"result":{}}}`,
+	)
+
+	legacy := filepath.Join(dir, "legacy.jsonl")
+	writeFile(t, legacy,
+		`{"timestamp":"2025-01-01T00:00:01Z","data":{"jsonrpc":"2.0","id":1,"result":{}}}`,
+	)
+
+	for _, file := range []string{current, legacy} {
+		provider, err := NewReplayIOProvider(file, ReplayOptions{})
+		if err != nil {
+			t.Fatalf("NewReplayIOProvider(%s) error: %v", file, err)
+		}
+
+		data, err := io.ReadAll(provider.GetReader())
+		if err != nil {
+			t.Fatalf("Failed to read replay: %v", err)
+		}
+
+		expected := `{"jsonrpc":"2.0","id":1,"result":{}}` + "\n"
+		if string(data) != expected {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(file), expected, data)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,192 @@
+package protocol
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Message is a single JSON-RPC message from the agent, decoded once from the
+// wire. ID, params, result and error stay raw so handlers can decode them
+// straight into typed structs.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+
+	// Raw is the complete message as received
+	Raw json.RawMessage `json:"-"`
+
+	// SessionID is the session the message belongs to, known once a typed
+	// handler's decoder has read its params
+	SessionID string `json:"-"`
+
+	// decoded holds the typed message once its params are decoded
+	decoded any
+}
+
+// IsRequest returns true if the agent expects a reply to this message
+func (m *Message) IsRequest() bool {
+	return m.Method != "" && len(m.ID) > 0
+}
+
+// IsResponse returns true if the message answers one of our requests
+func (m *Message) IsResponse() bool {
+	return m.Method == ""
+}
+
+// HandlerFunc handles a single agent message. Handlers for requests are
+// responsible for replying to the agent.
+type HandlerFunc func(acpConn *AcpConnection, msg *Message) error
+
+// Middleware wraps every registered handler, e.g. for logging, metrics or policy
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Registry maps agent method names to handlers
+type Registry struct {
+	mutex      sync.RWMutex
+	handlers   map[string]HandlerFunc
+	decoders   map[string]func(msg *Message) error
+	middleware []Middleware
+}
+
+// NewRegistry creates an empty method registry
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]HandlerFunc),
+		decoders: make(map[string]func(msg *Message) error),
+	}
+}
+
+// Handle registers a handler for a method, replacing any existing one
+func (r *Registry) Handle(method string, handler HandlerFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handlers[method] = handler
+	delete(r.decoders, method)
+}
+
+// handleTyped registers a handler along with the decoder of its typed message
+func (r *Registry) handleTyped(method string, decode func(msg *Message) error, handler HandlerFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handlers[method] = handler
+	r.decoders[method] = decode
+}
+
+// decode decodes a message into the type its handler takes, setting its
+// session ID. Messages without a typed handler are left alone, and decoding
+// errors are left for the handler to report.
+func (r *Registry) decode(msg *Message) {
+	r.mutex.RLock()
+	decode, ok := r.decoders[msg.Method]
+	r.mutex.RUnlock()
+
+	if ok {
+		_ = decode(msg)
+	}
+}
+
+// Use appends middleware. The first middleware added is the outermost.
+func (r *Registry) Use(middleware ...Middleware) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Lookup returns the handler for a method wrapped in all middleware
+func (r *Registry) Lookup(method string) (HandlerFunc, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	handler, ok := r.handlers[method]
+	if !ok {
+		return nil, false
+	}
+
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	return handler, true
+}
+
+// typedMessage is implemented by the typed agent messages handlers take.
+// fromMessage fills one in from the envelope, decoding only its params.
+type typedMessage[T any] interface {
+	*T
+	fromMessage(msg *Message) error
+	sessionID() string
+}
+
+// decodeMessage returns a message as T, decoding its params the first time
+func decodeMessage[T any, PT typedMessage[T]](msg *Message) (T, error) {
+	if req, ok := msg.decoded.(T); ok {
+		return req, nil
+	}
+
+	var req T
+	if err := PT(&req).fromMessage(msg); err != nil {
+		return req, err
+	}
+	msg.decoded = req
+	msg.SessionID = PT(&req).sessionID()
+	return req, nil
+}
+
+// OnRequest registers a typed handler for an agent request
+func OnRequest[T any, PT typedMessage[T]](
+	r *Registry,
+	method string,
+	handler func(acpConn *AcpConnection, raw []byte, req T) error,
+) {
+	r.handleTyped(method, decodeOnly[T, PT], func(acpConn *AcpConnection, msg *Message) error {
+		req, err := decodeMessage[T, PT](msg)
+		if err != nil {
+			return err
+		}
+		return handler(acpConn, msg.Raw, req)
+	})
+}
+
+// OnNotification registers a typed handler for an agent notification
+func OnNotification[T any, PT typedMessage[T]](
+	r *Registry,
+	method string,
+	handler func(raw []byte, req T) error,
+) {
+	r.handleTyped(method, decodeOnly[T, PT], func(acpConn *AcpConnection, msg *Message) error {
+		req, err := decodeMessage[T, PT](msg)
+		if err != nil {
+			return err
+		}
+		return handler(msg.Raw, req)
+	})
+}
+
+func decodeOnly[T any, PT typedMessage[T]](msg *Message) error {
+	_, err := decodeMessage[T, PT](msg)
+	return err
+}
+
+// LoggingMiddleware logs each handled message with its duration and outcome
+func LoggingMiddleware(logger *log.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(acpConn *AcpConnection, msg *Message) error {
+			start := time.Now()
+			err := next(acpConn, msg)
+			if err != nil {
+				logger.Printf("%s failed after %v: %v", msg.Method, time.Since(start), err)
+			} else {
+				logger.Printf("%s handled in %v", msg.Method, time.Since(start))
+			}
+			return err
+		}
+	}
+}
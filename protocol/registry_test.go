@@ -0,0 +1,132 @@
+package protocol
+
+import (
+	"testing"
+)
+
+func TestRegistry_TypedHandlers(t *testing.T) {
+	conn := &AcpConnection{}
+	registry := conn.Registry()
+
+	var gotUpdate SessionUpdateRequest
+	OnNotification(registry, MethodSessionUpdate, func(raw []byte, req SessionUpdateRequest) error {
+		gotUpdate = req
+		return nil
+	})
+
+	var gotPermission SessionRequestPermissionRequest
+	OnRequest(registry, MethodSessionRequestPermission, func(acpConn *AcpConnection, raw []byte, req SessionRequestPermissionRequest) error {
+		gotPermission = req
+		return nil
+	})
+
+	ch := make(chan TurnResult, 1)
+
+	update := map[string]any{
+		"jsonrpc": "2.0",
+		"method":  MethodSessionUpdate,
+		"params": map[string]any{
+			"sessionId": "abc",
+			"update":    map[string]any{"sessionUpdate": "agent_message_chunk"},
+		},
+	}
+	if err := RouteMessage(ch, conn, messageFrom(t, update)); err != nil {
+		t.Fatalf("RouteMessage returned error: %v", err)
+	}
+	if gotUpdate.Params.SessionID != "abc" {
+		t.Errorf("Expected session id abc, got %q", gotUpdate.Params.SessionID)
+	}
+
+	permission := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      3.0,
+		"method":  MethodSessionRequestPermission,
+		"params": map[string]any{
+			"toolCall": map[string]any{"toolCallId": "tool-1"},
+		},
+	}
+	if err := RouteMessage(ch, conn, messageFrom(t, permission)); err != nil {
+		t.Fatalf("RouteMessage returned error: %v", err)
+	}
+	if gotPermission.ID != 3 || gotPermission.Params.ToolCall.ToolCallID != "tool-1" {
+		t.Errorf("Unexpected permission request: %+v", gotPermission)
+	}
+}
+
+func TestRegistry_DecodesParamsOnce(t *testing.T) {
+	conn := &AcpConnection{sessionID: "own"}
+	var got []string
+	OnNotification(conn.Registry(), MethodSessionUpdate, func(raw []byte, req SessionUpdateRequest) error {
+		got = append(got, req.Params.SessionID+" "+req.Params.Update.SessionUpdateType)
+		return nil
+	})
+
+	// Only the params are decoded, never the whole message again
+	msg := &Message{
+		JSONRPC: "2.0",
+		Method:  MethodSessionUpdate,
+		Params:  []byte(`{"sessionId":"s2","update":{"sessionUpdate":"plan"}}`),
+		Raw:     []byte("not json"),
+	}
+	d := newDispatcher(conn, make(chan TurnResult), 0)
+	if session := d.sessionOf(msg); session != "s2" {
+		t.Errorf("Expected session s2, got %q", session)
+	}
+
+	// The handler gets the message decoded for the dispatcher
+	msg.Params = []byte("not json either")
+	if err := RouteMessage(nil, conn, msg); err != nil {
+		t.Fatalf("RouteMessage returned error: %v", err)
+	}
+	if len(got) != 1 || got[0] != "s2 plan" {
+		t.Errorf("Unexpected updates %q", got)
+	}
+
+	// Messages no typed handler takes are ordered with the connection's session
+	if session := d.sessionOf(&Message{Method: "_test/other", Params: []byte(`{"sessionId":"s3"}`)}); session != "own" {
+		t.Errorf("Expected the connection's session, got %q", session)
+	}
+}
+
+func TestRegistry_MiddlewareOrder(t *testing.T) {
+	registry := NewRegistry()
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(acpConn *AcpConnection, msg *Message) error {
+				calls = append(calls, name)
+				return next(acpConn, msg)
+			}
+		}
+	}
+
+	registry.Use(trace("outer"), trace("inner"))
+	registry.Handle("_test/method", func(acpConn *AcpConnection, msg *Message) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	handler, ok := registry.Lookup("_test/method")
+	if !ok {
+		t.Fatal("Expected handler to be registered")
+	}
+	if err := handler(nil, &Message{Method: "_test/method"}); err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+
+	expected := []string{"outer", "inner", "handler"}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Expected calls %v, got %v", expected, calls)
+			break
+		}
+	}
+
+	if _, ok := registry.Lookup("_test/missing"); ok {
+		t.Error("Expected lookup of unregistered method to fail")
+	}
+}
@@ -0,0 +1,201 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayOptions controls how fast a recording is played back
+type ReplayOptions struct {
+	// Speed multiplies playback speed; 2 plays twice as fast. Zero or less
+	// replays as fast as possible.
+	Speed float64
+	// MaxGap caps the recorded pause before any single message. Zero means
+	// no cap.
+	MaxGap time.Duration
+	// Strict checks every message we send against the recorded outgoing
+	// messages and fails the write on a mismatch
+	Strict bool
+}
+
+// RealTimeReplay plays a recording back with its original timing
+var RealTimeReplay = ReplayOptions{Speed: 1}
+
+// ParseReplaySpeed parses a playback speed such as "0.5", "2x" or "max"
+func ParseReplaySpeed(value string) (float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "max" {
+		return 0, nil
+	}
+	value = strings.TrimSuffix(value, "x")
+
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid replay speed %q: use a positive number or \"max\"", value)
+	}
+	return speed, nil
+}
+
+// delay returns how long to wait before a message recorded gap after the previous one
+func (o ReplayOptions) delay(gap time.Duration) time.Duration {
+	if o.Speed <= 0 || gap <= 0 {
+		return 0
+	}
+	if o.MaxGap > 0 && gap > o.MaxGap {
+		gap = o.MaxGap
+	}
+	return time.Duration(float64(gap) / o.Speed)
+}
+
+// ReplayIOProvider streams recorded messages for testing
+type ReplayIOProvider struct {
+	reader *pacedReader
+	writer io.Writer
+	// initializes is set when the recording starts with our initialize
+	// request; older recordings start with session/new
+	initializes bool
+	// requestIDs lists the IDs of our recorded requests by method
+	requestIDs map[string][]int
+}
+
+// NewReplayIOProvider creates a replay provider from a JSONL recording file.
+// Only messages the agent sent are replayed; our own recorded messages are
+// skipped, but the time around them still counts towards the pause before
+// the next agent message.
+func NewReplayIOProvider(recordingFile string, options ReplayOptions) (*ReplayIOProvider, error) {
+	conversation, err := ReadRecording(recordingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []replayFrame
+	var outbound []json.RawMessage
+	var previous time.Time
+	// gap is the time since the last agent message, including the time
+	// before and after our own messages in between
+	var gap time.Duration
+	for _, message := range conversation.Messages {
+		if !previous.IsZero() && !message.Timestamp.IsZero() {
+			gap += message.Timestamp.Sub(previous)
+		}
+		if !message.Timestamp.IsZero() {
+			previous = message.Timestamp
+		}
+
+		if !message.IsInbound() {
+			outbound = append(outbound, message.Data)
+			continue
+		}
+
+		data := make([]byte, 0, len(message.Data)+1)
+		data = append(append(data, message.Data...), '\n')
+		frames = append(frames, replayFrame{data: data, delay: options.delay(gap)})
+		gap = 0
+	}
+
+	var writer io.Writer = &noopWriter{}
+	if options.Strict {
+		writer = newStrictReplayWriter(outbound)
+	}
+
+	initializes := false
+	requestIDs := make(map[string][]int)
+	for i, data := range outbound {
+		var message Message
+		if json.Unmarshal(data, &message) != nil {
+			continue
+		}
+		if i == 0 {
+			initializes = message.Method == MethodInitialize
+		}
+		var id int
+		if message.Method != "" && json.Unmarshal(message.ID, &id) == nil {
+			requestIDs[message.Method] = append(requestIDs[message.Method], id)
+		}
+	}
+
+	return &ReplayIOProvider{
+		reader:      newPacedReader(frames),
+		writer:      writer,
+		initializes: initializes,
+		requestIDs:  requestIDs,
+	}, nil
+}
+
+// Start is a no-op for replay
+func (r *ReplayIOProvider) Start() error {
+	return nil
+}
+
+// GetReader returns the message stream
+func (r *ReplayIOProvider) GetReader() io.Reader {
+	return r.reader
+}
+
+// GetWriter discards what we send, or checks it against the recording in strict mode
+func (r *ReplayIOProvider) GetWriter() io.Writer {
+	return r.writer
+}
+
+// Close stops playback; pending reads return io.EOF
+func (r *ReplayIOProvider) Close() error {
+	r.reader.close()
+	return nil
+}
+
+type replayFrame struct {
+	data  []byte
+	delay time.Duration
+}
+
+// pacedReader releases each recorded message after its delay
+type pacedReader struct {
+	frames    []replayFrame
+	current   []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPacedReader(frames []replayFrame) *pacedReader {
+	return &pacedReader{
+		frames: frames,
+		closed: make(chan struct{}),
+	}
+}
+
+func (p *pacedReader) Read(buf []byte) (int, error) {
+	for len(p.current) == 0 {
+		if len(p.frames) == 0 {
+			return 0, io.EOF
+		}
+
+		frame := p.frames[0]
+		p.frames = p.frames[1:]
+
+		if frame.delay > 0 {
+			timer := time.NewTimer(frame.delay)
+			select {
+			case <-timer.C:
+			case <-p.closed:
+				timer.Stop()
+				return 0, io.EOF
+			}
+		}
+		p.current = frame.data
+	}
+
+	n := copy(buf, p.current)
+	p.current = p.current[n:]
+	return n, nil
+}
+
+func (p *pacedReader) close() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+	})
+}
@@ -0,0 +1,138 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ReplayStepper hands control of a replay to the caller: recorded agent
+// messages are only routed to the handlers when the caller steps to them.
+type ReplayStepper struct {
+	acpConn  *AcpConnection
+	messages []*Message
+	position int
+}
+
+// NewReplayStepper reads every remaining agent message from a replay
+// connection so the caller can step and seek through them
+func NewReplayStepper(acpConn *AcpConnection) (*ReplayStepper, error) {
+	var messages []*Message
+	for {
+		message, err := acpConn.readMessage()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return &ReplayStepper{
+		acpConn:  acpConn,
+		messages: messages,
+	}, nil
+}
+
+// Len returns the number of recorded agent messages
+func (s *ReplayStepper) Len() int {
+	return len(s.messages)
+}
+
+// Position returns the index of the next message to be delivered
+func (s *ReplayStepper) Position() int {
+	return s.position
+}
+
+// Done returns true when every message has been delivered or skipped
+func (s *ReplayStepper) Done() bool {
+	return s.position >= len(s.messages)
+}
+
+// Current returns the most recently delivered message, or nil before the first step
+func (s *ReplayStepper) Current() *Message {
+	if s.position == 0 {
+		return nil
+	}
+	return s.messages[s.position-1]
+}
+
+// Next returns the message the next step delivers, or nil when done
+func (s *ReplayStepper) Next() *Message {
+	if s.Done() {
+		return nil
+	}
+	return s.messages[s.position]
+}
+
+// Step routes the next message to the registered handlers and returns it
+func (s *ReplayStepper) Step(turns chan TurnResult) (*Message, error) {
+	if s.Done() {
+		return nil, io.EOF
+	}
+
+	message := s.messages[s.position]
+	s.position++
+	return message, RouteMessage(turns, s.acpConn, message)
+}
+
+// Seek moves so the message at index is delivered next. Skipped messages
+// are not routed.
+func (s *ReplayStepper) Seek(index int) error {
+	if index < 0 || index > len(s.messages) {
+		return fmt.Errorf("message %d out of range (0-%d)", index, len(s.messages))
+	}
+	s.position = index
+	return nil
+}
+
+// SeekNext moves to the next message that matches, so it is delivered on the
+// following step. When the message it is on already matches, it moves past
+// it to the one after, so repeated seeks visit each match in turn. It returns
+// false and stays put if nothing matches.
+func (s *ReplayStepper) SeekNext(match func(*Message) bool) bool {
+	start := s.position
+	if start < len(s.messages) && match(s.messages[start]) {
+		start++
+	}
+	for i := start; i < len(s.messages); i++ {
+		if match(s.messages[i]) {
+			s.position = i
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateType returns the sessionUpdate kind of a session/update notification
+func (m *Message) UpdateType() string {
+	if m.Method != MethodSessionUpdate {
+		return ""
+	}
+
+	var params struct {
+		Update struct {
+			SessionUpdate string `json:"sessionUpdate"`
+		} `json:"update"`
+	}
+	if err := json.Unmarshal(m.Params, &params); err != nil {
+		return ""
+	}
+	return params.Update.SessionUpdate
+}
+
+// IsToolCall matches session updates that start or update a tool call
+func IsToolCall(m *Message) bool {
+	switch m.UpdateType() {
+	case "tool_call", "tool_call_update":
+		return true
+	}
+	return false
+}
+
+// IsPermissionRequest matches session/request_permission requests
+func IsPermissionRequest(m *Message) bool {
+	return m.Method == MethodSessionRequestPermission
+}
@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReplayStepper(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "steps.jsonl")
+	writeFile(t, recordingFile,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{"update":{"sessionUpdate":"agent_message_chunk"}}}}`,
+		`{"timestamp":"2025-01-01T00:00:01Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{"update":{"sessionUpdate":"tool_call"}}}}`,
+		`{"timestamp":"2025-01-01T00:00:02Z","direction":"in","data":{"jsonrpc":"2.0","id":4,"method":"session/request_permission","params":{}}}`,
+		`{"timestamp":"2025-01-01T00:00:03Z","direction":"in","data":{"jsonrpc":"2.0","id":1,"result":{}}}`,
+	)
+
+	conn, err := OpenAcpReplayConnection(recordingFile, ReplayOptions{})
+	if err != nil {
+		t.Fatalf("OpenAcpReplayConnection() error: %v", err)
+	}
+	defer conn.Close()
+
+	var delivered []string
+	conn.Registry().Handle(MethodSessionUpdate, func(acpConn *AcpConnection, msg *Message) error {
+		delivered = append(delivered, msg.UpdateType())
+		return nil
+	})
+
+	stepper, err := NewReplayStepper(conn)
+	if err != nil {
+		t.Fatalf("NewReplayStepper() error: %v", err)
+	}
+	if stepper.Len() != 4 {
+		t.Fatalf("Expected 4 messages, got %d", stepper.Len())
+	}
+	if stepper.Current() != nil {
+		t.Error("Expected no current message before the first step")
+	}
+
+	ch := make(chan TurnResult, 1)
+	if _, err := stepper.Step(ch); err != nil {
+		t.Fatalf("Step() error: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0] != "agent_message_chunk" {
+		t.Errorf("Expected only the first update to be delivered, got %v", delivered)
+	}
+
+	if !stepper.SeekNext(IsPermissionRequest) || stepper.Position() != 2 {
+		t.Errorf("Expected to seek to the permission request at 2, at %d", stepper.Position())
+	}
+	if len(delivered) != 1 {
+		t.Errorf("Seeking should not deliver messages, got %v", delivered)
+	}
+
+	// Seeking again moves past the match it is on
+	if stepper.SeekNext(IsPermissionRequest) || stepper.Position() != 2 {
+		t.Errorf("Expected no further permission request and to stay at 2, at %d", stepper.Position())
+	}
+
+	if err := stepper.Seek(1); err != nil {
+		t.Fatalf("Seek() error: %v", err)
+	}
+	if !IsToolCall(stepper.messages[stepper.Position()]) {
+		t.Error("Expected message 1 to be a tool call")
+	}
+
+	if err := stepper.Seek(10); err == nil {
+		t.Error("Expected an error seeking past the end")
+	}
+
+	if err := stepper.Seek(3); err != nil {
+		t.Fatalf("Seek() error: %v", err)
+	}
+	if _, err := stepper.Step(ch); err != nil {
+		t.Fatalf("Step() error: %v", err)
+	}
+	if !stepper.Done() {
+		t.Error("Expected the stepper to be done")
+	}
+	if stepper.SeekNext(IsToolCall) {
+		t.Error("Expected no further tool calls")
+	}
+}
+
+func TestReplayStepper_SeekNextVisitsEachMatch(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "tools.jsonl")
+	writeFile(t, recordingFile,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{"update":{"sessionUpdate":"tool_call"}}}}`,
+		`{"timestamp":"2025-01-01T00:00:01Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{"update":{"sessionUpdate":"agent_message_chunk"}}}}`,
+		`{"timestamp":"2025-01-01T00:00:02Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{"update":{"sessionUpdate":"tool_call_update"}}}}`,
+	)
+
+	conn, err := OpenAcpReplayConnection(recordingFile, ReplayOptions{})
+	if err != nil {
+		t.Fatalf("OpenAcpReplayConnection() error: %v", err)
+	}
+	defer conn.Close()
+
+	stepper, err := NewReplayStepper(conn)
+	if err != nil {
+		t.Fatalf("NewReplayStepper() error: %v", err)
+	}
+
+	for _, want := range []int{2, 2} {
+		stepper.SeekNext(IsToolCall)
+		if stepper.Position() != want {
+			t.Errorf("Expected to be at %d, at %d", want, stepper.Position())
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// volatileReplayFields differ between runs and are ignored wherever they appear
+var volatileReplayFields = map[string]bool{
+	"sessionId": true,
+	"cwd":       true,
+}
+
+// ReplayMismatchError reports an outgoing message that differs from the recording
+type ReplayMismatchError struct {
+	Index       int
+	Expected    json.RawMessage
+	Actual      json.RawMessage
+	Differences []string
+}
+
+func (e *ReplayMismatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "outgoing message %d does not match the recording:", e.Index+1)
+	for _, difference := range e.Differences {
+		b.WriteString("\n  ")
+		b.WriteString(difference)
+	}
+	return b.String()
+}
+
+// strictReplayWriter checks each message we send against the next outbound
+// message in the recording
+type strictReplayWriter struct {
+	mutex    sync.Mutex
+	expected []json.RawMessage
+	sent     int
+}
+
+func newStrictReplayWriter(expected []json.RawMessage) *strictReplayWriter {
+	return &strictReplayWriter{expected: expected}
+}
+
+func (w *strictReplayWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	actual := json.RawMessage(bytes.TrimSpace(p))
+	index := w.sent
+	w.sent++
+
+	if index >= len(w.expected) {
+		return 0, &ReplayMismatchError{
+			Index:       index,
+			Actual:      actual,
+			Differences: []string{fmt.Sprintf("recording has no more outgoing messages, sent %s", actual)},
+		}
+	}
+
+	expected := w.expected[index]
+	differences, err := compareReplayMessages(expected, actual)
+	if err != nil {
+		return 0, err
+	}
+	if len(differences) > 0 {
+		return 0, &ReplayMismatchError{
+			Index:       index,
+			Expected:    expected,
+			Actual:      actual,
+			Differences: differences,
+		}
+	}
+
+	return len(p), nil
+}
+
+// compareReplayMessages lists the differences between a recorded and a sent
+// message, ignoring volatile fields and the IDs of our own requests
+func compareReplayMessages(expected, actual json.RawMessage) ([]string, error) {
+	var want, got map[string]any
+	if err := json.Unmarshal(expected, &want); err != nil {
+		return nil, fmt.Errorf("invalid recorded message: %v", err)
+	}
+	if err := json.Unmarshal(actual, &got); err != nil {
+		return nil, fmt.Errorf("invalid outgoing message: %v", err)
+	}
+
+	for _, message := range []map[string]any{want, got} {
+		if _, isRequest := message["method"]; isRequest {
+			delete(message, "id")
+		}
+	}
+
+	return diffJSON("", want, got), nil
+}
+
+func diffJSON(path string, want, got any) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return []string{describeDifference(path, want, got)}
+		}
+
+		keys := make(map[string]bool)
+		for key := range w {
+			keys[key] = true
+		}
+		for key := range g {
+			keys[key] = true
+		}
+
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			if !volatileReplayFields[key] {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Strings(sorted)
+
+		var differences []string
+		for _, key := range sorted {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			wantValue, inWant := w[key]
+			gotValue, inGot := g[key]
+			switch {
+			case !inWant:
+				differences = append(differences, fmt.Sprintf("%s: not recorded, sent %s", childPath, formatJSONValue(gotValue)))
+			case !inGot:
+				differences = append(differences, fmt.Sprintf("%s: recorded %s, not sent", childPath, formatJSONValue(wantValue)))
+			default:
+				differences = append(differences, diffJSON(childPath, wantValue, gotValue)...)
+			}
+		}
+		return differences
+
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return []string{describeDifference(path, want, got)}
+		}
+		if len(w) != len(g) {
+			return []string{fmt.Sprintf("%s: recorded %d items, sent %d", displayPath(path), len(w), len(g))}
+		}
+
+		var differences []string
+		for i := range w {
+			differences = append(differences, diffJSON(fmt.Sprintf("%s[%d]", path, i), w[i], g[i])...)
+		}
+		return differences
+
+	default:
+		if fmt.Sprint(want) != fmt.Sprint(got) || fmt.Sprintf("%T", want) != fmt.Sprintf("%T", got) {
+			return []string{describeDifference(path, want, got)}
+		}
+		return nil
+	}
+}
+
+func describeDifference(path string, want, got any) string {
+	return fmt.Sprintf("%s: recorded %s, sent %s", displayPath(path), formatJSONValue(want), formatJSONValue(got))
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "message"
+	}
+	return path
+}
+
+func formatJSONValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
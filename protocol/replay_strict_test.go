@@ -0,0 +1,96 @@
+package protocol
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrictReplay(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "strict.jsonl")
+	writeFile(t, recordingFile,
+		`{"type":"header","format_version":2,"agentgo_version":"test","session_id":"recorded","started_at":"2025-01-01T00:00:00Z"}`,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"out","data":{"jsonrpc":"2.0","id":0,"method":"session/new","params":{"cwd":"/recorded","mcpServers":[]}}}`,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","id":0,"result":{"sessionId":"recorded"}}}`,
+		`{"timestamp":"2025-01-01T00:00:01Z","direction":"out","data":{"jsonrpc":"2.0","id":1,"method":"session/prompt","params":{"sessionId":"recorded","prompt":[{"type":"text","text":"hello"}]}}}`,
+		`{"timestamp":"2025-01-01T00:00:02Z","direction":"in","data":{"jsonrpc":"2.0","id":7,"method":"session/request_permission","params":{}}}`,
+		`{"timestamp":"2025-01-01T00:00:03Z","direction":"out","data":{"jsonrpc":"2.0","id":7,"result":{"outcome":{"outcome":"selected","optionId":"allow"}}}}`,
+	)
+
+	open := func() *AcpConnection {
+		conn, err := OpenAcpReplayConnection(recordingFile, ReplayOptions{Strict: true})
+		if err != nil {
+			t.Fatalf("OpenAcpReplayConnection() error: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		if _, err := conn.InitializeSession(); err != nil {
+			t.Fatalf("InitializeSession() should match the recording: %v", err)
+		}
+		return conn
+	}
+
+	t.Run("matching session", func(t *testing.T) {
+		conn := open()
+		if err := conn.SendMessage("hello"); err != nil {
+			t.Fatalf("SendMessage() should match the recording: %v", err)
+		}
+		if err := conn.SendToolResponse(7, OptionIDAllowOnce); err != nil {
+			t.Fatalf("SendToolResponse() should match the recording: %v", err)
+		}
+		if err := conn.SendMessage("one more"); err == nil {
+			t.Error("Expected an error sending past the end of the recording")
+		}
+	})
+
+	t.Run("different permission choice", func(t *testing.T) {
+		conn := open()
+		if err := conn.SendMessage("hello"); err != nil {
+			t.Fatalf("SendMessage() should match the recording: %v", err)
+		}
+
+		err := conn.SendToolResponse(7, OptionIDRejectOnce)
+		var mismatch *ReplayMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("Expected a ReplayMismatchError, got %v", err)
+		}
+		expected := `result.outcome.optionId: recorded "allow", sent "reject"`
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected diff to contain %q, got:\n%v", expected, err)
+		}
+	})
+
+	t.Run("different prompt", func(t *testing.T) {
+		conn := open()
+		err := conn.SendMessage("goodbye")
+		expected := `params.prompt[0].text: recorded "hello", sent "goodbye"`
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected diff to contain %q, got: %v", expected, err)
+		}
+	})
+}
+
+func TestStrictReplay_WithInitialize(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "strict.jsonl")
+	writeFile(t, recordingFile,
+		`{"type":"header","format_version":2,"agentgo_version":"test","session_id":"recorded","started_at":"2025-01-01T00:00:00Z"}`,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"out","data":{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":1,"clientCapabilities":{"fs":{"readTextFile":false,"writeTextFile":false}}}}}`,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","id":0,"result":{"protocolVersion":1,"agentCapabilities":{"promptCapabilities":{"image":true}}}}}`,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"out","data":{"jsonrpc":"2.0","id":0,"method":"session/new","params":{"cwd":"/recorded","mcpServers":[]}}}`,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","id":0,"result":{"sessionId":"recorded"}}}`,
+	)
+
+	conn, err := OpenAcpReplayConnection(recordingFile, ReplayOptions{Strict: true})
+	if err != nil {
+		t.Fatalf("OpenAcpReplayConnection() error: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.InitializeSession(); err != nil {
+		t.Fatalf("InitializeSession() should match the recording: %v", err)
+	}
+	if capabilities := conn.PromptCapabilities(); !capabilities.Image || capabilities.EmbeddedContext {
+		t.Errorf("Expected the recorded capabilities, got %+v", capabilities)
+	}
+}
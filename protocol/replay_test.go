@@ -0,0 +1,144 @@
+package protocol
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseReplaySpeed(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{input: "1", want: 1},
+		{input: "0.5", want: 0.5},
+		{input: "2x", want: 2},
+		{input: "max", want: 0},
+		{input: "MAX", want: 0},
+		{input: "0", wantErr: true},
+		{input: "-1", wantErr: true},
+		{input: "fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseReplaySpeed(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReplaySpeed(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReplaySpeed(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayOptions_Delay(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ReplayOptions
+		gap     time.Duration
+		want    time.Duration
+	}{
+		{name: "real time", options: RealTimeReplay, gap: time.Second, want: time.Second},
+		{name: "half speed", options: ReplayOptions{Speed: 0.5}, gap: time.Second, want: 2 * time.Second},
+		{name: "double speed", options: ReplayOptions{Speed: 2}, gap: time.Second, want: 500 * time.Millisecond},
+		{name: "max speed", options: ReplayOptions{}, gap: time.Second, want: 0},
+		{name: "capped gap", options: ReplayOptions{Speed: 2, MaxGap: time.Second}, gap: time.Minute, want: 500 * time.Millisecond},
+		{name: "negative gap", options: RealTimeReplay, gap: -time.Second, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.delay(tt.gap); got != tt.want {
+				t.Errorf("delay(%v) = %v, want %v", tt.gap, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayIOProvider_HonorsRecordedGaps(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "timed.jsonl")
+	writeFile(t, recordingFile,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{}}}`,
+		`{"timestamp":"2025-01-01T00:00:00.100Z","direction":"out","data":{"jsonrpc":"2.0","id":1,"method":"session/prompt"}}`,
+		`{"timestamp":"2025-01-01T00:00:00.200Z","direction":"in","data":{"jsonrpc":"2.0","id":1,"result":{}}}`,
+	)
+
+	provider, err := NewReplayIOProvider(recordingFile, RealTimeReplay)
+	if err != nil {
+		t.Fatalf("NewReplayIOProvider() error: %v", err)
+	}
+	defer provider.Close()
+
+	start := time.Now()
+	if _, err := io.ReadAll(provider.GetReader()); err != nil {
+		t.Fatalf("Failed to read replay: %v", err)
+	}
+
+	// The pause before the response includes the time around our prompt
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected replay to take at least 200ms, took %v", elapsed)
+	}
+}
+
+func TestReplayIOProvider_CarriesGapsAcrossOutbound(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "outbound.jsonl")
+	writeFile(t, recordingFile,
+		`{"timestamp":"2025-01-01T00:00:00Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{}}}`,
+		`{"timestamp":"2025-01-01T00:00:03Z","direction":"out","data":{"jsonrpc":"2.0","id":1,"method":"session/prompt"}}`,
+		`{"timestamp":"2025-01-01T00:00:04Z","direction":"out","data":{"jsonrpc":"2.0","method":"session/cancel"}}`,
+		`{"timestamp":"2025-01-01T00:00:05Z","direction":"in","data":{"jsonrpc":"2.0","id":1,"result":{}}}`,
+		`{"timestamp":"2025-01-01T00:00:06Z","direction":"in","data":{"jsonrpc":"2.0","method":"session/update","params":{}}}`,
+	)
+
+	provider, err := NewReplayIOProvider(recordingFile, ReplayOptions{Speed: 1000})
+	if err != nil {
+		t.Fatalf("NewReplayIOProvider() error: %v", err)
+	}
+	defer provider.Close()
+
+	var delays []time.Duration
+	for _, frame := range provider.reader.frames {
+		delays = append(delays, frame.delay)
+	}
+	want := []time.Duration{0, 5 * time.Millisecond, time.Millisecond}
+	if !reflect.DeepEqual(delays, want) {
+		t.Errorf("Expected delays %v, got %v", want, delays)
+	}
+}
+
+func TestReplayIOProvider_CloseStopsPlayback(t *testing.T) {
+	recordingFile := filepath.Join(t.TempDir(), "slow.jsonl")
+	writeFile(t, recordingFile,
+		`{"timestamp":"2025-01-01T00:00:00Z","data":{"jsonrpc":"2.0","method":"session/update","params":{}}}`,
+		`{"timestamp":"2025-01-01T01:00:00Z","data":{"jsonrpc":"2.0","method":"session/update","params":{}}}`,
+	)
+
+	provider, err := NewReplayIOProvider(recordingFile, RealTimeReplay)
+	if err != nil {
+		t.Fatalf("NewReplayIOProvider() error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(provider.GetReader())
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	provider.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean end of stream, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not interrupt the pending replay gap")
+	}
+}
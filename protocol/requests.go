@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// requestTimeout bounds the wait for the agent to answer a request such as
+// session/set_mode
+const requestTimeout = 30 * time.Second
+
+// clientRequests hands out the IDs of the requests sent to the agent, so no
+// two share one, and tracks them until their responses arrive
+type clientRequests struct {
+	mutex  sync.Mutex
+	nextID int
+	// recorded holds the IDs a replayed recording used for each method.
+	// Requests sent in their place take them in order, so the recorded
+	// responses answer them.
+	recorded map[string][]int
+	pending  map[int]chan *Message
+	// prompts counts the session/prompt requests of each ID whose turns
+	// have not ended. Only old recordings send several with one ID.
+	prompts map[int]int
+}
+
+// reserve takes the ID for the next request of method
+func (r *clientRequests) reserve(method string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	id, _ := r.reserveLocked(method)
+	return id
+}
+
+// reserveLocked takes the ID for the next request of method, reporting
+// whether it is one the replayed recording used
+func (r *clientRequests) reserveLocked(method string) (int, bool) {
+	if ids := r.recorded[method]; len(ids) > 0 {
+		r.recorded[method] = ids[1:]
+		return ids[0], true
+	}
+	id := r.nextID
+	r.nextID++
+	return id, false
+}
+
+// replay makes requests take the IDs a recording used, method by method,
+// and new IDs after those. The recorded prompts' responses end turns even
+// when no prompt is sent in their place.
+func (r *clientRequests) replay(recorded map[string][]int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.recorded = recorded
+	for method, ids := range recorded {
+		for _, id := range ids {
+			r.nextID = max(r.nextID, id+1)
+			if method == MethodSessionPrompt {
+				r.addPromptLocked(id)
+			}
+		}
+	}
+}
+
+// add reserves an ID for a request and returns where its response is sent
+func (r *clientRequests) add(method string) (int, chan *Message) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.pending == nil {
+		r.pending = make(map[int]chan *Message)
+	}
+	id, _ := r.reserveLocked(method)
+	reply := make(chan *Message, 1)
+	r.pending[id] = reply
+	return id, reply
+}
+
+func (r *clientRequests) remove(id int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.pending, id)
+}
+
+// addPrompt reserves the ID of a session/prompt request, whose response
+// ends its turn
+func (r *clientRequests) addPrompt() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id, recorded := r.reserveLocked(MethodSessionPrompt)
+	// A recorded prompt was counted when the replay started
+	if !recorded {
+		r.addPromptLocked(id)
+	}
+	return id
+}
+
+func (r *clientRequests) addPromptLocked(id int) {
+	if r.prompts == nil {
+		r.prompts = make(map[int]int)
+	}
+	r.prompts[id]++
+}
+
+// endPrompt returns true when msg answers a prompt whose turn has not
+// ended, and ends it
+func (r *clientRequests) endPrompt(msg *Message) bool {
+	var id int
+	if json.Unmarshal(msg.ID, &id) != nil {
+		return false
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.prompts[id] == 0 {
+		return false
+	}
+	r.prompts[id]--
+	if r.prompts[id] == 0 {
+		delete(r.prompts, id)
+	}
+	return true
+}
+
+// deliver passes a response to the request waiting for it, returning false
+// when no request has its ID
+func (r *clientRequests) deliver(msg *Message) bool {
+	var id int
+	if json.Unmarshal(msg.ID, &id) != nil {
+		return false
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	reply, ok := r.pending[id]
+	if ok {
+		delete(r.pending, id)
+		reply <- msg
+	}
+	return ok
+}
+
+// request sends a request and waits for its result. The response is read by
+// StreamResponses, which must be running.
+func (acpConn *AcpConnection) request(method string, params any) (json.RawMessage, error) {
+	id, reply := acpConn.requests.add(method)
+
+	data, err := json.Marshal(SessionRequest{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		acpConn.requests.remove(id)
+		return nil, err
+	}
+	if err := acpConn.messageWriter().WriteMessage(data); err != nil {
+		acpConn.requests.remove(id)
+		return nil, err
+	}
+
+	select {
+	case msg := <-reply:
+		if err := responseError(msg); err != nil {
+			return nil, fmt.Errorf("%s failed: %w", method, err)
+		}
+		return msg.Result, nil
+	case <-time.After(requestTimeout):
+		acpConn.requests.remove(id)
+		return nil, fmt.Errorf("no response to %s after %v", method, requestTimeout)
+	}
+}
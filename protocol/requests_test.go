@@ -0,0 +1,72 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRequests_HaveUniqueIDs(t *testing.T) {
+	var out bytes.Buffer
+	responses := `{"jsonrpc":"2.0","id":0,"result":{"protocolVersion":1}}` + "\n" +
+		`{"jsonrpc":"2.0","id":1,"result":{"sessionId":"s1"}}` + "\n"
+	conn := &AcpConnection{reader: strings.NewReader(responses), writer: &out}
+
+	if _, err := conn.InitializeSession(); err != nil {
+		t.Fatalf("InitializeSession() error: %v", err)
+	}
+	for _, prompt := range []string{"one", "two"} {
+		if err := conn.SendMessage(prompt); err != nil {
+			t.Fatalf("SendMessage() error: %v", err)
+		}
+	}
+
+	seen := map[string]string{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var sent Message
+		if err := decoder.Decode(&sent); err != nil {
+			t.Fatalf("Failed to read request: %v", err)
+		}
+		if method, ok := seen[string(sent.ID)]; ok {
+			t.Errorf("%s and %s share the ID %s", method, sent.Method, sent.ID)
+		}
+		seen[string(sent.ID)] = sent.Method
+	}
+	if len(seen) != 4 {
+		t.Errorf("Expected 4 requests, got %v", seen)
+	}
+}
+
+func TestClientRequests_ReplayTakesRecordedIDs(t *testing.T) {
+	// Old recordings sent initialize and session/new with ID 0 and every
+	// prompt with ID 1
+	var requests clientRequests
+	requests.replay(map[string][]int{
+		MethodInitialize:    {0},
+		MethodSessionNew:    {0},
+		MethodSessionPrompt: {1, 1},
+	})
+
+	if id := requests.reserve(MethodSessionNew); id != 0 {
+		t.Errorf("Expected session/new to take the recorded ID 0, got %d", id)
+	}
+	if id := requests.addPrompt(); id != 1 {
+		t.Errorf("Expected the prompt to take the recorded ID 1, got %d", id)
+	}
+	if id, _ := requests.add(MethodSessionSetMode); id != 2 {
+		t.Errorf("Expected a request the recording lacks to take ID 2, got %d", id)
+	}
+
+	// Both recorded prompts end a turn, whether or not one was sent again
+	response := &Message{ID: json.RawMessage("1")}
+	for i := range 2 {
+		if !requests.endPrompt(response) {
+			t.Errorf("Expected response %d to end a turn", i+1)
+		}
+	}
+	if requests.endPrompt(response) {
+		t.Error("Expected no third turn to end")
+	}
+}
@@ -0,0 +1,31 @@
+package protocol
+
+// Modes returns the modes the agent offered when the session was created,
+// or nil when it offered none
+func (acpConn *AcpConnection) Modes() *SessionModeState {
+	return acpConn.session.Modes
+}
+
+// Models returns the models the agent offered when the session was created,
+// or nil when it offered none
+func (acpConn *AcpConnection) Models() *SessionModelState {
+	return acpConn.session.Models
+}
+
+// SetMode switches the session to one of the agent's modes
+func (acpConn *AcpConnection) SetMode(modeID string) error {
+	_, err := acpConn.request(MethodSessionSetMode, SetSessionModeParams{
+		SessionID: acpConn.sessionID,
+		ModeID:    modeID,
+	})
+	return err
+}
+
+// SetModel switches the session to one of the agent's models
+func (acpConn *AcpConnection) SetModel(modelID string) error {
+	_, err := acpConn.request(MethodSessionSetModel, SetSessionModelParams{
+		SessionID: acpConn.sessionID,
+		ModelID:   modelID,
+	})
+	return err
+}
@@ -1,5 +1,22 @@
 package protocol
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ACP method names
+const (
+	MethodInitialize               = "initialize"
+	MethodSessionNew               = "session/new"
+	MethodSessionPrompt            = "session/prompt"
+	MethodSessionUpdate            = "session/update"
+	MethodSessionRequestPermission = "session/request_permission"
+	MethodSessionCancel            = "session/cancel"
+	MethodSessionSetMode           = "session/set_mode"
+	MethodSessionSetModel          = "session/set_model"
+)
+
 type InitializeRequest struct {
 	JSONRPC string `json:"jsonrpc"`
 	ID      int    `json:"id"`
@@ -21,6 +38,27 @@ type FileSystemCapabilities struct {
 	WriteTextFile bool `json:"writeTextFile"`
 }
 
+// ProtocolVersion is the ACP version agentgo speaks
+const ProtocolVersion = 1
+
+type InitializeResult struct {
+	ProtocolVersion   int               `json:"protocolVersion"`
+	AgentCapabilities AgentCapabilities `json:"agentCapabilities"`
+}
+
+type AgentCapabilities struct {
+	LoadSession        bool               `json:"loadSession,omitempty"`
+	PromptCapabilities PromptCapabilities `json:"promptCapabilities"`
+}
+
+// PromptCapabilities lists the content blocks an agent accepts in prompts
+// beyond text and resource links, which every agent accepts
+type PromptCapabilities struct {
+	Image           bool `json:"image,omitempty"`
+	Audio           bool `json:"audio,omitempty"`
+	EmbeddedContext bool `json:"embeddedContext,omitempty"`
+}
+
 type SessionNewRequest struct {
 	JSONRPC string        `json:"jsonrpc"`
 	ID      int           `json:"id"`
@@ -33,6 +71,56 @@ type SessionParams struct {
 	MCPServers []MCPServer `json:"mcpServers"`
 }
 
+type SessionNewResult struct {
+	SessionID string             `json:"sessionId"`
+	Modes     *SessionModeState  `json:"modes,omitempty"`
+	Models    *SessionModelState `json:"models,omitempty"`
+}
+
+// SessionModeState lists the modes an agent can work in, such as asking
+// before every edit or planning without making changes
+type SessionModeState struct {
+	CurrentModeID  string        `json:"currentModeId"`
+	AvailableModes []SessionMode `json:"availableModes"`
+}
+
+type SessionMode struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SessionModelState lists the models an agent can switch between
+type SessionModelState struct {
+	CurrentModelID  string      `json:"currentModelId"`
+	AvailableModels []ModelInfo `json:"availableModels"`
+}
+
+type ModelInfo struct {
+	ModelID     string `json:"modelId"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SessionRequest is a request from the client other than session/prompt,
+// such as session/set_mode
+type SessionRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type SetSessionModeParams struct {
+	SessionID string `json:"sessionId"`
+	ModeID    string `json:"modeId"`
+}
+
+type SetSessionModelParams struct {
+	SessionID string `json:"sessionId"`
+	ModelID   string `json:"modelId"`
+}
+
 type MCPServer struct {
 	Name    string   `json:"name"`
 	Command string   `json:"command"`
@@ -52,12 +140,41 @@ type SessionPromptParams struct {
 	Prompt    []Prompt `json:"prompt"`
 }
 
+// SessionCancelNotification asks the agent to stop the running turn. It is a
+// notification, so it has no ID; the pending session/prompt request is
+// answered with the cancelled stop reason instead.
+type SessionCancelNotification struct {
+	JSONRPC string              `json:"jsonrpc"`
+	Method  string              `json:"method"`
+	Params  SessionCancelParams `json:"params"`
+}
+
+type SessionCancelParams struct {
+	SessionID string `json:"sessionId"`
+}
+
+// Prompt is a content block of a prompt. Text blocks set Text, image blocks
+// MimeType and base64 Data, resource_link blocks URI, Name and optionally
+// MimeType and Size, and resource blocks embed a Resource.
 type Prompt struct {
 	Type     string          `json:"type"`
 	Text     string          `json:"text,omitempty"`
+	MimeType string          `json:"mimeType,omitempty"`
+	Data     string          `json:"data,omitempty"`
+	URI      string          `json:"uri,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Size     int64           `json:"size,omitempty"`
 	Resource *PromptResource `json:"resource,omitempty"`
 }
 
+// Content block types
+const (
+	PromptText         = "text"
+	PromptImage        = "image"
+	PromptResourceLink = "resource_link"
+	PromptEmbedded     = "resource"
+)
+
 type PromptResource struct {
 	URI      string `json:"uri"`
 	MimeType string `json:"mimeType"`
@@ -70,6 +187,15 @@ type SessionUpdateRequest struct {
 	Params  SessionUpdateParams `json:"params"`
 }
 
+func (r *SessionUpdateRequest) fromMessage(msg *Message) error {
+	r.JSONRPC, r.Method = msg.JSONRPC, msg.Method
+	return json.Unmarshal(msg.Params, &r.Params)
+}
+
+func (r *SessionUpdateRequest) sessionID() string {
+	return r.Params.SessionID
+}
+
 type SessionUpdateParams struct {
 	SessionID string        `json:"sessionId"`
 	Update    SessionUpdate `json:"update"`
@@ -77,14 +203,84 @@ type SessionUpdateParams struct {
 }
 
 type SessionUpdate struct {
-	AvailableCommands []Command `json:"availableCommands,omitempty"`
-	SessionUpdateType string    `json:"sessionUpdate"`
+	AvailableCommands []Command      `json:"availableCommands,omitempty"`
+	SessionUpdateType string         `json:"sessionUpdate"`
+	Content           *UpdateContent `json:"content,omitempty"`
+	Entries           []PlanEntry    `json:"entries,omitempty"`
+
+	// CurrentModeID is set on current_mode_update updates
+	CurrentModeID string `json:"currentModeId,omitempty"`
+
+	// Tool call fields, set on tool_call and tool_call_update updates
+	ToolCallID string `json:"toolCallId,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Status     string `json:"status,omitempty"`
+	// Locations are the files the tool call works on
+	Locations []ToolCallLocation `json:"locations,omitempty"`
+	RawInput  map[string]any     `json:"rawInput,omitempty"`
+}
+
+// ToolCallLocation is a file, and optionally a line in it, that a tool call
+// reads or changes
+type ToolCallLocation struct {
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+}
+
+// UpdateContent is the single content block carried by message chunks.
+// Other shapes, such as the content lists on tool call updates, decode as empty.
+type UpdateContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
 }
 
+func (c *UpdateContent) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 || data[0] != '{' {
+		return nil
+	}
+
+	type plain UpdateContent
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+type PlanEntry struct {
+	Content  string `json:"content"`
+	Status   string `json:"status"`
+	Priority string `json:"priority"`
+}
+
+// Session updates that change what the client offers the user
+const (
+	// SessionUpdateAvailableCommands lists the agent's slash commands
+	SessionUpdateAvailableCommands = "available_commands_update"
+	// SessionUpdateCurrentMode reports that the agent switched modes
+	SessionUpdateCurrentMode = "current_mode_update"
+)
+
+// Session updates that report the agent's progress in a turn
+const (
+	SessionUpdateAgentMessage = "agent_message_chunk"
+	SessionUpdateAgentThought = "agent_thought_chunk"
+	SessionUpdateUserMessage  = "user_message_chunk"
+	SessionUpdateToolCall     = "tool_call"
+	// SessionUpdateToolCallUpdate changes some fields of an earlier tool call
+	SessionUpdateToolCallUpdate = "tool_call_update"
+	SessionUpdatePlan           = "plan"
+)
+
+// Command is a slash command the agent accepts, advertised with an
+// available_commands_update. It is invoked by sending "/name input" as a
+// prompt.
 type Command struct {
-	Description string `json:"description"`
-	Input       any    `json:"input"`
-	Name        string `json:"name"`
+	Description string        `json:"description"`
+	Input       *CommandInput `json:"input,omitempty"`
+	Name        string        `json:"name"`
+}
+
+// CommandInput describes the text a command expects after its name
+type CommandInput struct {
+	Hint string `json:"hint"`
 }
 
 type SessionRequestPermissionRequest struct {
@@ -94,6 +290,18 @@ type SessionRequestPermissionRequest struct {
 	Params  SessionRequestPermissionParams `json:"params"`
 }
 
+func (r *SessionRequestPermissionRequest) fromMessage(msg *Message) error {
+	r.JSONRPC, r.Method = msg.JSONRPC, msg.Method
+	if err := json.Unmarshal(msg.ID, &r.ID); err != nil {
+		return fmt.Errorf("invalid %s id %s: %w", msg.Method, msg.ID, err)
+	}
+	return json.Unmarshal(msg.Params, &r.Params)
+}
+
+func (r *SessionRequestPermissionRequest) sessionID() string {
+	return r.Params.SessionID
+}
+
 type SessionRequestPermissionParams struct {
 	SessionID string             `json:"sessionId"`
 	ToolCall  ToolCall           `json:"toolCall"`
@@ -123,9 +331,16 @@ type ToolPermissionResult struct {
 
 type ToolPermissionOutcome struct {
 	Outcome  string `json:"outcome"`
-	OptionID string `json:"optionId"`
+	OptionID string `json:"optionId,omitempty"`
 }
 
+// Permission outcomes: an option was selected, or the turn was cancelled
+// while the request was pending
+const (
+	PermissionOutcomeSelected  = "selected"
+	PermissionOutcomeCancelled = "cancelled"
+)
+
 type PermissionOption struct {
 	OptionID string `json:"optionId"`
 	Name     string `json:"name"`
@@ -143,6 +358,29 @@ type ResponseResult struct {
 	StopReason string `json:"stopReason,omitempty"`
 }
 
+// Reasons a session/prompt response gives for ending the turn
+const (
+	StopReasonEndTurn         = "end_turn"
+	StopReasonMaxTokens       = "max_tokens"
+	StopReasonMaxTurnRequests = "max_turn_requests"
+	StopReasonRefusal         = "refusal"
+	StopReasonCancelled       = "cancelled"
+)
+
+// JSON-RPC error codes
+const (
+	ErrorCodeMethodNotFound = -32601
+	ErrorCodeInvalidParams  = -32602
+)
+
+// ErrorResponse is a JSON-RPC error reply to an agent request. The ID is
+// echoed back exactly as received, so it may be a number or a string.
+type ErrorResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      any           `json:"id"`
+	Error   ResponseError `json:"error"`
+}
+
 type ResponseError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -0,0 +1,161 @@
+package protocol
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultWriteTimeout bounds how long a single message may take to reach the agent
+const DefaultWriteTimeout = 10 * time.Second
+
+// DefaultSendQueueSize is the number of outgoing messages buffered for the writer
+const DefaultSendQueueSize = 64
+
+var (
+	// ErrWriteTimeout is returned when the agent does not accept a message in time
+	ErrWriteTimeout = errors.New("write to agent timed out")
+	// ErrWriterClosed is returned for messages sent after the writer is closed
+	ErrWriterClosed = errors.New("writer closed")
+)
+
+// MessageWriter sends newline-framed messages to the agent from a single
+// goroutine, so writes from the interaction loop and handlers never
+// interleave. Callers block until their message is written or fails.
+type MessageWriter struct {
+	writer     io.Writer
+	timeout    time.Duration
+	afterWrite func(data []byte)
+	queue      chan outboundMessage
+	closed     chan struct{}
+	closeOnce  sync.Once
+
+	mutex sync.Mutex
+	err   error
+}
+
+type outboundMessage struct {
+	data []byte
+	done chan error
+}
+
+// NewMessageWriter starts a writer goroutine. afterWrite, if set, runs on the
+// writer goroutine after each successful write, in send order.
+func NewMessageWriter(
+	w io.Writer,
+	timeout time.Duration,
+	queueSize int,
+	afterWrite func(data []byte),
+) *MessageWriter {
+	if queueSize <= 0 {
+		queueSize = DefaultSendQueueSize
+	}
+
+	m := &MessageWriter{
+		writer:     w,
+		timeout:    timeout,
+		afterWrite: afterWrite,
+		queue:      make(chan outboundMessage, queueSize),
+		closed:     make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// WriteMessage queues a single JSON message and waits for it to be written.
+// A failed or timed out write breaks the stream, so every later call returns
+// the same error.
+func (m *MessageWriter) WriteMessage(data []byte) error {
+	if err := m.failure(); err != nil {
+		return err
+	}
+
+	select {
+	case <-m.closed:
+		return ErrWriterClosed
+	default:
+	}
+
+	message := outboundMessage{data: data, done: make(chan error, 1)}
+
+	var timeout <-chan time.Time
+	if m.timeout > 0 {
+		timer := time.NewTimer(m.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case m.queue <- message:
+	case <-m.closed:
+		return ErrWriterClosed
+	case <-timeout:
+		return m.fail(ErrWriteTimeout)
+	}
+
+	select {
+	case err := <-message.done:
+		if err != nil {
+			return m.fail(err)
+		}
+		return nil
+	case <-m.closed:
+		return ErrWriterClosed
+	case <-timeout:
+		return m.fail(ErrWriteTimeout)
+	}
+}
+
+// Close stops the writer goroutine. Queued messages that were not yet
+// written fail with ErrWriterClosed.
+func (m *MessageWriter) Close() {
+	m.closeOnce.Do(func() {
+		close(m.closed)
+	})
+}
+
+func (m *MessageWriter) run() {
+	for {
+		select {
+		case message := <-m.queue:
+			message.done <- m.write(message.data)
+		case <-m.closed:
+			return
+		}
+	}
+}
+
+func (m *MessageWriter) write(data []byte) error {
+	if deadliner, ok := m.writer.(interface{ SetWriteDeadline(time.Time) error }); ok && m.timeout > 0 {
+		_ = deadliner.SetWriteDeadline(time.Now().Add(m.timeout))
+	}
+
+	framed := make([]byte, 0, len(data)+1)
+	framed = append(append(framed, data...), '\n')
+	if _, err := m.writer.Write(framed); err != nil {
+		return err
+	}
+
+	if m.afterWrite != nil {
+		m.afterWrite(data)
+	}
+	return nil
+}
+
+func (m *MessageWriter) failure() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.err
+}
+
+func (m *MessageWriter) fail(err error) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.err == nil {
+		m.err = err
+	}
+	return m.err
+}
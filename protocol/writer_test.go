@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMessageWriter_ConcurrentWritesStayFramed(t *testing.T) {
+	var out bytes.Buffer
+	var recorded [][]byte
+	writer := NewMessageWriter(&out, time.Second, 0, func(data []byte) {
+		recorded = append(recorded, data)
+	})
+	defer writer.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{}}`, i))
+			if err := writer.WriteMessage(data); err != nil {
+				t.Errorf("WriteMessage() error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	scanner := bufio.NewScanner(&out)
+	lines := 0
+	for scanner.Scan() {
+		var message map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			t.Errorf("Line %d is not a complete message: %q", lines, scanner.Text())
+		}
+		lines++
+	}
+	if lines != 20 {
+		t.Errorf("Expected 20 lines, got %d", lines)
+	}
+	if len(recorded) != 20 {
+		t.Errorf("Expected 20 recorded messages, got %d", len(recorded))
+	}
+}
+
+func TestMessageWriter_TimeoutBreaksStream(t *testing.T) {
+	reader, pipe := io.Pipe()
+	defer reader.Close()
+
+	writer := NewMessageWriter(pipe, 20*time.Millisecond, 0, nil)
+	defer writer.Close()
+
+	if err := writer.WriteMessage([]byte(`{}`)); !errors.Is(err, ErrWriteTimeout) {
+		t.Fatalf("Expected ErrWriteTimeout, got %v", err)
+	}
+	if err := writer.WriteMessage([]byte(`{}`)); !errors.Is(err, ErrWriteTimeout) {
+		t.Errorf("Expected later writes to fail with ErrWriteTimeout, got %v", err)
+	}
+}
+
+func TestMessageWriter_WriteErrorPropagates(t *testing.T) {
+	reader, pipe := io.Pipe()
+	reader.Close()
+
+	writer := NewMessageWriter(pipe, time.Second, 0, nil)
+	defer writer.Close()
+
+	if err := writer.WriteMessage([]byte(`{}`)); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Expected io.ErrClosedPipe, got %v", err)
+	}
+}
+
+func TestMessageWriter_Closed(t *testing.T) {
+	writer := NewMessageWriter(io.Discard, time.Second, 0, nil)
+	writer.Close()
+
+	if err := writer.WriteMessage([]byte(`{}`)); err == nil {
+		t.Error("Expected an error writing to a closed writer")
+	}
+}
@@ -1,45 +1,77 @@
 package claude
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"agentgo/internal/events"
+	"agentgo/internal/render"
 	"agentgo/protocol"
 )
 
-// DisplayToolRequest shows a formatted tool permission request to the user
-func DisplayToolRequest(
-	toolType ToolType,
-	toolID string,
-	rawParams map[string]any,
-	options []protocol.PermissionOption,
-) error {
+var (
+	borderStyle    = render.Style{Color: render.Cyan, Bold: true}
+	headingStyle   = render.Style{Color: render.Green, Bold: true}
+	highlightStyle = render.Style{Color: render.Yellow, Bold: true}
+	detailStyle    = render.Style{Color: render.White}
+)
+
+// ToolRequestEvent describes the tool call a permission request is about as
+// an event, the same way its session updates are
+func ToolRequestEvent(params protocol.SessionRequestPermissionParams, at time.Time) events.Event {
+	event, _ := events.FromUpdate(protocol.SessionUpdate{
+		SessionUpdateType: protocol.SessionUpdateToolCallUpdate,
+		ToolCallID:        params.ToolCall.ToolCallID,
+		RawInput:          params.ToolCall.RawInput,
+	}, at)
+	return event
+}
+
+// DisplayToolRequest shows a formatted tool permission request to the user.
+// event describes the tool call, as made by ToolRequestEvent.
+func DisplayToolRequest(r render.Renderer, event events.Event, options []protocol.PermissionOption) error {
+	call := event.ToolCall
+	if call == nil {
+		return nil
+	}
+	rawParams := call.Input
+	if rawParams == nil {
+		rawParams = map[string]any{}
+	}
 	params := formatParamsForDisplay(rawParams)
 
-	fmt.Printf("\n\033[1;36m╭─ Tool Request ─────────────────────────────────╮\033[0m\n")
-	fmt.Printf("\033[1;36m│\033[0m \033[1;33m🔧 %s\033[0m\n", toolType)
-	fmt.Printf("\033[1;36m│\033[0m ID: \033[0;37m%s\033[0m\n", toolID)
+	lines := []string{
+		r.Paint(highlightStyle, fmt.Sprintf("🔧 %s", ClassifyToolInput(rawParams))),
+		"ID: " + r.Paint(detailStyle, call.ID),
+	}
 
 	if len(params) > 0 {
-		fmt.Printf("\033[1;36m│\033[0m\n\033[1;36m│\033[0m \033[1;32mParameters:\033[0m\n")
-		for key, value := range params {
+		lines = append(lines, "", r.Paint(headingStyle, "Parameters:"))
+		for _, key := range orderedParamKeys(params) {
+			value := fmt.Sprint(params[key])
 			if strings.HasPrefix(key, "📁") || strings.HasPrefix(key, "💻") ||
 				strings.HasPrefix(key, "📝") {
-				fmt.Printf("\033[1;36m│\033[0m   %s: \033[0;33m%v\033[0m\n", key, value)
+				lines = append(lines, fmt.Sprintf("  %s: %s", key, r.Paint(render.Style{Color: render.Yellow}, value)))
 			} else {
 				switch key {
 				case "old_string":
-					fmt.Printf("\033[1;36m│\033[0m   🔍 Replace: \033[0;31m%v\033[0m\n", value)
+					lines = append(lines, "  🔍 Replace: "+r.Paint(render.Style{Color: render.Red}, value))
 				case "new_string":
-					fmt.Printf("\033[1;36m│\033[0m   ✏️  With: \033[0;32m%v\033[0m\n", value)
+					lines = append(lines, "  ✏️  With: "+r.Paint(render.Style{Color: render.Green}, value))
 				default:
-					fmt.Printf("\033[1;36m│\033[0m   • %s: \033[0;37m%v\033[0m\n", key, value)
+					lines = append(lines, fmt.Sprintf("  • %s: %s", key, r.Paint(detailStyle, value)))
 				}
 			}
 		}
 	}
 
-	fmt.Printf("\033[1;36m│\033[0m\n\033[1;36m│\033[0m \033[1;32mOptions:\033[0m\n")
+	lines = append(lines, "", r.Paint(headingStyle, "Options:"))
 	for i, option := range options {
 		var icon string
 		switch option.OptionID {
@@ -52,31 +84,127 @@ func DisplayToolRequest(
 		default:
 			icon = "⚪"
 		}
-		fmt.Printf("\033[1;36m│\033[0m   [%d] %s %s\n", i+1, icon, option.Name)
+		lines = append(lines, fmt.Sprintf("  [%d] %s %s", i+1, icon, option.Name))
 	}
-	fmt.Printf("\033[1;36m╰────────────────────────────────────────────────╯\033[0m\n")
+
+	r.Println()
+	r.Box(borderStyle, "Tool Request", lines)
 
 	return nil
 }
 
-// PromptUserChoice asks the user to select from the available options
-func PromptUserChoice(numOptions int) (int, error) {
-	var choice int
-	fmt.Printf("\n\033[1;33m❓ Select your choice (1-%d):\033[0m ", numOptions)
-	fmt.Scanf("%d", &choice)
+// ErrPromptCancelled is returned by the input of a permission prompt when the
+// turn is cancelled before the user answers
+var ErrPromptCancelled = errors.New("permission prompt cancelled")
+
+// defaultChoice is picked when the answer is not one of the options, or
+// the only option when there is just one
+const defaultChoice = 2
+
+// PromptUserChoice asks the user to select from the available options,
+// reading the answer from in
+func PromptUserChoice(r render.Renderer, in io.Reader, numOptions int) (int, error) {
+	r.Printf("\n%s ", r.Paint(highlightStyle, fmt.Sprintf("❓ Select your choice (1-%d):", numOptions)))
 
-	if choice < 1 || choice > numOptions {
-		choice = 2
+	line, err := readLine(in)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	choice, convErr := strconv.Atoi(strings.TrimSpace(line))
+	if convErr != nil || choice < 1 || choice > numOptions {
+		choice = min(defaultChoice, numOptions)
 	}
 
 	return choice, nil
 }
 
-func ShowUserSelection(selectedOption string) error {
-	fmt.Printf("\033[1;32m✓ Selected:\033[0m %s\n\n", selectedOption)
+// readLine reads up to and including the next newline a byte at a time, so
+// nothing after the answer is taken from a shared stdin
+func readLine(in io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
+
+func ShowUserSelection(r render.Renderer, selectedOption string) error {
+	r.Printf("%s %s\n\n", r.Paint(headingStyle, "✓ Selected:"), selectedOption)
 	return nil
 }
 
+// DisplayAttachments lists the files sent with a prompt
+func DisplayAttachments(r render.Renderer, descriptions []string) {
+	if len(descriptions) == 0 {
+		return
+	}
+	r.Println(r.Paint(headingStyle, fmt.Sprintf("📎 Attaching %d file(s):", len(descriptions))))
+	for _, description := range descriptions {
+		r.Println("  " + description)
+	}
+}
+
+// ShowPolicySelection notes a permission request answered by a permission
+// rule without asking the user
+func ShowPolicySelection(r render.Renderer, tool, selectedOption string) {
+	r.Printf("%s %s %s\n\n", r.Paint(headingStyle, "✓ Selected:"), selectedOption, r.Paint(detailStyle, "(policy for "+tool+")"))
+}
+
+// ShowPermissionCancelled notes a permission request left unanswered because
+// the turn was cancelled
+func ShowPermissionCancelled(r render.Renderer) {
+	r.Printf("%s\n\n", r.Paint(highlightStyle, "⏹ Cancelled"))
+}
+
+// paramDisplayOrder lists the parameters shown first, in this order; the rest
+// follow alphabetically so output is stable
+var paramDisplayOrder = []string{"📁 File", "💻 Command", "📝 Content", "📝 Edits", "old_string", "new_string"}
+
+func orderedParamKeys(params map[string]any) []string {
+	keys := make([]string, 0, len(params))
+	for _, key := range paramDisplayOrder {
+		if _, ok := params[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	var rest []string
+	for key := range params {
+		if !slices.Contains(paramDisplayOrder, key) {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(keys, rest...)
+}
+
+// Long file contents and replacements are cut to these many characters
+const (
+	maxContentLength     = 200
+	maxReplacementLength = 100
+)
+
+// shorten keeps the first length characters of s, followed by "..." when
+// anything was cut. Characters are graphemes, so none is split.
+func shorten(s string, length int) string {
+	graphemes := render.Graphemes(s)
+	if len(graphemes) <= length {
+		return s
+	}
+	return strings.Join(graphemes[:length], "") + "..."
+}
+
 func formatParamsForDisplay(rawParams map[string]any) map[string]any {
 	enhanced := make(map[string]any)
 	for key, value := range rawParams {
@@ -91,19 +219,11 @@ func formatParamsForDisplay(rawParams map[string]any) map[string]any {
 			}
 		case "content":
 			if str, ok := value.(string); ok {
-				if len(str) > 200 {
-					enhanced["📝 Content"] = str[:200] + "..."
-				} else {
-					enhanced["📝 Content"] = str
-				}
+				enhanced["📝 Content"] = shorten(str, maxContentLength)
 			}
 		case "old_string", "new_string":
 			if str, ok := value.(string); ok {
-				if len(str) > 100 {
-					enhanced[key] = str[:100] + "..."
-				} else {
-					enhanced[key] = str
-				}
+				enhanced[key] = shorten(str, maxReplacementLength)
 			}
 		case "edits":
 			if edits, ok := value.([]any); ok {
@@ -116,74 +236,105 @@ func formatParamsForDisplay(rawParams map[string]any) map[string]any {
 	return enhanced
 }
 
-// DisplayNotification shows a formatted notification message
-func DisplayNotification(data *NotificationData) error {
-	if data == nil {
+// DisplayNotification shows a formatted notification message. Events the
+// display has no view for, such as tool calls, are not shown.
+func DisplayNotification(r render.Renderer, event events.Event) error {
+	switch event.Type {
+	case events.Message:
+		r.Printf("%s %s", r.Paint(render.Style{Color: render.Blue, Bold: true}, "🤖 Assistant:"), event.Text)
+	case events.Prompt:
+		r.Printf("%s %s", r.Paint(headingStyle, "👤 You:"), event.Text)
+	case events.Thought:
+		r.Printf("%s %s", r.Paint(render.Style{Color: render.White, Bold: true}, "💬 Message:"), event.Text)
+	case events.PlanUpdated:
+		return DisplayTodoList(r, TodoListFromPlan(event.Plan))
+	default:
 		return nil
 	}
 
-	switch data.Type {
-	case NotificationAgentChunk:
-		fmt.Printf("\033[1;34m🤖 Assistant:\033[0m %s", data.Text)
-	case NotificationUser:
-		fmt.Printf("\033[1;32m👤 You:\033[0m %s", data.Text)
-	case NotificationGeneric:
-		if data.ContentType == "text" {
-			fmt.Printf("\033[1;37m💬 Message:\033[0m %s", data.Text)
-		} else {
-			fmt.Printf("\033[1;33m📄 %s:\033[0m %s", data.UpdateType, data.Text)
-		}
+	r.Println()
+	return nil
+}
+
+// DisplayTurnEnd shows why the agent stopped and how long the turn took
+func DisplayTurnEnd(r render.Renderer, result protocol.TurnResult, elapsed time.Duration) error {
+	took := elapsed.Round(100 * time.Millisecond)
+
+	if result.Err != nil {
+		r.Printf("%s %v (after %s)\n\n", r.Paint(render.Style{Color: render.Red, Bold: true}, "✖ Turn failed:"), result.Err, took)
+		return nil
+	}
+
+	icon, message, style := "✓", "Turn complete", headingStyle
+	switch result.StopReason {
+	case protocol.StopReasonEndTurn:
+	case protocol.StopReasonMaxTokens:
+		icon, message, style = "⚠", "Stopped at the token limit", highlightStyle
+	case protocol.StopReasonMaxTurnRequests:
+		icon, message, style = "⚠", "Stopped after too many model requests", highlightStyle
+	case protocol.StopReasonRefusal:
+		icon, message, style = "✖", "The agent refused to continue", render.Style{Color: render.Red, Bold: true}
+	case protocol.StopReasonCancelled:
+		icon, message, style = "⏹", "Turn cancelled", highlightStyle
+	default:
+		message = "Turn ended"
 	}
 
-	fmt.Println()
+	reason := result.StopReason
+	if reason == "" {
+		reason = "no stop reason"
+	}
+	r.Printf("%s %s\n\n", r.Paint(style, icon+" "+message), r.Paint(detailStyle, fmt.Sprintf("(%s, %s)", reason, took)))
 	return nil
 }
 
 // DisplayTodoList shows a formatted todo list
-func DisplayTodoList(todoData *TodoListData) error {
+func DisplayTodoList(r render.Renderer, todoData *TodoListData) error {
 	if todoData == nil {
 		return nil
 	}
 
-	fmt.Println("\n\033[1;35m📋 Todo List Update:\033[0m")
-	fmt.Println("\033[1;37m" + strings.Repeat("─", 50) + "\033[0m")
+	ruleStyle := render.Style{Color: render.White, Bold: true}
 
-	for i, entry := range todoData.Entries {
-		statusIcon, statusColor := formatTodoStatus(entry.Status)
-		priorityIndicator := formatTodoPriority(entry.Priority)
+	r.Println()
+	r.Println(r.Paint(render.Style{Color: render.Magenta, Bold: true}, "📋 Todo List Update:"))
+	r.Rule(ruleStyle)
 
-		fmt.Printf("%s%d. %s %s%s%s\033[0m\n",
-			statusColor,
-			i+1,
-			statusIcon,
-			entry.Content,
-			priorityIndicator,
-			"")
+	items := make([]render.ListItem, 0, len(todoData.Entries))
+	for _, entry := range todoData.Entries {
+		statusIcon, statusStyle := TodoStatusStyle(entry.Status)
+		items = append(items, render.ListItem{
+			Text:  fmt.Sprintf("%s %s%s", statusIcon, entry.Content, formatTodoPriority(r, entry.Priority)),
+			Style: statusStyle,
+		})
 	}
+	r.List(items)
 
-	fmt.Println("\033[1;37m" + strings.Repeat("─", 50) + "\033[0m\n")
+	r.Rule(ruleStyle)
+	r.Println()
 	return nil
 }
 
-func formatTodoStatus(status string) (string, string) {
+// TodoStatusStyle returns the icon and style a todo entry is shown with
+func TodoStatusStyle(status string) (string, render.Style) {
 	switch status {
 	case "completed":
-		return "✅", "\033[1;32m"
+		return "✅", render.Style{Color: render.Green, Bold: true}
 	case "in_progress":
-		return "🔄", "\033[1;33m"
+		return "🔄", render.Style{Color: render.Yellow, Bold: true}
 	case "pending":
-		return "⏳", "\033[1;36m"
+		return "⏳", render.Style{Color: render.Cyan, Bold: true}
 	default:
-		return "❓", "\033[1;37m"
+		return "❓", render.Style{Color: render.White, Bold: true}
 	}
 }
 
-func formatTodoPriority(priority string) string {
+func formatTodoPriority(r render.Renderer, priority string) string {
 	switch priority {
 	case "high":
-		return " \033[1;31m[HIGH]\033[0m"
+		return " " + r.Paint(render.Style{Color: render.Red, Bold: true}, "[HIGH]")
 	case "low":
-		return " \033[1;34m[LOW]\033[0m"
+		return " " + r.Paint(render.Style{Color: render.Blue, Bold: true}, "[LOW]")
 	default:
 		return ""
 	}
@@ -1,36 +1,119 @@
 package claude
 
 import (
+	"bytes"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"agentgo/internal/render"
 	"agentgo/protocol"
 )
 
 func TestDisplayToolRequest(t *testing.T) {
-	toolType := ToolBash
-	toolID := "test-tool-123"
-	params := map[string]any{
-		"💻 Command":  "ls -la",
-		"old_string": "old content",
-		"new_string": "new content",
-	}
+	event := ToolRequestEvent(protocol.SessionRequestPermissionParams{
+		ToolCall: protocol.ToolCall{ToolCallID: "test-tool-123", RawInput: map[string]any{"command": "ls -la"}},
+	}, time.Now())
 	options := []protocol.PermissionOption{
 		{OptionID: "allow", Name: "Allow once"},
 		{OptionID: "allow_always", Name: "Allow always"},
 		{OptionID: "reject", Name: "Reject"},
 	}
 
-	err := DisplayToolRequest(toolType, toolID, params, options)
+	var output bytes.Buffer
+	err := DisplayToolRequest(render.NewTextRenderer(&output, render.PlainText), event, options)
 	if err != nil {
 		t.Errorf("DisplayToolRequest() returned error: %v", err)
 	}
+
+	for _, expected := range []string{"╭─ Tool Request ", "│ 🔧 bash\n", "│ ID: test-tool-123\n", "│   💻 Command: ls -la\n", "│   [3] ❌ Reject\n"} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output.String())
+		}
+	}
+	if strings.Contains(output.String(), "\033[") {
+		t.Errorf("Expected plain text without escapes, got %q", output.String())
+	}
 }
 
 func TestShowUserSelection(t *testing.T) {
-	err := ShowUserSelection("Allow once")
+	var output bytes.Buffer
+	err := ShowUserSelection(render.NewTextRenderer(&output, render.PlainText), "Allow once")
 	if err != nil {
 		t.Errorf("ShowUserSelection() returned error: %v", err)
 	}
+	if output.String() != "✓ Selected: Allow once\n\n" {
+		t.Errorf("Unexpected output %q", output.String())
+	}
+}
+
+func TestPromptUserChoice(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  int
+		expected int
+	}{
+		{"3\n", 3, 3},
+		{" 1 \n", 3, 1},
+		{"9\n", 3, 2},
+		{"yes\n", 3, 2},
+		{"", 3, 2},
+		{"\n", 1, 1},
+		{"2\n", 1, 1},
+	}
+
+	for _, tt := range tests {
+		var output bytes.Buffer
+		input := strings.NewReader(tt.input + "left over")
+		choice, err := PromptUserChoice(render.NewTextRenderer(&output, render.PlainText), input, tt.options)
+		if err != nil {
+			t.Fatalf("PromptUserChoice(%q) error: %v", tt.input, err)
+		}
+		if choice != tt.expected {
+			t.Errorf("PromptUserChoice(%q) = %d, expected %d", tt.input, choice, tt.expected)
+		}
+		if tt.input != "" && input.Len() != len("left over") {
+			t.Errorf("PromptUserChoice(%q) read past the end of the line", tt.input)
+		}
+	}
+}
+
+type cancelledInput struct{}
+
+func (cancelledInput) Read(p []byte) (int, error) {
+	return 0, ErrPromptCancelled
+}
+
+func TestPromptUserChoice_Cancelled(t *testing.T) {
+	var output bytes.Buffer
+	_, err := PromptUserChoice(render.NewTextRenderer(&output, render.PlainText), cancelledInput{}, 2)
+	if !errors.Is(err, ErrPromptCancelled) {
+		t.Errorf("Expected the cancellation to be returned, got %v", err)
+	}
+}
+
+func TestDisplayTurnEnd(t *testing.T) {
+	tests := []struct {
+		result   protocol.TurnResult
+		expected string
+	}{
+		{protocol.TurnResult{StopReason: protocol.StopReasonEndTurn}, "✓ Turn complete (end_turn, 4.2s)\n\n"},
+		{protocol.TurnResult{StopReason: protocol.StopReasonMaxTokens}, "⚠ Stopped at the token limit (max_tokens, 4.2s)\n\n"},
+		{protocol.TurnResult{StopReason: protocol.StopReasonMaxTurnRequests}, "⚠ Stopped after too many model requests (max_turn_requests, 4.2s)\n\n"},
+		{protocol.TurnResult{StopReason: protocol.StopReasonRefusal}, "✖ The agent refused to continue (refusal, 4.2s)\n\n"},
+		{protocol.TurnResult{StopReason: protocol.StopReasonCancelled}, "⏹ Turn cancelled (cancelled, 4.2s)\n\n"},
+		{protocol.TurnResult{StopReason: "paused"}, "✓ Turn ended (paused, 4.2s)\n\n"},
+		{protocol.TurnResult{Err: errors.New("overloaded")}, "✖ Turn failed: overloaded (after 4.2s)\n\n"},
+	}
+
+	for _, tt := range tests {
+		var output bytes.Buffer
+		DisplayTurnEnd(render.NewTextRenderer(&output, render.PlainText), tt.result, 4230*time.Millisecond)
+		if output.String() != tt.expected {
+			t.Errorf("DisplayTurnEnd(%+v) = %q, expected %q", tt.result, output.String(), tt.expected)
+		}
+	}
 }
 
 func TestFormatParamsForDisplay(t *testing.T) {
@@ -77,6 +160,33 @@ func TestFormatParamsForDisplay(t *testing.T) {
 				"old_string": string(make([]rune, 100)) + "...",
 			},
 		},
+		{
+			name: "truncation keeps multi-byte characters whole",
+			input: map[string]any{
+				"new_string": strings.Repeat("é", 60) + strings.Repeat("日本", 30),
+			},
+			expected: map[string]any{
+				"new_string": strings.Repeat("é", 60) + strings.Repeat("日本", 20) + "...",
+			},
+		},
+		{
+			name: "truncation keeps combining marks with their letter",
+			input: map[string]any{
+				"old_string": strings.Repeat("e\u0301", 150),
+			},
+			expected: map[string]any{
+				"old_string": strings.Repeat("e\u0301", 100) + "...",
+			},
+		},
+		{
+			name: "truncation keeps emoji sequences whole",
+			input: map[string]any{
+				"new_string": strings.Repeat("a", 98) + "👩\u200d💻🇯🇵🇫🇷",
+			},
+			expected: map[string]any{
+				"new_string": strings.Repeat("a", 98) + "👩\u200d💻🇯🇵...",
+			},
+		},
 		{
 			name: "edits count formatting",
 			input: map[string]any{
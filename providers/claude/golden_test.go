@@ -0,0 +1,98 @@
+package claude
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agentgo/internal/render"
+	"agentgo/protocol"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// TestGoldenRecordings renders every recording in testdata and compares the
+// output with its .golden file. Run with -update to accept new output.
+func TestGoldenRecordings(t *testing.T) {
+	recordings, err := filepath.Glob(filepath.Join("testdata", "*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) == 0 {
+		t.Fatal("No recordings found in testdata")
+	}
+
+	for _, recording := range recordings {
+		name := strings.TrimSuffix(filepath.Base(recording), ".jsonl")
+		t.Run(name, func(t *testing.T) {
+			output := renderRecording(t, recording, "2\n")
+			assertGolden(t, strings.TrimSuffix(recording, ".jsonl")+".golden", output)
+		})
+	}
+}
+
+// goldenCapabilities renders with colors on a 50 column terminal regardless
+// of where tests run
+var goldenCapabilities = render.Capabilities{ColorDepth: render.Color16, Width: 50, Unicode: true}
+
+// renderRecording routes every agent message in a recording through the
+// Claude handlers, answering permission prompts from input, and returns
+// everything they drew
+func renderRecording(t *testing.T, recordingFile string, input string) []byte {
+	t.Helper()
+
+	conn, err := protocol.OpenAcpReplayConnection(recordingFile, protocol.ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Failed to open recording: %v", err)
+	}
+	defer conn.Close()
+
+	stepper, err := protocol.NewReplayStepper(conn)
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+
+	var output bytes.Buffer
+	claude := NewClaude()
+	claude.SetRenderer(render.NewTextRenderer(&output, goldenCapabilities))
+	claude.SetInput(strings.NewReader(strings.Repeat(input, stepper.Len())))
+	protocol.OnRequest(conn.Registry(), protocol.MethodSessionRequestPermission, claude.HandlePermissionRequest)
+	protocol.OnNotification(conn.Registry(), protocol.MethodSessionUpdate, claude.HandleNotification)
+
+	ch := make(chan protocol.TurnResult, 1)
+	for !stepper.Done() {
+		if _, err := stepper.Step(ch); err != nil {
+			t.Errorf("Failed to route message %d: %v", stepper.Position(), err)
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+
+	return output.Bytes()
+}
+
+func assertGolden(t *testing.T, goldenFile string, actual []byte) {
+	t.Helper()
+
+	if *updateGolden {
+		if err := os.WriteFile(goldenFile, actual, 0o644); err != nil {
+			t.Fatalf("Failed to update %s: %v", goldenFile, err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("Failed to read %s (run with -update to create it): %v", goldenFile, err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Output does not match %s (run with -update to accept):\n--- expected\n%s\n--- actual\n%s",
+			goldenFile, expected, actual)
+	}
+}
@@ -1,11 +1,44 @@
 package claude
 
 import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"agentgo/internal/render"
 	"agentgo/protocol"
 )
 
 // Claude implements the core interfaces for Claude provider
-type Claude struct{}
+type Claude struct {
+	// prompts lets only one permission prompt own the terminal at a time,
+	// serving waiting requests in the order they arrived
+	prompts promptQueue
+
+	renderer render.Renderer
+	input    io.Reader
+}
+
+// NewClaude creates a Claude provider that draws on stdout and reads
+// permission choices from stdin
+func NewClaude() *Claude {
+	return &Claude{
+		renderer: render.Stdout(),
+		input:    os.Stdin,
+	}
+}
+
+// SetRenderer changes where the provider draws its output
+func (c *Claude) SetRenderer(renderer render.Renderer) {
+	c.renderer = renderer
+}
+
+// SetInput changes where permission choices are read from
+func (c *Claude) SetInput(input io.Reader) {
+	c.input = input
+}
 
 // HandlePermissionRequest handles tool permission requests with Claude's distinctive UI
 func (c *Claude) HandlePermissionRequest(
@@ -13,23 +46,78 @@ func (c *Claude) HandlePermissionRequest(
 	raw []byte,
 	req protocol.SessionRequestPermissionRequest,
 ) error {
-	toolType := DetectToolType(raw)
-	toolParams := ExtractToolParams(raw)
-
-	if err := DisplayToolRequest(toolType, req.Params.ToolCall.ToolCallID, toolParams, req.Params.Options); err != nil {
+	option, ok, err := c.AskPermission(req)
+	if err != nil {
 		return err
 	}
+	if !ok {
+		return acpConn.SendToolCancelled(req.ID)
+	}
+	return acpConn.SendToolResponse(req.ID, option.OptionID)
+}
+
+// AskPermission shows a permission request and returns the option the user
+// selects, or false when the prompt is cancelled before they answer or the
+// request offers no options
+func (c *Claude) AskPermission(req protocol.SessionRequestPermissionRequest) (protocol.PermissionOption, bool, error) {
+	if len(req.Params.Options) == 0 {
+		return protocol.PermissionOption{}, false, nil
+	}
+
+	c.prompts.acquire()
+	defer c.prompts.release()
+
+	if err := DisplayToolRequest(c.renderer, ToolRequestEvent(req.Params, time.Now()), req.Params.Options); err != nil {
+		return protocol.PermissionOption{}, false, err
+	}
 
-	choice, err := PromptUserChoice(len(req.Params.Options))
+	choice, err := PromptUserChoice(c.renderer, c.input, len(req.Params.Options))
+	if errors.Is(err, ErrPromptCancelled) {
+		ShowPermissionCancelled(c.renderer)
+		return protocol.PermissionOption{}, false, nil
+	}
 	if err != nil {
-		return err
+		return protocol.PermissionOption{}, false, err
 	}
 
 	selectedOption := req.Params.Options[choice-1]
 
-	if err := ShowUserSelection(selectedOption.Name); err != nil {
-		return err
+	if err := ShowUserSelection(c.renderer, selectedOption.Name); err != nil {
+		return protocol.PermissionOption{}, false, err
 	}
 
-	return acpConn.SendToolResponse(req.ID, selectedOption.OptionID)
+	return selectedOption, true, nil
+}
+
+// promptQueue is a lock whose waiters take it first come, first served
+type promptQueue struct {
+	mutex   sync.Mutex
+	busy    bool
+	waiting []chan struct{}
+}
+
+// acquire waits for the earlier holders and waiters to finish
+func (q *promptQueue) acquire() {
+	q.mutex.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mutex.Unlock()
+		return
+	}
+	turn := make(chan struct{})
+	q.waiting = append(q.waiting, turn)
+	q.mutex.Unlock()
+	<-turn
+}
+
+// release hands the lock to the longest waiting caller
+func (q *promptQueue) release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.waiting) == 0 {
+		q.busy = false
+		return
+	}
+	close(q.waiting[0])
+	q.waiting = q.waiting[1:]
 }
@@ -0,0 +1,83 @@
+package claude
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"agentgo/internal/render"
+	"agentgo/protocol"
+)
+
+func TestPromptQueue_ServesInArrivalOrder(t *testing.T) {
+	var q promptQueue
+	q.acquire()
+
+	served := make(chan int, 3)
+	for i := range 3 {
+		go func() {
+			q.acquire()
+			served <- i
+			q.release()
+		}()
+		// Wait for the caller to queue up before starting the next
+		deadline := time.Now().Add(time.Second)
+		for {
+			q.mutex.Lock()
+			waiting := len(q.waiting)
+			q.mutex.Unlock()
+			if waiting == i+1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Caller %d did not queue up", i)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	q.release()
+	var order []int
+	for range 3 {
+		select {
+		case i := <-served:
+			order = append(order, i)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected every caller to be served, got %v", order)
+		}
+	}
+	if !reflect.DeepEqual(order, []int{0, 1, 2}) {
+		t.Errorf("Expected callers served in arrival order, got %v", order)
+	}
+}
+
+func TestAskPermission_FewOptions(t *testing.T) {
+	tests := []struct {
+		options []protocol.PermissionOption
+		input   string
+		want    string
+		ok      bool
+	}{
+		{[]protocol.PermissionOption{{OptionID: "allow", Name: "Allow"}}, "\n", "allow", true},
+		{[]protocol.PermissionOption{{OptionID: "allow", Name: "Allow"}}, "5\n", "allow", true},
+		{nil, "1\n", "", false},
+	}
+
+	for _, tt := range tests {
+		var output bytes.Buffer
+		c := NewClaude()
+		c.SetRenderer(render.NewTextRenderer(&output, render.PlainText))
+		c.SetInput(strings.NewReader(tt.input))
+
+		req := protocol.SessionRequestPermissionRequest{ID: 3, Params: protocol.SessionRequestPermissionParams{Options: tt.options}}
+		option, ok, err := c.AskPermission(req)
+		if err != nil {
+			t.Fatalf("AskPermission() with %d options error: %v", len(tt.options), err)
+		}
+		if ok != tt.ok || option.OptionID != tt.want {
+			t.Errorf("AskPermission() with %d options, input %q = %q, %v, expected %q, %v", len(tt.options), tt.input, option.OptionID, ok, tt.want, tt.ok)
+		}
+	}
+}
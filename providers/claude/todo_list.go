@@ -0,0 +1,31 @@
+package claude
+
+import "agentgo/protocol"
+
+// TodoEntry represents a single todo list entry
+type TodoEntry struct {
+	Content  string
+	Status   string
+	Priority string
+}
+
+// TodoListData holds parsed todo list information
+type TodoListData struct {
+	Entries []TodoEntry
+}
+
+// TodoListFromPlan converts decoded plan entries into todo list data
+func TodoListFromPlan(entries []protocol.PlanEntry) *TodoListData {
+	var todoEntries []TodoEntry
+	for _, entry := range entries {
+		todoEntries = append(todoEntries, TodoEntry{
+			Content:  entry.Content,
+			Status:   entry.Status,
+			Priority: entry.Priority,
+		})
+	}
+
+	return &TodoListData{
+		Entries: todoEntries,
+	}
+}
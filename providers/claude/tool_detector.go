@@ -1,7 +1,5 @@
 package claude
 
-import "encoding/json"
-
 type ToolType string
 
 const (
@@ -12,14 +10,8 @@ const (
 	ToolUnknown   ToolType = "unknown"
 )
 
-// DetectToolType classifies Claude's rawInput payloads.
-func DetectToolType(raw json.RawMessage) ToolType {
-	var m map[string]any
-	if err := json.Unmarshal(raw, &m); err != nil {
-		return ToolUnknown
-	}
-
-	ri := extractRawInput(m)
+// ClassifyToolInput classifies the rawInput payload of a tool call
+func ClassifyToolInput(ri map[string]any) ToolType {
 	if ri == nil {
 		return ToolUnknown
 	}
@@ -47,19 +39,3 @@ func DetectToolType(raw json.RawMessage) ToolType {
 		return ToolUnknown
 	}
 }
-
-func extractRawInput(m map[string]any) map[string]any {
-	if params, ok := m["params"].(map[string]any); ok {
-		if tc, ok := params["toolCall"].(map[string]any); ok {
-			if ri, ok := tc["rawInput"].(map[string]any); ok {
-				return ri
-			}
-		}
-	}
-	if m["file_path"] != nil || m["command"] != nil || m["content"] != nil || m["edits"] != nil ||
-		m["old_string"] != nil ||
-		m["new_string"] != nil {
-		return m
-	}
-	return nil
-}
@@ -1,98 +1,32 @@
 package claude
 
-import (
-	"encoding/json"
-	"testing"
-)
+import "testing"
 
-func TestDetectToolType(t *testing.T) {
+func TestClassifyToolInput(t *testing.T) {
 	tests := []struct {
 		name     string
-		input    string
+		input    map[string]any
 		expected ToolType
 	}{
-		{
-			name: "bash tool",
-			input: `{
-				"params": {
-					"toolCall": {
-						"rawInput": {
-							"command": "ls -la"
-						}
-					}
-				}
-			}`,
-			expected: ToolBash,
-		},
-		{
-			name: "write tool",
-			input: `{
-				"params": {
-					"toolCall": {
-						"rawInput": {
-							"file_path": "/path/to/file.txt",
-							"content": "Hello world"
-						}
-					}
-				}
-			}`,
-			expected: ToolWrite,
-		},
-		{
-			name: "edit tool",
-			input: `{
-				"params": {
-					"toolCall": {
-						"rawInput": {
-							"file_path": "/path/to/file.txt",
-							"old_string": "old content",
-							"new_string": "new content"
-						}
-					}
-				}
-			}`,
-			expected: ToolEdit,
-		},
-		{
-			name: "multi-edit tool",
-			input: `{
-				"params": {
-					"toolCall": {
-						"rawInput": {
-							"file_path": "/path/to/file.txt",
-							"edits": [
-								{"old_string": "old1", "new_string": "new1"},
-								{"old_string": "old2", "new_string": "new2"}
-							]
-						}
-					}
-				}
-			}`,
-			expected: ToolMultiEdit,
-		},
-		{
-			name: "unknown tool",
-			input: `{
-				"params": {
-					"toolCall": {
-						"rawInput": {
-							"unknown_field": "value"
-						}
-					}
-				}
-			}`,
-			expected: ToolUnknown,
-		},
+		{"bash tool", map[string]any{"command": "ls -la"}, ToolBash},
+		{"write tool", map[string]any{"file_path": "/path/to/file.txt", "content": "Hello world"}, ToolWrite},
+		{"edit tool", map[string]any{"file_path": "/path/to/file.txt", "old_string": "old content", "new_string": "new content"}, ToolEdit},
+		{"multi-edit tool", map[string]any{
+			"file_path": "/path/to/file.txt",
+			"edits": []any{
+				map[string]any{"old_string": "old1", "new_string": "new1"},
+				map[string]any{"old_string": "old2", "new_string": "new2"},
+			},
+		}, ToolMultiEdit},
+		{"unknown tool", map[string]any{"unknown_field": "value"}, ToolUnknown},
+		{"no input", nil, ToolUnknown},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			raw := json.RawMessage(tt.input)
-			result := DetectToolType(raw)
-			if result != tt.expected {
-				t.Errorf("DetectToolType() = %v, expected %v", result, tt.expected)
+			if result := ClassifyToolInput(tt.input); result != tt.expected {
+				t.Errorf("ClassifyToolInput() = %v, expected %v", result, tt.expected)
 			}
 		})
 	}
 }
-